@@ -0,0 +1,152 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Options configure NewWriter.
+type Options struct {
+	Compression Compression
+	// MasterKey wraps the data key of the backup; without one the artifact
+	// is only compressed.
+	MasterKey MasterKey
+	// ChunkSize is the plaintext size of an encrypted chunk, DefaultChunkSize
+	// when zero.
+	ChunkSize int
+}
+
+// Writer compresses and encrypts what is written to it. Close must be called
+// to flush the compressor and seal the final chunk.
+type Writer struct {
+	compress io.WriteCloser
+	encrypt  *encryptWriter
+	opts     Options
+}
+
+// NewWriter writes the artifact of a backup to w, which is typically the
+// writing end of a pipe into a StorageBackend.
+func NewWriter(w io.Writer, opts Options) (*Writer, error) {
+	if opts.Compression == "" {
+		opts.Compression = CompressionNone
+	}
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.ChunkSize < 0 || opts.ChunkSize > maxChunkSize {
+		return nil, errors.New("artifact: chunk size out of range")
+	}
+
+	aw := &Writer{opts: opts}
+	if opts.MasterKey != nil {
+		e, err := newEncryptWriter(w, opts.MasterKey, opts.Compression, opts.ChunkSize)
+		if err != nil {
+			return nil, err
+		}
+		aw.encrypt = e
+		w = e
+	}
+	c, err := compressWriter(w, opts.Compression)
+	if err != nil {
+		return nil, err
+	}
+	aw.compress = c
+	return aw, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) { return w.compress.Write(p) }
+
+// Close flushes the pipeline. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	err := w.compress.Close()
+	if w.encrypt != nil {
+		err = errors.Join(err, w.encrypt.Close())
+	}
+	return err
+}
+
+// KeyID is the id of the master key the data key is wrapped with, empty for
+// unencrypted artifacts. It belongs on the Backup record.
+func (w *Writer) KeyID() string {
+	if w.opts.MasterKey == nil {
+		return ""
+	}
+	return w.opts.MasterKey.ID()
+}
+
+func (w *Writer) Compression() Compression { return w.opts.Compression }
+
+// ReaderOptions configure NewReader.
+type ReaderOptions struct {
+	// Keys resolves the master key named in an encrypted artifact.
+	Keys *Keyring
+	// Compression of unencrypted artifacts; encrypted ones record it in
+	// their header.
+	Compression Compression
+}
+
+// Reader is the original backup stream of an artifact.
+type Reader struct {
+	io.ReadCloser
+	keyID       string
+	compression Compression
+}
+
+// KeyID is the id of the master key the artifact was encrypted with, empty
+// when it was not encrypted.
+func (r *Reader) KeyID() string { return r.keyID }
+
+func (r *Reader) Compression() Compression { return r.compression }
+
+// NewReader returns the original backup stream of an artifact written by
+// NewWriter. Encrypted artifacts are recognised by their header. Reads fail
+// with ErrCorrupt if the artifact was truncated or modified.
+func NewReader(r io.Reader, opts ReaderOptions) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(envelopeMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(magic, envelopeMagic) {
+		if opts.Compression == "" {
+			opts.Compression = CompressionNone
+		}
+		rc, err := decompressReader(br, opts.Compression)
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{ReadCloser: rc, compression: opts.Compression}, nil
+	}
+
+	br.Discard(len(envelopeMagic))
+	header, raw, err := readEnvelopeHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	d, err := newDecryptReader(br, header, raw, opts.Keys)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := decompressReader(d, header.Compression)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{ReadCloser: rc, keyID: header.KeyID, compression: header.Compression}, nil
+}
@@ -0,0 +1,114 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func testKey(t *testing.T, id string) MasterKey {
+	t.Helper()
+	raw := make([]byte, 32)
+	rand.Read(raw)
+	key, err := NewMasterKey(id, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func pack(t *testing.T, data []byte, opts Options) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewWriter(&out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func unpack(packed []byte, opts ReaderOptions) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(packed), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	key := testKey(t, "master-2024")
+	keys := NewKeyring(testKey(t, "master-2023"), key)
+	data := bytes.Repeat([]byte("INSERT INTO t VALUES (1, 'frabit');\n"), 3000)
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd, CompressionLZ4} {
+		for _, size := range []int{0, len(data)} {
+			for _, encrypted := range []bool{false, true} {
+				opts := Options{Compression: compression, ChunkSize: 4096}
+				if encrypted {
+					opts.MasterKey = key
+				}
+				packed := pack(t, data[:size], opts)
+				if encrypted && bytes.Contains(packed, []byte("frabit")) {
+					t.Fatalf("%s: plaintext visible in encrypted artifact", compression)
+				}
+				got, err := unpack(packed, ReaderOptions{Keys: keys, Compression: compression})
+				if err != nil {
+					t.Fatalf("%s encrypted=%v size=%d: %v", compression, encrypted, size, err)
+				}
+				if !bytes.Equal(got, data[:size]) {
+					t.Fatalf("%s encrypted=%v size=%d: round trip mismatch", compression, encrypted, size)
+				}
+			}
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	key := testKey(t, "k1")
+	data := make([]byte, 10000)
+	rand.Read(data)
+	packed := pack(t, data, Options{MasterKey: key, ChunkSize: 1024})
+	keys := NewKeyring(key)
+
+	flipped := bytes.Clone(packed)
+	flipped[len(flipped)-100] ^= 1
+	truncated := packed[:len(packed)-1500]
+	appended := append(bytes.Clone(packed), 0, 0, 0, 0)
+
+	for name, artifact := range map[string][]byte{"flipped": flipped, "truncated": truncated, "appended": appended} {
+		if _, err := unpack(artifact, ReaderOptions{Keys: keys}); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: err = %v, want ErrCorrupt", name, err)
+		}
+	}
+
+	if _, err := unpack(packed, ReaderOptions{Keys: NewKeyring(testKey(t, "k2"))}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("unknown key: err = %v", err)
+	}
+	if _, err := unpack(packed, ReaderOptions{Keys: NewKeyring(testKey(t, "k1"))}); err == nil {
+		t.Error("data key unwrapped with the wrong key material")
+	}
+}
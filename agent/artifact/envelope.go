@@ -0,0 +1,280 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An envelope is
+//
+//	magic | uint32 header length | JSON header | chunk...
+//
+// where every chunk is a uint32 ciphertext length followed by the AES-256-GCM
+// sealed chunk. The nonce of chunk i is the 7 byte prefix from the header,
+// i as uint32 and a byte that is 1 only for the final chunk, so chunks cannot
+// be reordered, dropped or appended without failing authentication. Every
+// chunk authenticates the header as additional data.
+var envelopeMagic = []byte("FRBXENC1")
+
+const (
+	DefaultChunkSize = 1 << 20
+	maxChunkSize     = 16 << 20
+	maxHeaderSize    = 64 << 10
+	noncePrefixSize  = 7
+	dataKeySize      = 32
+)
+
+var ErrCorrupt = errors.New("artifact: envelope is corrupt or was tampered with")
+
+type envelopeHeader struct {
+	KeyID       string      `json:"key_id"`
+	WrappedKey  []byte      `json:"wrapped_key"`
+	Compression Compression `json:"compression"`
+	ChunkSize   int         `json:"chunk_size"`
+	NoncePrefix []byte      `json:"nonce_prefix"`
+}
+
+func chunkNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if final {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptWriter seals everything written to it chunk by chunk.
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	buf     []byte
+	size    int
+	counter uint32
+	closed  bool
+}
+
+func newEncryptWriter(w io.Writer, key MasterKey, compression Compression, chunkSize int) (*encryptWriter, error) {
+	dataKey := make([]byte, dataKeySize)
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	wrapped, err := key.Wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(envelopeHeader{
+		KeyID:       key.ID(),
+		WrappedKey:  wrapped,
+		Compression: compression,
+		ChunkSize:   chunkSize,
+		NoncePrefix: prefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var preamble bytes.Buffer
+	preamble.Write(envelopeMagic)
+	binary.Write(&preamble, binary.BigEndian, uint32(len(header)))
+	preamble.Write(header)
+	if _, err := w.Write(preamble.Bytes()); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, header: header, prefix: prefix, size: chunkSize, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("artifact: write after close")
+	}
+	n := 0
+	for len(p) > 0 {
+		// a full buffer is only sealed once more data arrives, so the final
+		// chunk is never empty unless the whole stream is
+		if len(e.buf) == e.size {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		take := min(e.size-len(e.buf), len(p))
+		e.buf = append(e.buf, p[:take]...)
+		p = p[take:]
+		n += take
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.prefix, e.counter, final), e.buf, e.header)
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := e.w.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.counter++
+	if e.counter == 0 {
+		return errors.New("artifact: too many chunks")
+	}
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Close writes the final chunk. It does not close the underlying writer.
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// decryptReader opens an envelope chunk by chunk.
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	maxLen  int
+	counter uint32
+	plain   []byte
+	done    bool
+	err     error
+}
+
+// readEnvelopeHeader consumes the preamble of an envelope whose magic has
+// already been read.
+func readEnvelopeHeader(r *bufio.Reader) (*envelopeHeader, []byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, nil, ErrCorrupt
+	}
+	if length > maxHeaderSize {
+		return nil, nil, ErrCorrupt
+	}
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, nil, ErrCorrupt
+	}
+	var header envelopeHeader
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if header.ChunkSize <= 0 || header.ChunkSize > maxChunkSize || len(header.NoncePrefix) != noncePrefixSize {
+		return nil, nil, ErrCorrupt
+	}
+	return &header, raw, nil
+}
+
+func newDecryptReader(r *bufio.Reader, header *envelopeHeader, raw []byte, keys *Keyring) (*decryptReader, error) {
+	key, err := keys.Get(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := key.Unwrap(header.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      r,
+		aead:   aead,
+		header: raw,
+		prefix: header.NoncePrefix,
+		maxLen: header.ChunkSize + aead.Overhead(),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	var length uint32
+	if err := binary.Read(d.r, binary.BigEndian, &length); err != nil {
+		// the stream ended without a final chunk: truncated
+		return ErrCorrupt
+	}
+	if int(length) > d.maxLen || int(length) < d.aead.Overhead() {
+		return ErrCorrupt
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return ErrCorrupt
+	}
+
+	// only the final chunk opens with the final nonce; a failed Open clears
+	// its output, so it must not decrypt in place
+	plain, err := d.aead.Open(nil, chunkNonce(d.prefix, d.counter, false), sealed, d.header)
+	if err != nil {
+		plain, err = d.aead.Open(nil, chunkNonce(d.prefix, d.counter, true), sealed, d.header)
+		if err != nil {
+			return ErrCorrupt
+		}
+		d.done = true
+		if _, err := d.r.Peek(1); err != io.EOF {
+			return ErrCorrupt
+		}
+	}
+	d.counter++
+	d.plain = plain
+	return nil
+}
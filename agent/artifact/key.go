@@ -0,0 +1,119 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrUnknownKey = errors.New("artifact: unknown master key")
+
+// MasterKey wraps the per-backup data keys. Implementations backed by a KMS
+// keep the key material out of the agent entirely.
+type MasterKey interface {
+	ID() string
+	Wrap(dataKey []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// aesKey is a MasterKey held in memory, wrapping with AES-256-GCM.
+type aesKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewMasterKey returns a MasterKey for a 32 byte AES-256 key. The id is stored
+// with every backup, so restores can find the key again after rotation.
+func NewMasterKey(id string, key []byte) (MasterKey, error) {
+	if id == "" {
+		return nil, errors.New("artifact: master key id is required")
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("artifact: master key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesKey{id: id, aead: aead}, nil
+}
+
+func (k *aesKey) ID() string { return k.id }
+
+// additionalData binds a wrapped key to the id of the key that wrapped it.
+func (k *aesKey) additionalData() []byte { return []byte("frabit-data-key:" + k.id) }
+
+func (k *aesKey) Wrap(dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, dataKey, k.additionalData()), nil
+}
+
+func (k *aesKey) Unwrap(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < k.aead.NonceSize() {
+		return nil, errors.New("artifact: wrapped data key is truncated")
+	}
+	nonce, ciphertext := wrapped[:k.aead.NonceSize()], wrapped[k.aead.NonceSize():]
+	dataKey, err := k.aead.Open(nil, nonce, ciphertext, k.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("artifact: unwrap data key with %s: %w", k.id, err)
+	}
+	return dataKey, nil
+}
+
+// Keyring holds the master keys restores may need, old ones included.
+type Keyring struct {
+	mu   sync.RWMutex
+	keys map[string]MasterKey
+}
+
+func NewKeyring(keys ...MasterKey) *Keyring {
+	k := &Keyring{keys: make(map[string]MasterKey, len(keys))}
+	for _, key := range keys {
+		k.Add(key)
+	}
+	return k
+}
+
+func (k *Keyring) Add(key MasterKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[key.ID()] = key
+}
+
+func (k *Keyring) Get(id string) (MasterKey, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return key, nil
+}
@@ -0,0 +1,199 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/frabits/frabit-go-sdk/agent/artifact"
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// BackupTaskParams are the params of backup and restore tasks. Driver and
+// Storage name plugins in the registry the handler was built with.
+type BackupTaskParams struct {
+	Driver  string              `json:"driver"`
+	Storage string              `json:"storage"`
+	Key     string              `json:"key"`
+	Target  plugin.BackupTarget `json:"target"`
+
+	Compression artifact.Compression `json:"compression,omitempty"`
+	// KeyID selects the master key a backup is encrypted with; empty leaves
+	// it unencrypted. Restores take the key id from the artifact itself.
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// BackupTaskOutput is submitted as the output of a successful backup task.
+// Bytes counts the backup before compression.
+type BackupTaskOutput struct {
+	Key         string               `json:"key"`
+	Compression artifact.Compression `json:"compression"`
+	KeyID       string               `json:"key_id,omitempty"`
+	// Checksum is "sha256:<hex>" of the stored artifact
+	Checksum string `json:"checksum"`
+	plugin.BackupResult
+}
+
+type BackupTaskOption func(o *backupTaskOptions)
+
+type backupTaskOptions struct {
+	keys *artifact.Keyring
+}
+
+// WithKeyring provides the master keys backups are encrypted with and
+// restores decrypt with.
+func WithKeyring(keys *artifact.Keyring) BackupTaskOption {
+	return func(o *backupTaskOptions) {
+		o.keys = keys
+	}
+}
+
+func newBackupTaskOptions(opts []BackupTaskOption) backupTaskOptions {
+	var o backupTaskOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// BackupTaskHandler streams a backup from an engine driver through the
+// artifact pipeline straight into a storage backend.
+func BackupTaskHandler(plugins *plugin.Registry, opts ...BackupTaskOption) TaskHandler {
+	o := newBackupTaskOptions(opts)
+	return TaskHandlerFunc(func(ctx context.Context, task frabit.Task, reporter Reporter) (any, error) {
+		params, driver, storage, err := lookupBackupPlugins(plugins, task)
+		if err != nil {
+			return nil, err
+		}
+		pipeline := artifact.Options{Compression: params.Compression}
+		if params.KeyID != "" {
+			if pipeline.MasterKey, err = o.keys.Get(params.KeyID); err != nil {
+				return nil, err
+			}
+		}
+		reporter.Logf("backing up with %s to %s:%s", params.Driver, params.Storage, params.Key)
+
+		pr, pw := io.Pipe()
+		stored := make(chan error, 1)
+		go func() {
+			err := storage.Put(ctx, params.Key, pr)
+			pr.CloseWithError(err)
+			stored <- err
+		}()
+		sum := sha256.New()
+		w, err := artifact.NewWriter(io.MultiWriter(pw, sum), pipeline)
+		if err != nil {
+			pw.CloseWithError(err)
+			<-stored
+			return nil, err
+		}
+		result, err := driver.Backup(ctx, params.Target, w)
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+		if putErr := <-stored; err == nil {
+			err = putErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		reporter.Logf("backup finished, %d bytes", result.Bytes)
+		return BackupTaskOutput{
+			Key:          params.Key,
+			Compression:  w.Compression(),
+			KeyID:        w.KeyID(),
+			Checksum:     checksum(sum.Sum(nil)),
+			BackupResult: *result,
+		}, nil
+	})
+}
+
+// RestoreTaskHandler restores a backup read from a storage backend,
+// decrypting and decompressing it on the way.
+func RestoreTaskHandler(plugins *plugin.Registry, opts ...BackupTaskOption) TaskHandler {
+	o := newBackupTaskOptions(opts)
+	return TaskHandlerFunc(func(ctx context.Context, task frabit.Task, reporter Reporter) (any, error) {
+		params, driver, storage, err := lookupBackupPlugins(plugins, task)
+		if err != nil {
+			return nil, err
+		}
+		reporter.Logf("restoring %s:%s with %s", params.Storage, params.Key, params.Driver)
+
+		source, err := storage.Get(ctx, params.Key)
+		if err != nil {
+			return nil, err
+		}
+		defer source.Close()
+		r, err := artifact.NewReader(source, artifact.ReaderOptions{Keys: o.keys, Compression: params.Compression})
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		if err := checkKeyID(r, params.KeyID); err != nil {
+			return nil, err
+		}
+		return nil, driver.Restore(ctx, params.Target, r)
+	})
+}
+
+// checkKeyID rejects an artifact that is not encrypted with the key the
+// backup was taken with. Unencrypted artifacts carry no authentication, so
+// one swapped in on the storage backend would otherwise be trusted.
+func checkKeyID(r *artifact.Reader, keyID string) error {
+	if keyID != "" && r.KeyID() != keyID {
+		if r.KeyID() == "" {
+			return fmt.Errorf("agent: backup was encrypted with %s but the stored artifact is not encrypted", keyID)
+		}
+		return fmt.Errorf("agent: backup was encrypted with %s but the stored artifact uses %s", keyID, r.KeyID())
+	}
+	return nil
+}
+
+func checksum(sum []byte) string {
+	return "sha256:" + hex.EncodeToString(sum)
+}
+
+func lookupBackupPlugins(plugins *plugin.Registry, task frabit.Task) (*BackupTaskParams, plugin.EngineDriver, plugin.StorageBackend, error) {
+	var params BackupTaskParams
+	if err := json.Unmarshal(task.Params, &params); err != nil {
+		return nil, nil, nil, fmt.Errorf("agent: invalid %s task params: %w", task.Type, err)
+	}
+	driver, storage, err := backupPlugins(plugins, params)
+	return &params, driver, storage, err
+}
+
+func backupPlugins(plugins *plugin.Registry, params BackupTaskParams) (plugin.EngineDriver, plugin.StorageBackend, error) {
+	p, err := plugins.Get(params.Driver)
+	if err != nil {
+		return nil, nil, err
+	}
+	driver, ok := p.(plugin.EngineDriver)
+	if !ok {
+		return nil, nil, fmt.Errorf("agent: plugin %q is not an engine driver", params.Driver)
+	}
+	storage, err := storagePlugin(plugins, params.Storage)
+	if err != nil {
+		return nil, nil, err
+	}
+	return driver, storage, nil
+}
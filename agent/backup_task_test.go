@@ -0,0 +1,140 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/agent/artifact"
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+	"github.com/frabits/frabit-go-sdk/plugin/storage"
+)
+
+// memoryDriver dumps a fixed payload and remembers what it restored.
+type memoryDriver struct {
+	dump     []byte
+	restored []byte
+}
+
+func (d *memoryDriver) Metadata() plugin.Metadata {
+	return plugin.Metadata{Name: "memory", Kind: plugin.KindEngine, APIVersion: plugin.APIVersion}
+}
+func (d *memoryDriver) Close() error                            { return nil }
+func (d *memoryDriver) Capabilities() plugin.EngineCapabilities { return plugin.EngineCapabilities{} }
+
+func (d *memoryDriver) Backup(ctx context.Context, target plugin.BackupTarget, sink io.Writer) (*plugin.BackupResult, error) {
+	n, err := sink.Write(d.dump)
+	return &plugin.BackupResult{Method: plugin.BackupLogical, Bytes: int64(n), StartedAt: time.Now(), FinishedAt: time.Now()}, err
+}
+
+func (d *memoryDriver) Restore(ctx context.Context, target plugin.BackupTarget, source io.Reader) (err error) {
+	d.restored, err = io.ReadAll(source)
+	return err
+}
+
+func (d *memoryDriver) Verify(ctx context.Context, method plugin.BackupMethod, source io.Reader) error {
+	return nil
+}
+
+func (d *memoryDriver) ListLogPositions(ctx context.Context, target plugin.BackupTarget) ([]plugin.LogPosition, error) {
+	return nil, nil
+}
+
+type discardReporter struct{}
+
+func (discardReporter) Progress(ctx context.Context, percent float64, message string) error {
+	return nil
+}
+func (discardReporter) Logf(format string, args ...any) {}
+
+func TestEncryptedBackupRoundTrip(t *testing.T) {
+	local, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver := &memoryDriver{dump: bytes.Repeat([]byte("CREATE TABLE secret_table (id int);\n"), 500)}
+	plugins := plugin.NewRegistry()
+	if err := plugins.Register(driver); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugins.Register(local); err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, 32)
+	rand.Read(raw)
+	key, _ := artifact.NewMasterKey("master-1", raw)
+	keys := artifact.NewKeyring(key)
+
+	params, _ := json.Marshal(BackupTaskParams{Driver: "memory", Storage: "local", Key: "db1/full.zst", Compression: artifact.CompressionZstd, KeyID: "master-1"})
+	ctx := context.Background()
+	out, err := BackupTaskHandler(plugins, WithKeyring(keys)).Handle(ctx, frabit.Task{Type: frabit.TaskBackup, Params: params}, discardReporter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := out.(BackupTaskOutput); output.KeyID != "master-1" || output.Compression != artifact.CompressionZstd {
+		t.Errorf("output = %+v", output)
+	}
+
+	stored, _ := local.Get(ctx, "db1/full.zst")
+	data, _ := io.ReadAll(stored)
+	stored.Close()
+	if bytes.Contains(data, []byte("secret_table")) {
+		t.Fatal("artifact stored in plaintext")
+	}
+
+	if _, err := RestoreTaskHandler(plugins, WithKeyring(keys)).Handle(ctx, frabit.Task{Type: frabit.TaskRestore, Params: params}, discardReporter{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(driver.restored, driver.dump) {
+		t.Fatal("restored data differs from the backup")
+	}
+}
+
+func TestRestoreRejectsDowngradedArtifact(t *testing.T) {
+	local, _ := storage.NewLocal(t.TempDir())
+	driver := &memoryDriver{}
+	plugins := plugin.NewRegistry()
+	plugins.Register(driver)
+	plugins.Register(local)
+	raw := make([]byte, 32)
+	rand.Read(raw)
+	key, _ := artifact.NewMasterKey("master-1", raw)
+	ctx := context.Background()
+
+	// an unencrypted artifact put in place of the encrypted backup
+	var buf bytes.Buffer
+	w, _ := artifact.NewWriter(&buf, artifact.Options{Compression: artifact.CompressionZstd})
+	w.Write([]byte("DROP TABLE users;\n"))
+	w.Close()
+	if err := local.Put(ctx, "db1/full.zst", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	params, _ := json.Marshal(BackupTaskParams{Driver: "memory", Storage: "local", Key: "db1/full.zst", Compression: artifact.CompressionZstd, KeyID: "master-1"})
+	if _, err := RestoreTaskHandler(plugins, WithKeyring(artifact.NewKeyring(key))).Handle(ctx, frabit.Task{Type: frabit.TaskRestore, Params: params}, discardReporter{}); err == nil {
+		t.Fatal("restore of an unencrypted artifact succeeded")
+	}
+	if driver.restored != nil {
+		t.Errorf("restored %q", driver.restored)
+	}
+}
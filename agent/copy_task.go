@@ -0,0 +1,124 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// CopyTaskParams are the params of backup copy tasks. Storage names are
+// plugins in the handler's registry.
+type CopyTaskParams struct {
+	SourceStorage      string `json:"source_storage"`
+	SourceKey          string `json:"source_key"`
+	DestinationStorage string `json:"destination_storage"`
+	DestinationKey     string `json:"destination_key"`
+	// Checksum is the checksum recorded for the backup; the copy fails if the
+	// source no longer matches it.
+	Checksum string `json:"checksum"`
+}
+
+type CopyTaskOutput struct {
+	Key      string `json:"key"`
+	Checksum string `json:"checksum"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// CopyTaskHandler copies a backup artifact between storage backends as is;
+// it stays encrypted with its original data key.
+func CopyTaskHandler(plugins *plugin.Registry) TaskHandler {
+	return TaskHandlerFunc(func(ctx context.Context, task frabit.Task, reporter Reporter) (any, error) {
+		var params CopyTaskParams
+		if err := json.Unmarshal(task.Params, &params); err != nil {
+			return nil, fmt.Errorf("agent: invalid %s task params: %w", task.Type, err)
+		}
+		source, err := storagePlugin(plugins, params.SourceStorage)
+		if err != nil {
+			return nil, err
+		}
+		destination, err := storagePlugin(plugins, params.DestinationStorage)
+		if err != nil {
+			return nil, err
+		}
+		reporter.Logf("copying %s:%s to %s:%s", params.SourceStorage, params.SourceKey, params.DestinationStorage, params.DestinationKey)
+
+		r, err := source.Get(ctx, params.SourceKey)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		sum := sha256.New()
+		counted := &countingReader{r: io.TeeReader(r, sum)}
+		// a mismatch fails the last read, so Put aborts instead of
+		// replacing what is at the destination key
+		verified := &checksumReader{r: counted, sum: sum, want: params.Checksum}
+		if err := destination.Put(ctx, params.DestinationKey, verified); err != nil {
+			if verified.mismatch != "" {
+				return nil, fmt.Errorf("agent: source %s:%s has checksum %s, backup recorded %s",
+					params.SourceStorage, params.SourceKey, verified.mismatch, params.Checksum)
+			}
+			return nil, err
+		}
+
+		out := CopyTaskOutput{Key: params.DestinationKey, Checksum: checksum(sum.Sum(nil)), Bytes: counted.n}
+		reporter.Logf("copied %d bytes", out.Bytes)
+		return out, nil
+	})
+}
+
+// checksumReader fails with errChecksumMismatch instead of returning
+// io.EOF when what was read does not match want.
+type checksumReader struct {
+	r    io.Reader
+	sum  hash.Hash
+	want string
+	// mismatch is the checksum actually read, set on a mismatch
+	mismatch string
+}
+
+var errChecksumMismatch = errors.New("agent: checksum mismatch")
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF && c.want != "" {
+		if got := checksum(c.sum.Sum(nil)); got != c.want {
+			c.mismatch = got
+			return n, errChecksumMismatch
+		}
+	}
+	return n, err
+}
+
+func storagePlugin(plugins *plugin.Registry, name string) (plugin.StorageBackend, error) {
+	p, err := plugins.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	storage, ok := p.(plugin.StorageBackend)
+	if !ok {
+		return nil, fmt.Errorf("agent: plugin %q is not a storage backend", name)
+	}
+	return storage, nil
+}
@@ -0,0 +1,93 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+	"github.com/frabits/frabit-go-sdk/plugin/storage"
+)
+
+type namedStorage struct {
+	plugin.StorageBackend
+	name string
+}
+
+func (n namedStorage) Metadata() plugin.Metadata {
+	meta := n.StorageBackend.Metadata()
+	meta.Name = n.name
+	return meta
+}
+
+func TestCopyTask(t *testing.T) {
+	ctx := context.Background()
+	primary, _ := storage.NewLocal(t.TempDir())
+	offsite, _ := storage.NewLocal(t.TempDir())
+	plugins := plugin.NewRegistry()
+	plugins.Register(namedStorage{primary, "primary"})
+	plugins.Register(namedStorage{offsite, "offsite"})
+	primary.Put(ctx, "b1", strings.NewReader("artifact bytes"))
+
+	run := func(key, checksum string) (any, error) {
+		params, _ := json.Marshal(CopyTaskParams{
+			SourceStorage: "primary", SourceKey: "b1",
+			DestinationStorage: "offsite", DestinationKey: key,
+			Checksum: checksum,
+		})
+		return CopyTaskHandler(plugins).Handle(ctx, frabit.Task{Type: frabit.TaskCopy, Params: params}, discardReporter{})
+	}
+
+	out, err := run("copies/b1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := out.(CopyTaskOutput)
+	r, err := offsite.Get(ctx, "copies/b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "artifact bytes" || copied.Bytes != int64(len(data)) {
+		t.Fatalf("copy = %q, output %+v", data, copied)
+	}
+
+	if _, err := run("copies/b1", copied.Checksum); err != nil {
+		t.Fatalf("copy with matching checksum: %v", err)
+	}
+	if _, err := run("copies/b2", "sha256:0000"); err == nil {
+		t.Fatal("copy with mismatched checksum succeeded")
+	}
+	if _, err := offsite.Stat(ctx, "copies/b2"); !errors.Is(err, plugin.ErrObjectNotFound) {
+		t.Fatalf("mismatched copy left behind: %v", err)
+	}
+
+	// a retried copy that does not match keeps the good copy in place
+	primary.Put(ctx, "b1", strings.NewReader("corrupted bytes"))
+	if _, err := run("copies/b1", copied.Checksum); err == nil {
+		t.Fatal("copy of a changed source succeeded")
+	}
+	if info, err := offsite.Stat(ctx, "copies/b1"); err != nil || info.Size != copied.Bytes {
+		t.Fatalf("good copy replaced: %+v, %v", info, err)
+	}
+}
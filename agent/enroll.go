@@ -0,0 +1,143 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// Enrollment names the files an enrolled agent keeps its credentials in. They
+// are meant to be passed on to frabit.WithClientCertificate and frabit.WithRootCAs.
+type Enrollment struct {
+	CertFile string
+	KeyFile  string
+	// CAFile is optional; the CA bundle returned by the server is dropped when empty
+	CAFile string
+}
+
+// Enroll trades a one-time join token for a client certificate. The private
+// key is generated locally and never leaves the host, only its signing
+// request is sent. Re-running Enroll with a new token rotates the credentials
+// in place, which clients built with frabit.WithClientCertificate pick up.
+func Enroll(ctx context.Context, client *frabit.Client, joinToken, agentID string, out Enrollment) (*frabit.EnrollAgentResponse, error) {
+	if joinToken == "" || agentID == "" {
+		return nil, errors.New("agent: join token and agent id are required")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: agentID},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Agent.Enroll(ctx, frabit.EnrollAgentRequest{
+		JoinToken: joinToken,
+		AgentID:   agentID,
+		CSR:       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode([]byte(resp.Certificate)); block == nil {
+		return nil, errors.New("agent: enrolment returned no certificate")
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	files := []stagedFile{
+		// the key goes first: the certificate file is what rotation watchers look at
+		{name: out.KeyFile, data: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), perm: 0o600},
+		{name: out.CertFile, data: []byte(resp.Certificate), perm: 0o644},
+	}
+	if out.CAFile != "" && resp.CABundle != "" {
+		files = append(files, stagedFile{name: out.CAFile, data: []byte(resp.CABundle), perm: 0o644})
+	}
+	if err := writeFilesAtomic(files); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type stagedFile struct {
+	name string
+	data []byte
+	perm os.FileMode
+	tmp  string
+}
+
+// writeFilesAtomic writes every file to a temporary one before renaming any
+// into place, so a failed write leaves the old credentials untouched.
+func writeFilesAtomic(files []stagedFile) error {
+	defer func() {
+		for _, f := range files {
+			if f.tmp != "" {
+				os.Remove(f.tmp)
+			}
+		}
+	}()
+	for i := range files {
+		tmp, err := writeTemp(files[i].name, files[i].data, files[i].perm)
+		if err != nil {
+			return err
+		}
+		files[i].tmp = tmp
+	}
+	for i := range files {
+		if err := os.Rename(files[i].tmp, files[i].name); err != nil {
+			return err
+		}
+		files[i].tmp = ""
+	}
+	return nil
+}
+
+// writeTemp writes data to a temporary file next to name and returns its name.
+func writeTemp(name string, data []byte, perm os.FileMode) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-"+filepath.Base(name)+"-*")
+	if err != nil {
+		return "", err
+	}
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
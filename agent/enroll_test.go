@@ -0,0 +1,95 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/frabits/frabit-go-sdk/frabittest"
+)
+
+func TestEnroll(t *testing.T) {
+	srv := frabittest.NewServer(t, frabittest.WithJoinToken("join-1"))
+	client := srv.Client()
+	ctx := context.Background()
+	dir := t.TempDir()
+	out := Enrollment{
+		CertFile: filepath.Join(dir, "agent.crt"),
+		KeyFile:  filepath.Join(dir, "agent.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	noFiles := func(what string) {
+		t.Helper()
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("%s left %d files behind, first %s", what, len(entries), entries[0].Name())
+		}
+	}
+
+	if _, err := Enroll(ctx, client, "wrong", "agent-1", out); err == nil {
+		t.Fatal("enrolled with a wrong join token")
+	}
+	noFiles("rejected join token")
+
+	srv.InjectFault(frabittest.Fault{Method: http.MethodPost, Path: "/api/v2/agents/enroll", Status: http.StatusOK, Body: `{"certificate": ""}`, Times: 1})
+	if _, err := Enroll(ctx, client, "join-1", "agent-1", out); err == nil {
+		t.Fatal("enrolled without a certificate")
+	}
+	noFiles("empty certificate")
+
+	// the CA can not be written, so neither may the key and certificate
+	broken := out
+	broken.CAFile = filepath.Join(dir, "missing", "ca.crt")
+	if _, err := Enroll(ctx, client, "join-1", "agent-1", broken); err == nil {
+		t.Fatal("enrolled with an unwritable CA file")
+	}
+	noFiles("unwritable CA file")
+
+	if _, err := Enroll(ctx, client, "join-1", "agent-1", out); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{out.KeyFile: 0o600, out.CertFile: 0o644, out.CAFile: 0o644} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != mode {
+			t.Errorf("%s mode = %v, want %v", filepath.Base(name), info.Mode().Perm(), mode)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("%d files in %s, want 3", len(entries), dir)
+	}
+
+	certPEM, _ := os.ReadFile(out.CertFile)
+	keyPEM, _ := os.ReadFile(out.KeyFile)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || cert.Subject.CommonName != "agent-1" {
+		t.Fatalf("certificate = %v, %v", cert, err)
+	}
+	block, _ = pem.Decode(keyPEM)
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil || !key.PublicKey.Equal(cert.PublicKey) {
+		t.Errorf("key does not match the certificate: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+const engineProbeTimeout = 5 * time.Second
+
+// EngineProbe locates an installed database engine by its server binaries.
+type EngineProbe struct {
+	Engine   string
+	Binaries []string
+}
+
+// DefaultEngineProbes covers the engines Frabit manages.
+var DefaultEngineProbes = []EngineProbe{
+	{Engine: "mysql", Binaries: []string{"mysqld", "mariadbd"}},
+	{Engine: "postgresql", Binaries: []string{"postgres"}},
+	{Engine: "mongodb", Binaries: []string{"mongod"}},
+	{Engine: "redis", Binaries: []string{"redis-server"}},
+}
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+type InventoryOption func(c *InventoryCollector) error
+
+// InventoryCollector gathers the host facts an agent reports to Frabit.
+type InventoryCollector struct {
+	procRoot string
+	sysRoot  string
+	etcRoot  string
+
+	agentVersion string
+	capabilities []string
+	probes       []EngineProbe
+}
+
+// WithCapabilities declares what the agent can do, e.g. the task types it handles.
+func WithCapabilities(capabilities ...string) InventoryOption {
+	return func(c *InventoryCollector) error {
+		c.capabilities = append(c.capabilities, capabilities...)
+		return nil
+	}
+}
+
+// WithAgentVersion overrides the reported agent version, which defaults to the SDK version.
+func WithAgentVersion(version string) InventoryOption {
+	return func(c *InventoryCollector) error {
+		c.agentVersion = version
+		return nil
+	}
+}
+
+// WithEngineProbes replaces DefaultEngineProbes.
+func WithEngineProbes(probes ...EngineProbe) InventoryOption {
+	return func(c *InventoryCollector) error {
+		c.probes = probes
+		return nil
+	}
+}
+
+// WithHostRoot reads /proc, /sys and /etc below root, for agents running in a
+// container with the host filesystem mounted.
+func WithHostRoot(root string) InventoryOption {
+	return func(c *InventoryCollector) error {
+		c.procRoot = root + "/proc"
+		c.sysRoot = root + "/sys"
+		c.etcRoot = root + "/etc"
+		return nil
+	}
+}
+
+func NewInventoryCollector(opts ...InventoryOption) (*InventoryCollector, error) {
+	c := &InventoryCollector{
+		procRoot:     "/proc",
+		sysRoot:      "/sys",
+		etcRoot:      "/etc",
+		agentVersion: frabit.Version,
+		probes:       DefaultEngineProbes,
+	}
+
+	for _, opt := range opts {
+		err := opt(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Collect takes a fresh snapshot of the host. Facts that can not be read are
+// left empty rather than failing the whole snapshot.
+func (c *InventoryCollector) Collect(ctx context.Context) (*frabit.AgentInventory, error) {
+	inv := &frabit.AgentInventory{
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		CPU:          frabit.CPUInfo{Cores: runtime.NumCPU()},
+		AgentVersion: c.agentVersion,
+		Capabilities: c.capabilities,
+		CollectedAt:  time.Now().UTC(),
+	}
+	inv.Hostname, _ = os.Hostname()
+
+	c.collectHost(inv)
+	inv.Engines = c.collectEngines(ctx)
+
+	return inv, nil
+}
+
+func (c *InventoryCollector) collectEngines(ctx context.Context) []frabit.EngineInfo {
+	var engines []frabit.EngineInfo
+	for _, probe := range c.probes {
+		for _, bin := range probe.Binaries {
+			path, err := exec.LookPath(bin)
+			if err != nil {
+				continue
+			}
+			engines = append(engines, frabit.EngineInfo{
+				Engine:  probe.Engine,
+				Version: engineVersion(ctx, path),
+				Path:    path,
+			})
+		}
+	}
+	return engines
+}
+
+func engineVersion(ctx context.Context, path string) string {
+	ctx, cancel := context.WithTimeout(ctx, engineProbeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return versionPattern.FindString(string(out))
+}
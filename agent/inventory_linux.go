@@ -0,0 +1,191 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package agent
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// tcpListen is the socket state of a listening socket in /proc/net/tcp.
+const tcpListen = "0A"
+
+func (c *InventoryCollector) collectHost(inv *frabit.AgentInventory) {
+	if name := osReleaseName(filepath.Join(c.etcRoot, "os-release")); name != "" {
+		inv.OS = name
+	}
+	inv.Kernel = readTrimmed(filepath.Join(c.procRoot, "sys/kernel/osrelease"))
+	if cpu, ok := cpuInfo(filepath.Join(c.procRoot, "cpuinfo")); ok {
+		inv.CPU = cpu
+	}
+	inv.MemoryBytes = memTotal(filepath.Join(c.procRoot, "meminfo"))
+	inv.Disks = blockDevices(filepath.Join(c.sysRoot, "block"))
+	inv.ListeningPorts = append(
+		listeningPorts(filepath.Join(c.procRoot, "net/tcp"), "tcp"),
+		listeningPorts(filepath.Join(c.procRoot, "net/tcp6"), "tcp6")...,
+	)
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func osReleaseName(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+			return strings.Trim(v, `"'`)
+		}
+	}
+	return ""
+}
+
+func cpuInfo(path string) (frabit.CPUInfo, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return frabit.CPUInfo{}, false
+	}
+	defer f.Close()
+
+	var cpu frabit.CPUInfo
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "processor":
+			cpu.Cores++
+		case "model name":
+			if cpu.Model == "" {
+				cpu.Model = strings.TrimSpace(value)
+			}
+		}
+	}
+	return cpu, cpu.Cores > 0
+}
+
+func memTotal(path string) uint64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+func blockDevices(dir string) []frabit.DiskInfo {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var disks []frabit.DiskInfo
+	for _, e := range entries {
+		name := e.Name()
+		// virtual devices carry no data worth scheduling on
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		sectors, _ := strconv.ParseUint(readTrimmed(filepath.Join(dir, name, "size")), 10, 64)
+		disks = append(disks, frabit.DiskInfo{
+			Name: name,
+			// sysfs always counts in 512 byte sectors, whatever the device block size
+			SizeBytes:  sectors * 512,
+			Rotational: readTrimmed(filepath.Join(dir, name, "queue/rotational")) == "1",
+		})
+	}
+	return disks
+}
+
+func listeningPorts(path, protocol string) []frabit.ListeningPort {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var ports []frabit.ListeningPort
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+		addr, port, ok := parseProcAddr(fields[1])
+		if !ok {
+			continue
+		}
+		key := addr + ":" + strconv.Itoa(port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ports = append(ports, frabit.ListeningPort{Protocol: protocol, Address: addr, Port: port})
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// parseProcAddr decodes an address of /proc/net/tcp{,6} such as
+// "0100007F:0CEA". The address is stored as 32 bit words in host byte order.
+func parseProcAddr(s string) (string, int, bool) {
+	hexAddr, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, false
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", 0, false
+	}
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, false
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return net.IP(raw).String(), int(port), true
+}
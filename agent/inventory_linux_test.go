@@ -0,0 +1,85 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectHostFacts(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "etc/os-release"), "NAME=Debian\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n")
+	writeFile(t, filepath.Join(root, "proc/sys/kernel/osrelease"), "6.1.0-18-amd64\n")
+	writeFile(t, filepath.Join(root, "proc/cpuinfo"), "processor\t: 0\nmodel name\t: AMD EPYC 7B13\n\nprocessor\t: 1\nmodel name\t: AMD EPYC 7B13\n")
+	writeFile(t, filepath.Join(root, "proc/meminfo"), "MemTotal:       16384000 kB\nMemFree:         1024 kB\n")
+	writeFile(t, filepath.Join(root, "proc/net/tcp"),
+		"  sl  local_address rem_address   st\n"+
+			"   0: 00000000:0CEA 00000000:0000 0A\n"+
+			"   1: 0100007F:1538 00000000:0000 0A\n"+
+			"   2: 0100007F:D2A0 0100007F:0CEA 01\n")
+	writeFile(t, filepath.Join(root, "sys/block/sda/size"), "2097152\n")
+	writeFile(t, filepath.Join(root, "sys/block/sda/queue/rotational"), "1\n")
+	writeFile(t, filepath.Join(root, "sys/block/loop0/size"), "8\n")
+
+	c, err := NewInventoryCollector(WithHostRoot(root), WithEngineProbes(), WithCapabilities("backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inv.OS != "Debian GNU/Linux 12 (bookworm)" || inv.Kernel != "6.1.0-18-amd64" {
+		t.Errorf("os = %q, kernel = %q", inv.OS, inv.Kernel)
+	}
+	if inv.CPU != (frabit.CPUInfo{Model: "AMD EPYC 7B13", Cores: 2}) {
+		t.Errorf("cpu = %+v", inv.CPU)
+	}
+	if inv.MemoryBytes != 16384000*1024 {
+		t.Errorf("memory = %d", inv.MemoryBytes)
+	}
+	if len(inv.Disks) != 1 || inv.Disks[0] != (frabit.DiskInfo{Name: "sda", SizeBytes: 1 << 30, Rotational: true}) {
+		t.Errorf("disks = %+v", inv.Disks)
+	}
+	want := []frabit.ListeningPort{
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 3306},
+		{Protocol: "tcp", Address: "127.0.0.1", Port: 5432},
+	}
+	if len(inv.ListeningPorts) != len(want) || inv.ListeningPorts[0] != want[0] || inv.ListeningPorts[1] != want[1] {
+		t.Errorf("ports = %+v", inv.ListeningPorts)
+	}
+	if inv.AgentVersion != frabit.Version || len(inv.Capabilities) != 1 {
+		t.Errorf("agent version = %q, capabilities = %v", inv.AgentVersion, inv.Capabilities)
+	}
+}
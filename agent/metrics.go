@@ -0,0 +1,362 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+const (
+	defaultCollectInterval = 15 * time.Second
+	defaultBatchSize       = 1000
+	defaultMaxPending      = 50000
+	defaultPushAttempts    = 3
+	defaultPushBackoff     = 500 * time.Millisecond
+	spoolFileSuffix        = ".json.gz"
+)
+
+// MetricsCollector produces one round of samples each time it is called.
+type MetricsCollector interface {
+	Name() string
+	Collect(ctx context.Context) ([]frabit.MetricSample, error)
+}
+
+type MetricsOption func(p *MetricsPipeline) error
+
+// MetricsPipeline periodically runs its collectors and pushes the samples to
+// Frabit in batches. Batches that can not be delivered are spooled to disk
+// and replayed once the API is reachable again.
+type MetricsPipeline struct {
+	client  *frabit.Client
+	agentID string
+
+	collectors   []MetricsCollector
+	interval     time.Duration
+	batchSize    int
+	maxPending   int
+	attempts     int
+	backoff      time.Duration
+	spoolDir     string
+	spoolMaxSize int64
+	onError      func(err error)
+
+	pending  []frabit.MetricSample
+	dropped  atomic.Int64
+	spoolSeq int
+}
+
+func WithCollectors(collectors ...MetricsCollector) MetricsOption {
+	return func(p *MetricsPipeline) error {
+		p.collectors = append(p.collectors, collectors...)
+		return nil
+	}
+}
+
+func WithCollectInterval(interval time.Duration) MetricsOption {
+	return func(p *MetricsPipeline) error {
+		if interval <= 0 {
+			return errors.New("agent: collect interval must be positive")
+		}
+		p.interval = interval
+		return nil
+	}
+}
+
+// WithBatchSize caps the number of samples sent in one request.
+func WithBatchSize(n int) MetricsOption {
+	return func(p *MetricsPipeline) error {
+		if n <= 0 {
+			return errors.New("agent: batch size must be positive")
+		}
+		p.batchSize = n
+		return nil
+	}
+}
+
+// WithMaxPending caps the samples held in memory while the API is down and no
+// spool is configured, or the spool is full. The oldest samples are dropped first.
+func WithMaxPending(n int) MetricsOption {
+	return func(p *MetricsPipeline) error {
+		p.maxPending = n
+		return nil
+	}
+}
+
+// WithPushRetry sets how often a batch is attempted and the initial backoff,
+// which doubles after every failed attempt.
+func WithPushRetry(attempts int, backoff time.Duration) MetricsOption {
+	return func(p *MetricsPipeline) error {
+		if attempts <= 0 {
+			return errors.New("agent: push attempts must be positive")
+		}
+		p.attempts = attempts
+		p.backoff = backoff
+		return nil
+	}
+}
+
+// WithSpool keeps undeliverable batches in dir, using at most maxBytes of disk.
+func WithSpool(dir string, maxBytes int64) MetricsOption {
+	return func(p *MetricsPipeline) error {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		p.spoolDir = dir
+		p.spoolMaxSize = maxBytes
+		return nil
+	}
+}
+
+func WithMetricsErrorHandler(fn func(err error)) MetricsOption {
+	return func(p *MetricsPipeline) error {
+		p.onError = fn
+		return nil
+	}
+}
+
+func NewMetricsPipeline(client *frabit.Client, agentID string, opts ...MetricsOption) (*MetricsPipeline, error) {
+	p := &MetricsPipeline{
+		client:     client,
+		agentID:    agentID,
+		interval:   defaultCollectInterval,
+		batchSize:  defaultBatchSize,
+		maxPending: defaultMaxPending,
+		attempts:   defaultPushAttempts,
+		backoff:    defaultPushBackoff,
+		onError:    func(error) {},
+	}
+
+	for _, opt := range opts {
+		err := opt(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Dropped returns how many samples were discarded because neither memory nor
+// spool had room for them, or because the API rejected them.
+func (p *MetricsPipeline) Dropped() int {
+	return int(p.dropped.Load())
+}
+
+// Run collects and pushes until ctx is cancelled. Pushing happens on the
+// collecting goroutine, so a slow API stretches the collection interval
+// instead of piling up samples.
+func (p *MetricsPipeline) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.collect(ctx)
+		p.flush(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *MetricsPipeline) collect(ctx context.Context) {
+	for _, c := range p.collectors {
+		samples, err := c.Collect(ctx)
+		if err != nil {
+			p.onError(fmt.Errorf("collector %s: %w", c.Name(), err))
+		}
+		p.pending = append(p.pending, samples...)
+	}
+}
+
+func (p *MetricsPipeline) flush(ctx context.Context) {
+	for len(p.pending) > 0 {
+		n := min(len(p.pending), p.batchSize)
+		batch := frabit.MetricBatch{AgentID: p.agentID, Samples: p.pending[:n]}
+
+		if err := p.push(ctx, batch); err != nil {
+			p.onError(fmt.Errorf("push metrics: %w", err))
+			if frabit.IsErrorCode(err, frabit.ErrInvalid) {
+				// the API will never take this batch, go on with the rest
+				p.dropped.Add(int64(n))
+				p.pending = p.pending[n:]
+				continue
+			}
+			p.spoolPending()
+			return
+		}
+		p.pending = p.pending[n:]
+	}
+	p.pending = nil
+
+	// the API is reachable again, catch up on what piled up while it was not
+	p.replaySpool(ctx)
+}
+
+func (p *MetricsPipeline) push(ctx context.Context, batch frabit.MetricBatch) error {
+	backoff := p.backoff
+	var err error
+	for attempt := 0; attempt < p.attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = p.client.Agent.PushMetrics(ctx, batch)
+		// a rejected batch will not get better by resending it
+		if err == nil || frabit.IsErrorCode(err, frabit.ErrInvalid) {
+			return err
+		}
+	}
+	return err
+}
+
+// spoolPending moves the undelivered samples to disk, or trims them to
+// maxPending in memory when there is no spool or it is full.
+func (p *MetricsPipeline) spoolPending() {
+	if p.spoolDir != "" {
+		for len(p.pending) > 0 {
+			n := min(len(p.pending), p.batchSize)
+			if err := p.writeSpool(p.pending[:n]); err != nil {
+				p.onError(fmt.Errorf("spool metrics: %w", err))
+				break
+			}
+			p.pending = p.pending[n:]
+		}
+	}
+	if over := len(p.pending) - p.maxPending; over > 0 {
+		p.dropped.Add(int64(over))
+		p.pending = p.pending[over:]
+	}
+}
+
+func (p *MetricsPipeline) writeSpool(samples []frabit.MetricSample) error {
+	if p.spoolMaxSize > 0 && p.spoolSize() >= p.spoolMaxSize {
+		return errors.New("spool is full")
+	}
+
+	p.spoolSeq++
+	name := filepath.Join(p.spoolDir, fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), p.spoolSeq%1000000, spoolFileSuffix))
+	f, err := os.CreateTemp(p.spoolDir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	zw := gzip.NewWriter(f)
+	err = json.NewEncoder(zw).Encode(samples)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// rename last so a crash never leaves a half written batch behind for replay
+	return os.Rename(f.Name(), name)
+}
+
+func (p *MetricsPipeline) spoolFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(p.spoolDir, "*"+spoolFileSuffix))
+	sort.Strings(files)
+	return files
+}
+
+func (p *MetricsPipeline) spoolSize() int64 {
+	var size int64
+	for _, f := range p.spoolFiles() {
+		if info, err := os.Stat(f); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// replaySpool sends spooled batches oldest first and stops at the first failure.
+func (p *MetricsPipeline) replaySpool(ctx context.Context) {
+	if p.spoolDir == "" {
+		return
+	}
+	for _, name := range p.spoolFiles() {
+		samples, err := readSpool(name)
+		if err != nil {
+			// unreadable batches are never going to be delivered
+			p.onError(fmt.Errorf("read spooled metrics %s: %w", filepath.Base(name), err))
+			os.Remove(name)
+			continue
+		}
+		err = p.client.Agent.PushMetrics(ctx, frabit.MetricBatch{AgentID: p.agentID, Samples: samples})
+		if err != nil && !frabit.IsErrorCode(err, frabit.ErrInvalid) {
+			return
+		}
+		if err != nil {
+			p.dropped.Add(int64(len(samples)))
+		}
+		os.Remove(name)
+	}
+}
+
+func readSpool(name string) ([]frabit.MetricSample, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var samples []frabit.MetricSample
+	if err := json.NewDecoder(zr).Decode(&samples); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// metricName builds a snake case metric name from a prefix and a status
+// variable name such as "Threads_connected".
+func metricName(prefix, name string) string {
+	return prefix + "_" + strings.ToLower(name)
+}
+
+func withLabels(base map[string]string, extra ...string) map[string]string {
+	labels := make(map[string]string, len(base)+len(extra)/2)
+	for k, v := range base {
+		labels[k] = v
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels[extra[i]] = extra[i+1]
+	}
+	return labels
+}
@@ -0,0 +1,50 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// HostCollector reports load, memory and CPU time of the host. On platforms
+// other than Linux only the Go runtime view is available.
+type HostCollector struct {
+	// ProcRoot defaults to /proc
+	ProcRoot string
+	Labels   map[string]string
+}
+
+func (c *HostCollector) Name() string { return "host" }
+
+func (c *HostCollector) Collect(ctx context.Context) ([]frabit.MetricSample, error) {
+	now := time.Now().UTC()
+	samples := []frabit.MetricSample{
+		{Name: "host_cpu_count", Labels: c.Labels, Value: float64(runtime.NumCPU()), Timestamp: now},
+	}
+	host, err := c.collectHost(now)
+	return append(samples, host...), err
+}
+
+func (c *HostCollector) procRoot() string {
+	if c.ProcRoot == "" {
+		return "/proc"
+	}
+	return c.ProcRoot
+}
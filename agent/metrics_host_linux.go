@@ -0,0 +1,132 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package agent
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// clockTicks is USER_HZ, which is 100 on every architecture Linux supports today.
+const clockTicks = 100
+
+var cpuModes = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+
+func (c *HostCollector) collectHost(now time.Time) ([]frabit.MetricSample, error) {
+	var samples []frabit.MetricSample
+	add := func(name string, value float64, extra ...string) {
+		labels := c.Labels
+		if len(extra) > 0 {
+			labels = withLabels(c.Labels, extra...)
+		}
+		samples = append(samples, frabit.MetricSample{Name: name, Labels: labels, Value: value, Timestamp: now})
+	}
+
+	var errs []error
+	if fields := strings.Fields(readTrimmed(filepath.Join(c.procRoot(), "loadavg"))); len(fields) >= 3 {
+		for i, name := range []string{"host_load1", "host_load5", "host_load15"} {
+			v, _ := strconv.ParseFloat(fields[i], 64)
+			add(name, v)
+		}
+	} else {
+		errs = append(errs, errors.New("loadavg unavailable"))
+	}
+
+	mem, err := readMeminfo(filepath.Join(c.procRoot(), "meminfo"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for key, name := range map[string]string{
+		"MemTotal":     "host_memory_total_bytes",
+		"MemAvailable": "host_memory_available_bytes",
+		"SwapTotal":    "host_swap_total_bytes",
+		"SwapFree":     "host_swap_free_bytes",
+	} {
+		if v, ok := mem[key]; ok {
+			add(name, float64(v))
+		}
+	}
+
+	cpu, err := readCPUTimes(filepath.Join(c.procRoot(), "stat"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for i, v := range cpu {
+		add("host_cpu_seconds_total", v, "mode", cpuModes[i])
+	}
+
+	return samples, errors.Join(errs...)
+}
+
+func readMeminfo(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mem := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) == 3 && fields[2] == "kB" {
+			v *= 1024
+		}
+		mem[strings.TrimSuffix(fields[0], ":")] = v
+	}
+	return mem, scanner.Err()
+}
+
+// readCPUTimes returns the aggregate CPU time per mode in seconds, in the
+// order of cpuModes.
+func readCPUTimes(path string) ([]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "cpu" {
+			continue
+		}
+		times := make([]float64, 0, len(cpuModes))
+		for _, f := range fields[1:min(len(fields), len(cpuModes)+1)] {
+			v, _ := strconv.ParseFloat(f, 64)
+			times = append(times, v/clockTicks)
+		}
+		return times, nil
+	}
+	return nil, errors.New("no aggregate cpu line in " + path)
+}
@@ -0,0 +1,264 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// MySQLCollector reads SHOW GLOBAL STATUS and the replication status of a
+// MySQL or MariaDB server. The caller opens DB with the driver of its choice.
+type MySQLCollector struct {
+	DB     *sql.DB
+	Labels map[string]string
+}
+
+func (c *MySQLCollector) Name() string { return "mysql" }
+
+func (c *MySQLCollector) Collect(ctx context.Context) ([]frabit.MetricSample, error) {
+	now := time.Now().UTC()
+	rows, err := c.DB.QueryContext(ctx, "SHOW GLOBAL STATUS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []frabit.MetricSample
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		// status variables such as Ssl_cipher are not numeric and are skipped
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, frabit.MetricSample{
+			Name:      metricName("mysql_global_status", name),
+			Labels:    c.Labels,
+			Value:     v,
+			Timestamp: now,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	replication, err := c.replication(ctx, now)
+	if err != nil {
+		return samples, err
+	}
+	return append(samples, replication...), nil
+}
+
+// replication reports lag and thread state of a replica. MySQL 8.0.22 renamed
+// the statement and its columns, both spellings are understood.
+func (c *MySQLCollector) replication(ctx context.Context, now time.Time) ([]frabit.MetricSample, error) {
+	rows, err := c.DB.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = c.DB.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+
+	status, err := scanRowMap(rows)
+	if err != nil || status == nil {
+		// not a replica
+		return nil, err
+	}
+
+	var samples []frabit.MetricSample
+	add := func(name string, value float64) {
+		samples = append(samples, frabit.MetricSample{Name: name, Labels: c.Labels, Value: value, Timestamp: now})
+	}
+	if lag, ok := firstOf(status, "Seconds_Behind_Source", "Seconds_Behind_Master"); ok {
+		// NULL lag means the SQL thread is not running, which the thread gauges report
+		if v, err := strconv.ParseFloat(lag, 64); err == nil {
+			add("mysql_replication_lag_seconds", v)
+		}
+	}
+	if io, ok := firstOf(status, "Replica_IO_Running", "Slave_IO_Running"); ok {
+		add("mysql_replication_io_running", boolGauge(io == "Yes"))
+	}
+	if sqlThread, ok := firstOf(status, "Replica_SQL_Running", "Slave_SQL_Running"); ok {
+		add("mysql_replication_sql_running", boolGauge(sqlThread == "Yes"))
+	}
+	return samples, nil
+}
+
+// PostgresCollector reads pg_stat_database and pg_stat_replication and, on a
+// standby, how far replay is behind. The caller opens DB with the driver of
+// its choice.
+type PostgresCollector struct {
+	DB     *sql.DB
+	Labels map[string]string
+}
+
+func (c *PostgresCollector) Name() string { return "postgresql" }
+
+var pgStatDatabaseColumns = []string{
+	"numbackends", "xact_commit", "xact_rollback", "blks_read", "blks_hit",
+	"tup_returned", "tup_fetched", "tup_inserted", "tup_updated", "tup_deleted",
+	"conflicts", "deadlocks", "temp_bytes",
+}
+
+func (c *PostgresCollector) Collect(ctx context.Context) ([]frabit.MetricSample, error) {
+	now := time.Now().UTC()
+	query := "SELECT datname"
+	for _, col := range pgStatDatabaseColumns {
+		query += ", COALESCE(" + col + ", 0)::float8"
+	}
+	query += " FROM pg_stat_database WHERE datname IS NOT NULL"
+
+	rows, err := c.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []frabit.MetricSample
+	values := make([]float64, len(pgStatDatabaseColumns))
+	dest := make([]any, len(values)+1)
+	var datname string
+	dest[0] = &datname
+	for i := range values {
+		dest[i+1] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		labels := withLabels(c.Labels, "datname", datname)
+		for i, col := range pgStatDatabaseColumns {
+			samples = append(samples, frabit.MetricSample{
+				Name:      metricName("pg_stat_database", col),
+				Labels:    labels,
+				Value:     values[i],
+				Timestamp: now,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	replication, err := c.replication(ctx, now)
+	if err != nil {
+		return samples, err
+	}
+	return append(samples, replication...), nil
+}
+
+func (c *PostgresCollector) replication(ctx context.Context, now time.Time) ([]frabit.MetricSample, error) {
+	var standby bool
+	if err := c.DB.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&standby); err != nil {
+		return nil, err
+	}
+	samples := []frabit.MetricSample{
+		{Name: "pg_replication_is_replica", Labels: c.Labels, Value: boolGauge(standby), Timestamp: now},
+	}
+	// pg_current_wal_lsn fails during recovery; a standby is as far as the
+	// WAL it has received, which is also what its own replicas stream
+	current := "pg_current_wal_lsn()"
+	if standby {
+		current = "pg_last_wal_receive_lsn()"
+		var lagSeconds, lagBytes float64
+		err := c.DB.QueryRowContext(ctx, `SELECT
+			COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)::float8,
+			COALESCE(pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn()), 0)::float8`).Scan(&lagSeconds, &lagBytes)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples,
+			frabit.MetricSample{Name: "pg_replication_lag_seconds", Labels: c.Labels, Value: lagSeconds, Timestamp: now},
+			frabit.MetricSample{Name: "pg_replication_replay_lag_bytes", Labels: c.Labels, Value: lagBytes, Timestamp: now},
+		)
+	}
+
+	rows, err := c.DB.QueryContext(ctx, `SELECT application_name, COALESCE(client_addr::text, ''),
+		COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)::float8,
+		COALESCE(pg_wal_lsn_diff(`+current+`, replay_lsn), 0)::float8
+		FROM pg_stat_replication`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var app, addr string
+		var lagSeconds, lagBytes float64
+		if err := rows.Scan(&app, &addr, &lagSeconds, &lagBytes); err != nil {
+			return nil, err
+		}
+		labels := withLabels(c.Labels, "application_name", app, "client_addr", addr)
+		samples = append(samples,
+			frabit.MetricSample{Name: "pg_stat_replication_replay_lag_seconds", Labels: labels, Value: lagSeconds, Timestamp: now},
+			frabit.MetricSample{Name: "pg_stat_replication_replay_lag_bytes", Labels: labels, Value: lagBytes, Timestamp: now},
+		)
+	}
+	return samples, rows.Err()
+}
+
+// scanRowMap reads the first row of a result with unknown columns, returning
+// nil when the result is empty.
+func scanRowMap(rows *sql.Rows) (map[string]string, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	raw := make([]sql.RawBytes, len(cols))
+	dest := make([]any, len(cols))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(cols))
+	for i, col := range cols {
+		if raw[i] != nil {
+			m[col] = string(raw[i])
+		}
+	}
+	return m, nil
+}
+
+func firstOf(m map[string]string, keys ...string) (string, bool) {
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
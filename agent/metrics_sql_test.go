@@ -0,0 +1,140 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakePostgres answers the queries of PostgresCollector like a primary or,
+// in recovery, a standby.
+type fakePostgres struct {
+	recovery bool
+}
+
+func (f *fakePostgres) query(q string) ([]string, [][]driver.Value, error) {
+	switch {
+	case f.recovery && strings.Contains(q, "pg_current_wal_lsn()"):
+		return nil, nil, errors.New("ERROR: recovery is in progress")
+	case strings.Contains(q, "pg_stat_database"):
+		cols := append([]string{"datname"}, pgStatDatabaseColumns...)
+		row := []driver.Value{"app"}
+		for range pgStatDatabaseColumns {
+			row = append(row, 1.0)
+		}
+		return cols, [][]driver.Value{row}, nil
+	case q == "SELECT pg_is_in_recovery()":
+		return []string{"pg_is_in_recovery"}, [][]driver.Value{{f.recovery}}, nil
+	case strings.Contains(q, "pg_last_xact_replay_timestamp()"):
+		return []string{"lag_seconds", "lag_bytes"}, [][]driver.Value{{2.5, 1024.0}}, nil
+	case strings.Contains(q, "pg_stat_replication"):
+		cols := []string{"application_name", "client_addr", "replay_lag", "lag_bytes"}
+		if f.recovery {
+			return cols, nil, nil
+		}
+		return cols, [][]driver.Value{{"standby1", "10.0.0.2", 0.5, 2048.0}}, nil
+	}
+	return nil, nil, errors.New("unexpected query: " + q)
+}
+
+func (f *fakePostgres) Connect(context.Context) (driver.Conn, error) { return f, nil }
+func (f *fakePostgres) Driver() driver.Driver                        { return nil }
+func (f *fakePostgres) Prepare(q string) (driver.Stmt, error)        { return fakeStmt{f, q}, nil }
+func (f *fakePostgres) Close() error                                 { return nil }
+func (f *fakePostgres) Begin() (driver.Tx, error)                    { return nil, errors.New("no transactions") }
+
+type fakeStmt struct {
+	db    *fakePostgres
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return 0 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("read only")
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	cols, rows, err := s.db.query(strings.Join(strings.Fields(s.query), " "))
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{cols: cols, rows: rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestPostgresCollectorReplication(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		recovery bool
+		want     map[string]float64
+	}{
+		{"primary", false, map[string]float64{
+			"pg_replication_is_replica":              0,
+			"pg_stat_replication_replay_lag_seconds": 0.5,
+			"pg_stat_replication_replay_lag_bytes":   2048,
+		}},
+		{"standby", true, map[string]float64{
+			"pg_replication_is_replica":       1,
+			"pg_replication_lag_seconds":      2.5,
+			"pg_replication_replay_lag_bytes": 1024,
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sql.OpenDB(&fakePostgres{recovery: tt.recovery})
+			defer db.Close()
+			samples, err := (&PostgresCollector{DB: db}).Collect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]float64)
+			for _, s := range samples {
+				if strings.HasPrefix(s.Name, "pg_replication_") || strings.HasPrefix(s.Name, "pg_stat_replication_") {
+					got[s.Name] = s.Value
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("replication samples = %v", got)
+			}
+			for name, v := range tt.want {
+				if got[name] != v {
+					t.Errorf("%s = %v, want %v", name, got[name], v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+type staticCollector []frabit.MetricSample
+
+func (c staticCollector) Name() string { return "static" }
+
+func (c staticCollector) Collect(context.Context) ([]frabit.MetricSample, error) { return c, nil }
+
+type fakeMetricsAPI struct {
+	mu       sync.Mutex
+	down     bool
+	received []frabit.MetricSample
+	// reject answers 400 to batches with a sample of this name
+	reject string
+}
+
+func (f *fakeMetricsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var batch frabit.MetricBatch
+	if err := json.NewDecoder(zr).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, sample := range batch.Samples {
+		if f.reject != "" && sample.Name == f.reject {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	f.received = append(f.received, batch.Samples...)
+}
+
+func TestMetricsPipelineSpoolsWhileAPIDown(t *testing.T) {
+	api := &fakeMetricsAPI{down: true}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sample := frabit.MetricSample{Name: "mysql_global_status_threads_connected", Value: 12, Timestamp: time.Now()}
+	spool := t.TempDir()
+	p, err := NewMetricsPipeline(client, "agent-1",
+		WithCollectors(staticCollector{sample, sample, sample}),
+		WithBatchSize(2),
+		WithPushRetry(1, 0),
+		WithSpool(spool, 1<<20),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	p.collect(ctx)
+	p.flush(ctx)
+	if n := len(p.spoolFiles()); n != 2 {
+		t.Fatalf("spooled %d batches, want 2", n)
+	}
+
+	api.mu.Lock()
+	api.down = false
+	api.mu.Unlock()
+
+	p.collect(ctx)
+	p.flush(ctx)
+	if n := len(p.spoolFiles()); n != 0 {
+		t.Fatalf("%d batches left in spool after recovery", n)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.received) != 6 {
+		t.Errorf("received %d samples, want 6", len(api.received))
+	}
+}
+
+func TestMetricsPipelineDropsOldestWithoutSpool(t *testing.T) {
+	srv := httptest.NewServer(&fakeMetricsAPI{down: true})
+	defer srv.Close()
+	client, _ := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+
+	p, err := NewMetricsPipeline(client, "agent-1",
+		WithCollectors(staticCollector{{Name: "a"}, {Name: "b"}, {Name: "c"}}),
+		WithPushRetry(1, 0),
+		WithMaxPending(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.collect(context.Background())
+	p.flush(context.Background())
+
+	if p.Dropped() != 1 || len(p.pending) != 2 || p.pending[0].Name != "b" {
+		t.Errorf("dropped = %d, pending = %+v", p.Dropped(), p.pending)
+	}
+}
+
+func TestMetricsPipelineDropsRejectedBatch(t *testing.T) {
+	api := &fakeMetricsAPI{reject: "bad"}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	client, _ := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+
+	spool := t.TempDir()
+	p, err := NewMetricsPipeline(client, "agent-1",
+		WithCollectors(staticCollector{{Name: "a"}, {Name: "bad"}, {Name: "c"}}),
+		WithBatchSize(1),
+		WithPushRetry(1, 0),
+		WithSpool(spool, 1<<20),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.collect(context.Background())
+	p.flush(context.Background())
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.received) != 2 || api.received[1].Name != "c" {
+		t.Errorf("received = %+v", api.received)
+	}
+	if p.Dropped() != 1 || len(p.pending) != 0 || len(p.spoolFiles()) != 0 {
+		t.Errorf("dropped = %d, pending = %d, spooled = %d", p.Dropped(), len(p.pending), len(p.spoolFiles()))
+	}
+}
@@ -0,0 +1,332 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agent provides the runtime pieces shared by Frabit agents: the
+// registration and heartbeat loop and the plumbing built on top of it.
+package agent
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+const (
+	defaultInterval          = 10 * time.Second
+	defaultJitter            = 0.1
+	defaultDeregisterTimeout = 5 * time.Second
+)
+
+// ErrUnreachable is returned by a HealthCheck when the resource it guards
+// can not be reached at all, as opposed to being reachable but unhealthy.
+var ErrUnreachable = errors.New("agent: target unreachable")
+
+// HealthCheck probes one local resource. A nil error means healthy, an error
+// wrapping ErrUnreachable or context.DeadlineExceeded marks the agent
+// UnReachable, any other error marks it Failed.
+type HealthCheck func(ctx context.Context) error
+
+type RunnerOption func(r *Runner) error
+
+// Runner registers an agent with Frabit and keeps it alive with periodic
+// heartbeats until its context is cancelled.
+type Runner struct {
+	client *frabit.Client
+
+	agentID  string
+	name     string
+	clientIP string
+
+	interval          time.Duration
+	jitter            float64
+	checkTimeout      time.Duration
+	deregisterTimeout time.Duration
+	checks            map[string]HealthCheck
+	onError           func(err error)
+
+	inventory        *InventoryCollector
+	inventoryRefresh time.Duration
+	inventorySentAt  time.Time
+
+	mu         sync.RWMutex
+	status     frabit.AgentStatus
+	registered bool
+}
+
+func WithAgentID(agentID string) RunnerOption {
+	return func(r *Runner) error {
+		r.agentID = agentID
+		return nil
+	}
+}
+
+func WithName(name string) RunnerOption {
+	return func(r *Runner) error {
+		r.name = name
+		return nil
+	}
+}
+
+func WithClientIP(ip string) RunnerOption {
+	return func(r *Runner) error {
+		r.clientIP = ip
+		return nil
+	}
+}
+
+// WithInterval sets the base delay between two heartbeats.
+func WithInterval(interval time.Duration) RunnerOption {
+	return func(r *Runner) error {
+		if interval <= 0 {
+			return errors.New("agent: heartbeat interval must be positive")
+		}
+		r.interval = interval
+		return nil
+	}
+}
+
+// WithJitter spreads heartbeats by up to the given fraction of the interval
+// in either direction, so a fleet restarted together does not beat in lockstep.
+func WithJitter(fraction float64) RunnerOption {
+	return func(r *Runner) error {
+		if fraction < 0 || fraction >= 1 {
+			return errors.New("agent: jitter must be in [0, 1)")
+		}
+		r.jitter = fraction
+		return nil
+	}
+}
+
+// WithHealthCheck adds a named local health check evaluated before every heartbeat.
+func WithHealthCheck(name string, check HealthCheck) RunnerOption {
+	return func(r *Runner) error {
+		r.checks[name] = check
+		return nil
+	}
+}
+
+// WithInventory attaches host facts to the registration and reports a fresh
+// snapshot every refresh interval.
+func WithInventory(collector *InventoryCollector, refresh time.Duration) RunnerOption {
+	return func(r *Runner) error {
+		r.inventory = collector
+		r.inventoryRefresh = refresh
+		return nil
+	}
+}
+
+// WithCheckTimeout bounds how long a single health check may run.
+func WithCheckTimeout(timeout time.Duration) RunnerOption {
+	return func(r *Runner) error {
+		r.checkTimeout = timeout
+		return nil
+	}
+}
+
+// WithErrorHandler receives errors the loop recovers from on its own, such as
+// a failed heartbeat. By default they are dropped.
+func WithErrorHandler(fn func(err error)) RunnerOption {
+	return func(r *Runner) error {
+		r.onError = fn
+		return nil
+	}
+}
+
+func NewRunner(client *frabit.Client, opts ...RunnerOption) (*Runner, error) {
+	r := &Runner{
+		client:            client,
+		interval:          defaultInterval,
+		jitter:            defaultJitter,
+		deregisterTimeout: defaultDeregisterTimeout,
+		checks:            make(map[string]HealthCheck),
+		onError:           func(error) {},
+		status:            frabit.Active,
+	}
+
+	for _, opt := range opts {
+		err := opt(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.agentID == "" {
+		return nil, errors.New("agent: agent id is required")
+	}
+	if r.checkTimeout == 0 {
+		r.checkTimeout = r.interval / 2
+	}
+
+	return r, nil
+}
+
+// Status returns the status reported with the most recent heartbeat.
+func (r *Runner) Status() frabit.AgentStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status
+}
+
+// AgentID returns the identifier the runner registers with.
+func (r *Runner) AgentID() string {
+	return r.agentID
+}
+
+// Run registers the agent and sends heartbeats until ctx is cancelled, then
+// deregisters it. Transient API failures are reported to the error handler and
+// retried on the next tick rather than ending the loop.
+func (r *Runner) Run(ctx context.Context) error {
+	r.tick(ctx)
+
+	timer := time.NewTimer(r.nextDelay())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return r.shutdown(ctx)
+		case <-timer.C:
+			r.tick(ctx)
+			timer.Reset(r.nextDelay())
+		}
+	}
+}
+
+func (r *Runner) tick(ctx context.Context) {
+	status := r.evaluate(ctx)
+	r.mu.Lock()
+	r.status = status
+	registered := r.registered
+	r.mu.Unlock()
+
+	if !registered {
+		if err := r.register(ctx, status); err != nil {
+			r.onError(err)
+			return
+		}
+	}
+
+	err := r.client.Agent.Heartbeat(ctx, frabit.CreateHeartbeat{AgentID: r.agentID, Status: status})
+	if err == nil {
+		r.refreshInventory(ctx)
+		return
+	}
+	if !frabit.IsErrorCode(err, frabit.ErrNotFound) {
+		r.onError(err)
+		return
+	}
+
+	// the server no longer knows this agent, most likely it was evicted after
+	// missing heartbeats; register again and resume beating on the next tick
+	r.setRegistered(false)
+	if err := r.register(ctx, status); err != nil {
+		r.onError(err)
+	}
+}
+
+func (r *Runner) register(ctx context.Context, status frabit.AgentStatus) error {
+	req := frabit.CreateAgentRequest{
+		AgentID:  r.agentID,
+		Name:     r.name,
+		Status:   string(status),
+		ClientIP: r.clientIP,
+	}
+	if r.inventory != nil {
+		inv, err := r.inventory.Collect(ctx)
+		if err != nil {
+			r.onError(err)
+		}
+		req.Inventory = inv
+	}
+
+	err := r.client.Agent.Register(ctx, req)
+	if err != nil {
+		return err
+	}
+	if req.Inventory != nil {
+		r.inventorySentAt = time.Now()
+	}
+	r.setRegistered(true)
+	return nil
+}
+
+func (r *Runner) refreshInventory(ctx context.Context) {
+	if r.inventory == nil || r.inventoryRefresh <= 0 || time.Since(r.inventorySentAt) < r.inventoryRefresh {
+		return
+	}
+	inv, err := r.inventory.Collect(ctx)
+	if err != nil {
+		r.onError(err)
+		return
+	}
+	if err := r.client.Agent.ReportInventory(ctx, r.agentID, *inv); err != nil {
+		r.onError(err)
+		return
+	}
+	r.inventorySentAt = time.Now()
+}
+
+func (r *Runner) setRegistered(registered bool) {
+	r.mu.Lock()
+	r.registered = registered
+	r.mu.Unlock()
+}
+
+// evaluate runs every health check and folds the results into one status.
+// UnReachable wins over Failed, which wins over Active.
+func (r *Runner) evaluate(ctx context.Context) frabit.AgentStatus {
+	status := frabit.Active
+	for _, check := range r.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, r.checkTimeout)
+		err := check(checkCtx)
+		cancel()
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrUnreachable), errors.Is(err, context.DeadlineExceeded):
+			return frabit.UnReachable
+		default:
+			status = frabit.Failed
+		}
+	}
+	return status
+}
+
+func (r *Runner) nextDelay() time.Duration {
+	if r.jitter == 0 {
+		return r.interval
+	}
+	spread := float64(r.interval) * r.jitter
+	return r.interval + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+func (r *Runner) shutdown(ctx context.Context) error {
+	r.mu.RLock()
+	registered := r.registered
+	r.mu.RUnlock()
+	if !registered {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.deregisterTimeout)
+	defer cancel()
+	if err := r.client.Agent.Deregister(ctx, r.agentID); err != nil {
+		return err
+	}
+	r.setRegistered(false)
+	return nil
+}
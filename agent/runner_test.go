@@ -0,0 +1,126 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+type fakeAgentAPI struct {
+	mu         sync.Mutex
+	registers  int
+	heartbeats []frabit.AgentStatus
+	deregister int
+	evictOnce  bool
+}
+
+func (f *fakeAgentAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/agents":
+		f.registers++
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/agents/heartbeat":
+		if f.evictOnce {
+			f.evictOnce = false
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var hb frabit.CreateHeartbeat
+		_ = json.NewDecoder(r.Body).Decode(&hb)
+		f.heartbeats = append(f.heartbeats, hb.Status)
+	case r.Method == http.MethodDelete && r.URL.Path == "/api/v2/agents/agent-1":
+		f.deregister++
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestRunner(t *testing.T, api http.Handler, opts ...RunnerOption) *Runner {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]RunnerOption{WithAgentID("agent-1"), WithInterval(10 * time.Millisecond), WithJitter(0)}, opts...)
+	r, err := NewRunner(client, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRunnerLifecycle(t *testing.T) {
+	api := &fakeAgentAPI{evictOnce: true}
+	r := newTestRunner(t, api)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := r.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.registers != 2 {
+		t.Errorf("registers = %d, want 2 (initial and after eviction)", api.registers)
+	}
+	if len(api.heartbeats) == 0 {
+		t.Error("no heartbeats sent")
+	}
+	if api.deregister != 1 {
+		t.Errorf("deregister = %d, want 1", api.deregister)
+	}
+}
+
+func TestRunnerHealthStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want frabit.AgentStatus
+	}{
+		{"healthy", nil, frabit.Active},
+		{"failed", errors.New("replication stopped"), frabit.Failed},
+		{"unreachable", ErrUnreachable, frabit.UnReachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAgentAPI{}
+			r := newTestRunner(t, api, WithHealthCheck("db", func(context.Context) error { return tt.err }))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+			defer cancel()
+			if err := r.Run(ctx); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := r.Status(); got != tt.want {
+				t.Errorf("Status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+const (
+	defaultLeaseDuration = 60 * time.Second
+	defaultPollWait      = 30 * time.Second
+	defaultPollBackoff   = 5 * time.Second
+	maxBufferedLogLines  = 100
+)
+
+// Reporter streams the state of a running task back to Frabit.
+type Reporter interface {
+	// Progress reports completion in percent together with a short status message.
+	Progress(ctx context.Context, percent float64, message string) error
+	// Logf appends a log line to the task. Lines are buffered and shipped in batches.
+	Logf(format string, args ...any)
+}
+
+// TaskHandler executes one type of task. The returned value is JSON encoded
+// and submitted as the task output; a returned error fails the task.
+type TaskHandler interface {
+	Handle(ctx context.Context, task frabit.Task, reporter Reporter) (any, error)
+}
+
+type TaskHandlerFunc func(ctx context.Context, task frabit.Task, reporter Reporter) (any, error)
+
+func (f TaskHandlerFunc) Handle(ctx context.Context, task frabit.Task, reporter Reporter) (any, error) {
+	return f(ctx, task, reporter)
+}
+
+// Registry maps task types to the handlers able to execute them.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[frabit.TaskType]TaskHandler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[frabit.TaskType]TaskHandler)}
+}
+
+func (r *Registry) Register(taskType frabit.TaskType, handler TaskHandler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.handlers[taskType]; ok {
+		return fmt.Errorf("agent: handler for task type %q already registered", taskType)
+	}
+	r.handlers[taskType] = handler
+	return nil
+}
+
+func (r *Registry) Lookup(taskType frabit.TaskType) (TaskHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[taskType]
+	return h, ok
+}
+
+// Types returns the registered task types in a stable order.
+func (r *Registry) Types() []frabit.TaskType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]frabit.TaskType, 0, len(r.handlers))
+	for t := range r.handlers {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+type DispatcherOption func(d *Dispatcher) error
+
+// Dispatcher long-polls Frabit for tasks assigned to an agent, claims the ones
+// it has a handler for and runs them while holding their lease.
+type Dispatcher struct {
+	client   *frabit.Client
+	agentID  string
+	registry *Registry
+
+	concurrency   int
+	leaseDuration time.Duration
+	pollWait      time.Duration
+	pollBackoff   time.Duration
+	onError       func(err error)
+}
+
+// WithConcurrency limits how many tasks run at the same time.
+func WithConcurrency(n int) DispatcherOption {
+	return func(d *Dispatcher) error {
+		if n <= 0 {
+			return errors.New("agent: concurrency must be positive")
+		}
+		d.concurrency = n
+		return nil
+	}
+}
+
+// WithLeaseDuration sets the lease requested on claim; it is renewed at a third of its length.
+func WithLeaseDuration(lease time.Duration) DispatcherOption {
+	return func(d *Dispatcher) error {
+		if lease < 3*time.Second {
+			return errors.New("agent: lease duration must be at least 3s")
+		}
+		d.leaseDuration = lease
+		return nil
+	}
+}
+
+// WithPollWait sets how long the server may hold a poll open when it has no
+// work. It is cut to half the client's timeout, if that is shorter.
+func WithPollWait(wait time.Duration) DispatcherOption {
+	return func(d *Dispatcher) error {
+		d.pollWait = wait
+		return nil
+	}
+}
+
+// WithDispatchErrorHandler receives errors the dispatcher recovers from, such
+// as failed polls or lost leases. By default they are dropped.
+func WithDispatchErrorHandler(fn func(err error)) DispatcherOption {
+	return func(d *Dispatcher) error {
+		d.onError = fn
+		return nil
+	}
+}
+
+func NewDispatcher(client *frabit.Client, agentID string, registry *Registry, opts ...DispatcherOption) (*Dispatcher, error) {
+	d := &Dispatcher{
+		client:        client,
+		agentID:       agentID,
+		registry:      registry,
+		concurrency:   1,
+		leaseDuration: defaultLeaseDuration,
+		pollWait:      defaultPollWait,
+		pollBackoff:   defaultPollBackoff,
+		onError:       func(error) {},
+	}
+
+	for _, opt := range opts {
+		err := opt(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// Run polls and executes tasks until ctx is cancelled, then waits for running
+// tasks to observe the cancellation and report back.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	slots := make(chan struct{}, d.concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		// hand the slot back right away, it is only taken to wait for capacity
+		<-slots
+
+		wait := d.pollWait
+		// the client's timeout also covers the time the server holds the poll
+		if timeout := d.client.HTTPClient().Timeout; timeout != 0 && wait > timeout/2 {
+			wait = timeout / 2
+		}
+		started := time.Now()
+		tasks, err := d.client.Agent.PollTasks(ctx, frabit.PollTasksRequest{
+			AgentID: d.agentID,
+			Types:   d.registry.Types(),
+			Wait:    wait,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			d.onError(fmt.Errorf("poll tasks: %w", err))
+			select {
+			case <-time.After(d.pollBackoff):
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+		// a server that answers an empty poll at once, without holding it
+		// open, must not be polled again right away
+		if elapsed := time.Since(started); len(tasks) == 0 && (elapsed < wait || elapsed < d.pollBackoff) {
+			select {
+			case <-time.After(d.pollBackoff/2 + rand.N(d.pollBackoff/2+1)):
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+
+		for _, task := range tasks {
+			handler, ok := d.registry.Lookup(task.Type)
+			if !ok {
+				continue
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+
+			lease, err := d.client.Agent.ClaimTask(ctx, frabit.ClaimTaskRequest{
+				AgentID:      d.agentID,
+				TaskID:       task.TaskID,
+				LeaseSeconds: int(d.leaseDuration / time.Second),
+			})
+			if err != nil {
+				<-slots
+				// another agent got there first
+				if !frabit.IsErrorCode(err, frabit.ErrConflict) {
+					d.onError(fmt.Errorf("claim task %s: %w", task.TaskID, err))
+				}
+				continue
+			}
+
+			wg.Add(1)
+			go func(task frabit.Task, lease *frabit.TaskLease) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				d.execute(ctx, task, lease, handler)
+			}(task, lease)
+		}
+	}
+}
+
+func (d *Dispatcher) execute(ctx context.Context, task frabit.Task, lease *frabit.TaskLease, handler TaskHandler) {
+	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reporter := &taskReporter{client: d.client, agentID: d.agentID, taskID: task.TaskID, leaseID: lease.LeaseID}
+
+	done := make(chan struct{})
+	go d.keepLease(taskCtx, cancel, reporter, done)
+
+	output, err := d.runHandler(taskCtx, task, reporter, handler)
+	close(done)
+
+	// the final report must reach the server even when the agent is shutting down
+	reportCtx, reportCancel := context.WithTimeout(context.WithoutCancel(ctx), defaultDeregisterTimeout)
+	defer reportCancel()
+	if ferr := reporter.flush(reportCtx); ferr != nil {
+		d.onError(fmt.Errorf("ship logs of task %s: %w", task.TaskID, ferr))
+	}
+
+	result := frabit.TaskResult{
+		AgentID: d.agentID,
+		TaskID:  task.TaskID,
+		LeaseID: lease.LeaseID,
+		State:   frabit.TaskSucceeded,
+	}
+	if err == nil && output != nil {
+		result.Output, err = json.Marshal(output)
+	}
+	if err != nil {
+		result.State = frabit.TaskFailed
+		result.Error = err.Error()
+	}
+	if err := d.client.Agent.SubmitResult(reportCtx, result); err != nil {
+		d.onError(fmt.Errorf("submit result of task %s: %w", task.TaskID, err))
+	}
+}
+
+func (d *Dispatcher) runHandler(ctx context.Context, task frabit.Task, reporter Reporter, handler TaskHandler) (output any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task handler panicked: %v", r)
+		}
+	}()
+	return handler.Handle(ctx, task, reporter)
+}
+
+// keepLease renews the task lease until the handler returns. Losing the lease
+// cancels the handler since the server may already have handed the task to
+// another agent.
+func (d *Dispatcher) keepLease(ctx context.Context, cancel context.CancelFunc, reporter *taskReporter, done <-chan struct{}) {
+	ticker := time.NewTicker(d.leaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := d.client.Agent.ExtendLease(ctx, frabit.ExtendLeaseRequest{
+				AgentID:      d.agentID,
+				TaskID:       reporter.taskID,
+				LeaseID:      reporter.leaseID,
+				LeaseSeconds: int(d.leaseDuration / time.Second),
+			})
+			if frabit.IsErrorCode(err, frabit.ErrNotFound) || frabit.IsErrorCode(err, frabit.ErrConflict) {
+				d.onError(fmt.Errorf("lease of task %s lost: %w", reporter.taskID, err))
+				cancel()
+				return
+			}
+			if err != nil {
+				d.onError(fmt.Errorf("extend lease of task %s: %w", reporter.taskID, err))
+			}
+			if err := reporter.flush(ctx); err != nil {
+				d.onError(fmt.Errorf("ship logs of task %s: %w", reporter.taskID, err))
+			}
+		}
+	}
+}
+
+type taskReporter struct {
+	client  *frabit.Client
+	agentID string
+	taskID  string
+	leaseID string
+
+	mu    sync.Mutex
+	lines []string
+	// flushMu keeps the periodic and the final flush from shipping the same batch
+	flushMu sync.Mutex
+}
+
+func (r *taskReporter) Progress(ctx context.Context, percent float64, message string) error {
+	return r.client.Agent.ReportProgress(ctx, frabit.TaskProgress{
+		AgentID: r.agentID,
+		TaskID:  r.taskID,
+		LeaseID: r.leaseID,
+		Percent: percent,
+		Message: message,
+	})
+}
+
+func (r *taskReporter) Logf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+	// keep memory bounded when the server is not reachable for a while
+	if len(r.lines) > maxBufferedLogLines*10 {
+		r.lines = r.lines[len(r.lines)-maxBufferedLogLines*10:]
+	}
+}
+
+func (r *taskReporter) flush(ctx context.Context) error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+	for {
+		r.mu.Lock()
+		n := min(len(r.lines), maxBufferedLogLines)
+		if n == 0 {
+			r.mu.Unlock()
+			return nil
+		}
+		batch := append([]string(nil), r.lines[:n]...)
+		r.mu.Unlock()
+
+		err := r.client.Agent.AppendLogs(ctx, frabit.TaskLogs{
+			AgentID: r.agentID,
+			TaskID:  r.taskID,
+			LeaseID: r.leaseID,
+			Lines:   batch,
+		})
+		if err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		r.lines = r.lines[min(n, len(r.lines)):]
+		r.mu.Unlock()
+	}
+}
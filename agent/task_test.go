@@ -0,0 +1,179 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+type fakeTaskAPI struct {
+	mu      sync.Mutex
+	pending []frabit.Task
+	polls   int
+	wait    string
+	logs    []string
+	results map[string]frabit.TaskResult
+}
+
+func (f *fakeTaskAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v2/agents/agent-1/tasks"), "/")
+	switch {
+	case len(parts) == 1:
+		f.polls++
+		f.wait = r.URL.Query().Get("wait")
+		_ = json.NewEncoder(w).Encode(f.pending)
+		f.pending = nil
+	case parts[2] == "claim":
+		_ = json.NewEncoder(w).Encode(frabit.TaskLease{TaskID: parts[1], LeaseID: "lease-" + parts[1]})
+	case parts[2] == "logs":
+		var req frabit.TaskLogs
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.logs = append(f.logs, req.Lines...)
+	case parts[2] == "result":
+		var req frabit.TaskResult
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.results[parts[1]] = req
+	}
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	reg := NewRegistry()
+	noop := TaskHandlerFunc(func(context.Context, frabit.Task, Reporter) (any, error) { return nil, nil })
+	if err := reg.Register(frabit.TaskBackup, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register(frabit.TaskBackup, noop); err == nil {
+		t.Fatal("expected duplicate registration to fail")
+	}
+}
+
+func TestDispatcherRunsClaimedTasks(t *testing.T) {
+	api := &fakeTaskAPI{
+		pending: []frabit.Task{
+			{TaskID: "t1", Type: frabit.TaskHealthProbe},
+			{TaskID: "t2", Type: frabit.TaskSQLExecute},
+			{TaskID: "t3", Type: frabit.TaskRestore},
+		},
+		results: make(map[string]frabit.TaskResult),
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewRegistry()
+	_ = reg.Register(frabit.TaskHealthProbe, TaskHandlerFunc(func(ctx context.Context, task frabit.Task, r Reporter) (any, error) {
+		r.Logf("probing %s", task.TaskID)
+		return map[string]bool{"healthy": true}, nil
+	}))
+	_ = reg.Register(frabit.TaskSQLExecute, TaskHandlerFunc(func(context.Context, frabit.Task, Reporter) (any, error) {
+		return nil, errors.New("syntax error")
+	}))
+
+	d, err := NewDispatcher(client, "agent-1", reg, WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.pollBackoff = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if got := api.results["t1"]; got.State != frabit.TaskSucceeded || string(got.Output) != `{"healthy":true}` {
+		t.Errorf("t1 result = %+v", got)
+	}
+	if got := api.results["t2"]; got.State != frabit.TaskFailed || got.Error != "syntax error" {
+		t.Errorf("t2 result = %+v", got)
+	}
+	if _, ok := api.results["t3"]; ok {
+		t.Error("t3 has no handler and must not be claimed")
+	}
+	if len(api.logs) != 1 || api.logs[0] != "probing t1" {
+		t.Errorf("logs = %q", api.logs)
+	}
+}
+
+func TestDispatcherPacesEmptyPolls(t *testing.T) {
+	// the fake answers polls at once instead of holding them open
+	api := &fakeTaskAPI{results: make(map[string]frabit.TaskResult)}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDispatcher(client, "agent-1", NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.pollBackoff = 20 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := d.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	// at most one poll every 10ms, the shortest jittered pause
+	if api.polls == 0 || api.polls > 11 {
+		t.Errorf("%d polls in 100ms", api.polls)
+	}
+}
+
+func TestDispatcherCapsPollWait(t *testing.T) {
+	api := &fakeTaskAPI{results: make(map[string]frabit.TaskResult)}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL), frabit.WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDispatcher(client, "agent-1", NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	// the server may not hold the poll open past the client's timeout
+	if api.wait != "500ms" {
+		t.Errorf("wait = %q", api.wait)
+	}
+}
@@ -0,0 +1,229 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/agent/artifact"
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// VerifyTaskParams are the params of backup verification tasks.
+type VerifyTaskParams struct {
+	BackupTaskParams
+	VerificationID string            `json:"verification_id"`
+	BackupID       string            `json:"backup_id"`
+	Mode           frabit.VerifyMode `json:"mode"`
+	// Checksum is the checksum recorded when the backup was taken.
+	Checksum string `json:"checksum"`
+	// Scratch is the throwaway instance a test restore goes to. It must
+	// never point at a production instance.
+	Scratch plugin.BackupTarget `json:"scratch"`
+}
+
+// VerifyTaskHandler verifies a stored backup in a single pass over the
+// artifact: the checksum is computed while the artifact is decrypted,
+// decompressed and handed to the engine driver. A verification that finds a
+// problem still succeeds as a task; its frabit.VerificationReport output says
+// what failed.
+func VerifyTaskHandler(plugins *plugin.Registry, opts ...BackupTaskOption) TaskHandler {
+	o := newBackupTaskOptions(opts)
+	return TaskHandlerFunc(func(ctx context.Context, task frabit.Task, reporter Reporter) (any, error) {
+		var params VerifyTaskParams
+		if err := json.Unmarshal(task.Params, &params); err != nil {
+			return nil, fmt.Errorf("agent: invalid %s task params: %w", task.Type, err)
+		}
+		switch params.Mode {
+		case frabit.VerifyChecksum, frabit.VerifyStructural, frabit.VerifyTestRestore:
+		default:
+			return nil, fmt.Errorf("agent: unknown verify mode %q", params.Mode)
+		}
+		if params.Mode == frabit.VerifyTestRestore {
+			if err := checkScratch(params.Scratch, params.Target); err != nil {
+				return nil, err
+			}
+		}
+		driver, storage, err := backupPlugins(plugins, params.BackupTaskParams)
+		if err != nil {
+			return nil, err
+		}
+		reporter.Logf("verifying %s:%s (%s)", params.Storage, params.Key, params.Mode)
+
+		report := &frabit.VerificationReport{
+			ID:        params.VerificationID,
+			BackupID:  params.BackupID,
+			Mode:      params.Mode,
+			StartedAt: time.Now().UTC(),
+		}
+		err = verifyBackup(ctx, params, driver, storage, o.keys, report)
+		report.FinishedAt = time.Now().UTC()
+		switch {
+		case ctx.Err() != nil:
+			// cancelled or lease lost: the backup was not actually checked
+			return nil, ctx.Err()
+		case err != nil:
+			report.Status = frabit.VerificationFailed
+			report.Error = err.Error()
+			reporter.Logf("verification failed: %v", err)
+		default:
+			report.Status = frabit.VerificationPassed
+			reporter.Logf("verification passed")
+		}
+		return report, nil
+	})
+}
+
+func verifyBackup(ctx context.Context, params VerifyTaskParams, driver plugin.EngineDriver, storage plugin.StorageBackend, keys *artifact.Keyring, report *frabit.VerificationReport) error {
+	if params.Mode == frabit.VerifyChecksum && params.Checksum == "" {
+		return errors.New("no checksum was recorded for this backup")
+	}
+	source, err := storage.Get(ctx, params.Key)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	sum := sha256.New()
+	stored := &countingReader{r: io.TeeReader(source, sum)}
+	finishChecksum := func() error {
+		// drain what the driver did not read so the checksum covers everything
+		if _, err := io.Copy(io.Discard, stored); err != nil {
+			return err
+		}
+		report.Checksum = &frabit.ChecksumResult{Expected: params.Checksum, Actual: checksum(sum.Sum(nil)), Bytes: stored.n}
+		if params.Checksum != "" && !report.Checksum.Match() {
+			return fmt.Errorf("checksum mismatch: recorded %s, stored artifact has %s", params.Checksum, report.Checksum.Actual)
+		}
+		return nil
+	}
+	if params.Mode == frabit.VerifyChecksum {
+		return finishChecksum()
+	}
+
+	structure := &frabit.StructuralResult{Method: string(params.Target.Method)}
+	report.Structure = structure
+	r, err := artifact.NewReader(stored, artifact.ReaderOptions{Keys: keys, Compression: params.Compression})
+	if err != nil {
+		return errors.Join(err, finishChecksum())
+	}
+	defer r.Close()
+	structure.Decrypted = r.KeyID() != ""
+	if err := checkKeyID(r, params.KeyID); err != nil {
+		return errors.Join(err, finishChecksum())
+	}
+	plain := &countingReader{r: r}
+
+	if params.Mode == frabit.VerifyStructural {
+		err = driver.Verify(ctx, params.Target.Method, plain)
+	} else {
+		scratch := params.Scratch
+		scratch.Method = params.Target.Method
+		report.TestRestore = &frabit.TestRestoreResult{ScratchInstance: scratchName(scratch)}
+		started := time.Now()
+		err = driver.Restore(ctx, scratch, plain)
+		report.TestRestore.Duration = time.Since(started)
+	}
+	if err == nil {
+		// every chunk must authenticate, even past where the driver stopped reading
+		_, err = io.Copy(io.Discard, plain)
+	}
+	if err != nil {
+		// a corrupt artifact usually shows up as a driver error; the
+		// checksum tells whether storage or the backup itself is at fault
+		return errors.Join(err, finishChecksum())
+	}
+	structure.Decompressed = true
+	structure.Bytes = plain.n
+	return finishChecksum()
+}
+
+// checkScratch refuses test restores that would not go to a dedicated
+// instance. A logical restore connects to the scratch instance, and a
+// driver given no address connects to the local default instance, which is
+// usually the one the agent backs up; a physical restore writes to the data
+// dir and needs nothing else.
+func checkScratch(scratch, target plugin.BackupTarget) error {
+	switch target.Method {
+	case plugin.BackupPhysical:
+		if scratch.DataDir == "" {
+			return errors.New("agent: physical test restore needs a scratch instance with a data dir")
+		}
+		if scratch.DataDir == target.DataDir {
+			return fmt.Errorf("agent: scratch data dir %s is the backed up instance's", scratch.DataDir)
+		}
+	default:
+		if scratch.Host == "" && scratch.Socket == "" {
+			return errors.New("agent: logical test restore needs a scratch instance with a host or socket")
+		}
+		if sameAddress(scratch, target) {
+			return fmt.Errorf("agent: scratch instance %s is the backed up instance", scratchName(scratch))
+		}
+	}
+	return nil
+}
+
+// sameAddress reports whether the scratch instance may be reached at the
+// target's address. An empty host is the local default instance, and a
+// zero port the default port.
+func sameAddress(scratch, target plugin.BackupTarget) bool {
+	if scratch.Socket != "" {
+		return scratch.Socket == target.Socket
+	}
+	samePort := scratch.Port == target.Port || scratch.Port == 0 || target.Port == 0
+	if localHost(scratch.Host) {
+		return localHost(target.Host) && samePort
+	}
+	return scratch.Host == target.Host && samePort
+}
+
+func localHost(host string) bool {
+	if host == "" || host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func scratchName(target plugin.BackupTarget) string {
+	switch {
+	case target.Host != "":
+		return net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+	case target.Socket != "":
+		return target.Socket
+	}
+	return target.DataDir
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
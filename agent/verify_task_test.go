@@ -0,0 +1,142 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frabits/frabit-go-sdk/agent/artifact"
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+	"github.com/frabits/frabit-go-sdk/plugin/storage"
+)
+
+func TestVerifyTask(t *testing.T) {
+	dir := t.TempDir()
+	local, _ := storage.NewLocal(dir)
+	driver := &memoryDriver{dump: bytes.Repeat([]byte("INSERT INTO t VALUES (42);\n"), 2000)}
+	plugins := plugin.NewRegistry()
+	plugins.Register(driver)
+	plugins.Register(local)
+	raw := make([]byte, 32)
+	rand.Read(raw)
+	key, _ := artifact.NewMasterKey("master-1", raw)
+	keys := artifact.NewKeyring(key)
+	ctx := context.Background()
+
+	backupParams := BackupTaskParams{Driver: "memory", Storage: "local", Key: "b1", Compression: artifact.CompressionGzip, KeyID: "master-1"}
+	params, _ := json.Marshal(backupParams)
+	out, err := BackupTaskHandler(plugins, WithKeyring(keys)).Handle(ctx, frabit.Task{Params: params}, discardReporter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := out.(BackupTaskOutput).Checksum
+
+	verify := func(mode frabit.VerifyMode) *frabit.VerificationReport {
+		t.Helper()
+		params, _ := json.Marshal(VerifyTaskParams{
+			BackupTaskParams: backupParams,
+			BackupID:         "b1",
+			Mode:             mode,
+			Checksum:         recorded,
+			Scratch:          plugin.BackupTarget{Host: "scratch", Port: 3306},
+		})
+		out, err := VerifyTaskHandler(plugins, WithKeyring(keys)).Handle(ctx, frabit.Task{Type: frabit.TaskVerify, Params: params}, discardReporter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.(*frabit.VerificationReport)
+	}
+
+	for _, mode := range []frabit.VerifyMode{frabit.VerifyChecksum, frabit.VerifyStructural, frabit.VerifyTestRestore} {
+		report := verify(mode)
+		if !report.Passed() || !report.Checksum.Match() {
+			t.Fatalf("%s: report = %+v", mode, report)
+		}
+		if mode != frabit.VerifyChecksum && (!report.Structure.Decrypted || report.Structure.Bytes != int64(len(driver.dump))) {
+			t.Errorf("%s: structure = %+v", mode, report.Structure)
+		}
+	}
+	if report := verify(frabit.VerifyTestRestore); report.TestRestore.ScratchInstance != "scratch:3306" || !bytes.Equal(driver.restored, driver.dump) {
+		t.Errorf("test restore = %+v", report.TestRestore)
+	}
+
+	// test restores never fall back to the instance the driver defaults to
+	driver.restored = nil
+	backupParams.Target = plugin.BackupTarget{Host: "db1", Port: 3306}
+	for _, scratch := range []plugin.BackupTarget{{}, {Port: 3306}, {Host: "db1", Port: 3306}, {DataDir: "/var/lib/scratch"}} {
+		params, _ := json.Marshal(VerifyTaskParams{BackupTaskParams: backupParams, Mode: frabit.VerifyTestRestore, Checksum: recorded, Scratch: scratch})
+		if _, err := VerifyTaskHandler(plugins, WithKeyring(keys)).Handle(ctx, frabit.Task{Type: frabit.TaskVerify, Params: params}, discardReporter{}); err == nil {
+			t.Errorf("scratch %+v: want error", scratch)
+		}
+	}
+	if driver.restored != nil {
+		t.Error("Restore called without a usable scratch instance")
+	}
+
+	// flip a byte in the stored artifact
+	name := filepath.Join(dir, "b1")
+	data, _ := os.ReadFile(name)
+	data[len(data)/2] ^= 0xff
+	os.WriteFile(name, data, 0o600)
+
+	for _, mode := range []frabit.VerifyMode{frabit.VerifyChecksum, frabit.VerifyStructural} {
+		if report := verify(mode); report.Status != frabit.VerificationFailed || report.Checksum.Match() || report.Error == "" {
+			t.Errorf("%s on corrupt artifact: report = %+v", mode, report)
+		}
+	}
+
+	// an unencrypted artifact in place of the encrypted one
+	var plain bytes.Buffer
+	w, _ := artifact.NewWriter(&plain, artifact.Options{Compression: artifact.CompressionGzip})
+	w.Write(driver.dump)
+	w.Close()
+	os.WriteFile(name, plain.Bytes(), 0o600)
+	if report := verify(frabit.VerifyStructural); report.Status != frabit.VerificationFailed {
+		t.Errorf("structural on unencrypted artifact: report = %+v", report)
+	}
+}
+
+func TestCheckScratch(t *testing.T) {
+	local := plugin.BackupTarget{DataDir: "/var/lib/mysql"}
+	physical := plugin.BackupTarget{Method: plugin.BackupPhysical, DataDir: "/var/lib/mysql"}
+	for _, tt := range []struct {
+		scratch, target plugin.BackupTarget
+		ok              bool
+	}{
+		{plugin.BackupTarget{Host: "scratch"}, local, true},
+		{plugin.BackupTarget{Socket: "/run/scratch.sock"}, local, true},
+		{plugin.BackupTarget{Host: "127.0.0.1", Port: 3307}, plugin.BackupTarget{Port: 3306}, true},
+		// logical restores ignore the data dir and would go to the local instance
+		{plugin.BackupTarget{DataDir: "/var/lib/scratch"}, local, false},
+		{plugin.BackupTarget{Host: "localhost"}, local, false},
+		{plugin.BackupTarget{Host: "::1", Port: 3306}, plugin.BackupTarget{Host: "127.0.0.1"}, false},
+		{plugin.BackupTarget{Socket: "/run/mysqld.sock"}, plugin.BackupTarget{Socket: "/run/mysqld.sock"}, false},
+		{plugin.BackupTarget{DataDir: "/var/lib/scratch"}, physical, true},
+		{plugin.BackupTarget{Host: "scratch"}, physical, false},
+		{plugin.BackupTarget{DataDir: "/var/lib/mysql"}, physical, false},
+	} {
+		if err := checkScratch(tt.scratch, tt.target); (err == nil) != tt.ok {
+			t.Errorf("checkScratch(%+v, %+v) = %v", tt.scratch, tt.target, err)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth signs humans in to Frabit without long-lived tokens.
+//
+// DeviceLogin implements the OAuth2 device authorization grant (RFC 8628):
+// the user confirms a short code in a browser while the client polls the
+// token endpoint, and the tokens obtained are saved in the config profile.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// DeviceAuthorizationPath is the device authorization endpoint of the Frabit API.
+const DeviceAuthorizationPath = "/api/v2/auth/device"
+
+// DefaultClientID is the OAuth2 client Frabit registers for its command-line tools.
+const DefaultClientID = "frabit-cli"
+
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+var (
+	ErrAccessDenied = errors.New("auth: the login was denied")
+	ErrExpired      = errors.New("auth: the login code expired before it was confirmed")
+)
+
+// pollUnit is the unit of the intervals and lifetimes a server sends, so
+// tests need not wait seconds.
+var pollUnit = time.Second
+
+// DeviceCode is what the user needs to confirm the login.
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// Token is the result of a successful login.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// PromptFunc shows the user where to confirm the code.
+type PromptFunc func(code DeviceCode) error
+
+type LoginOption func(o *loginOptions)
+
+type loginOptions struct {
+	clientID string
+	scopes   []string
+	prompt   PromptFunc
+	save     bool
+}
+
+// WithClientID logs in as a client other than DefaultClientID.
+func WithClientID(id string) LoginOption {
+	return func(o *loginOptions) {
+		o.clientID = id
+	}
+}
+
+func WithScopes(scopes ...string) LoginOption {
+	return func(o *loginOptions) {
+		o.scopes = scopes
+	}
+}
+
+// WithPrompt replaces the default prompt, which prints the verification URL
+// and code to stderr.
+func WithPrompt(prompt PromptFunc) LoginOption {
+	return func(o *loginOptions) {
+		o.prompt = prompt
+	}
+}
+
+// WithoutSave only returns the tokens and leaves the config file alone.
+func WithoutSave() LoginOption {
+	return func(o *loginOptions) {
+		o.save = false
+	}
+}
+
+// DeviceLogin signs the user in to the Frabit API of client. Unless
+// WithoutSave is given the tokens are written to the profile the client was
+// built from, or to the default profile of the default config file, and
+// later clients built from that profile renew them as needed.
+func DeviceLogin(ctx context.Context, client *frabit.Client, opts ...LoginOption) (*Token, error) {
+	o := loginOptions{clientID: DefaultClientID, prompt: defaultPrompt, save: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	form := url.Values{"client_id": {o.clientID}}
+	if len(o.scopes) > 0 {
+		form.Set("scope", strings.Join(o.scopes, " "))
+	}
+	var code DeviceCode
+	if err := post(ctx, client, DeviceAuthorizationPath, form, &code); err != nil {
+		return nil, err
+	}
+	if err := o.prompt(code); err != nil {
+		return nil, err
+	}
+
+	token, err := poll(ctx, client, o.clientID, code)
+	if err != nil {
+		return nil, err
+	}
+	if o.save {
+		if err := save(client, o.clientID, token); err != nil {
+			return token, fmt.Errorf("auth: logged in but saving the token failed: %w", err)
+		}
+	}
+	return token, nil
+}
+
+// poll asks the token endpoint until the user has confirmed the code, backing
+// off when told to slow down.
+func poll(ctx context.Context, client *frabit.Client, clientID string, code DeviceCode) (*Token, error) {
+	interval := time.Duration(code.Interval) * pollUnit
+	if interval <= 0 {
+		interval = 5 * pollUnit
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * pollUnit)
+	form := url.Values{"grant_type": {deviceCodeGrant}, "device_code": {code.DeviceCode}, "client_id": {clientID}}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		var resp struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+			ExpiresIn    int64  `json:"expires_in"`
+		}
+		err := post(ctx, client, frabit.OAuth2TokenPath, form, &resp)
+		var oerr *frabit.OAuth2Error
+		switch {
+		case err == nil:
+			token := &Token{AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}
+			if resp.ExpiresIn > 0 {
+				token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+			}
+			return token, nil
+		case !errors.As(err, &oerr):
+			return nil, err
+		case oerr.Code == "authorization_pending":
+		case oerr.Code == "slow_down":
+			interval += 5 * pollUnit
+		case oerr.Code == "expired_token":
+			return nil, ErrExpired
+		case oerr.Code == "access_denied":
+			return nil, ErrAccessDenied
+		default:
+			return nil, err
+		}
+		if code.ExpiresIn > 0 && time.Now().Add(interval).After(deadline) {
+			return nil, ErrExpired
+		}
+		timer.Reset(interval)
+	}
+}
+
+// post sends an OAuth2 form request to path and decodes the JSON response
+// into out, or the OAuth2 error response into a *frabit.OAuth2Error.
+func post(ctx context.Context, client *frabit.Client, path string, form url.Values, out any) error {
+	if client.BaseURL == nil {
+		return errors.New("auth: the client has no base URL")
+	}
+	addr, err := client.BaseURL.Parse(path)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", client.UserAgent)
+	resp, err := client.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		oerr := &frabit.OAuth2Error{}
+		if json.Unmarshal(body, oerr) == nil && oerr.Code != "" {
+			return oerr
+		}
+		return fmt.Errorf("auth: %s failed with status %d", path, resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("auth: malformed response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// save writes token to the client's profile, recording the base URL so that
+// a first login creates a usable profile.
+func save(client *frabit.Client, clientID string, token *Token) error {
+	path, name := "", frabit.DefaultProfile
+	if cfg := client.Config(); cfg != nil {
+		path, name = cfg.Path, cfg.Profile
+	}
+	if path == "" {
+		var err error
+		if path, err = frabit.DefaultConfigPath(); err != nil {
+			return err
+		}
+	}
+	return frabit.UpdateProfile(path, name, func(p *frabit.Profile) {
+		p.BaseURL = client.BaseURL.String()
+		p.Token, p.TokenCommand = token.AccessToken, ""
+		p.RefreshToken, p.TokenExpiry, p.ClientID = token.RefreshToken, token.Expiry, clientID
+	})
+}
+
+func defaultPrompt(code DeviceCode) error {
+	if code.VerificationURIComplete != "" {
+		_, err := fmt.Fprintf(os.Stderr, "Open %s to log in, and check that it shows the code %s.\n", code.VerificationURIComplete, code.UserCode)
+		return err
+	}
+	_, err := fmt.Fprintf(os.Stderr, "Open %s and enter the code %s to log in.\n", code.VerificationURI, code.UserCode)
+	return err
+}
@@ -0,0 +1,150 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// fakeAuthServer answers the device flow with the queued token endpoint
+// errors before issuing a token.
+type fakeAuthServer struct {
+	mu        sync.Mutex
+	pending   []string
+	polls     []time.Time
+	refreshes int
+	expiresIn int
+}
+
+func (f *fakeAuthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case DeviceAuthorizationPath:
+		_ = json.NewEncoder(w).Encode(DeviceCode{DeviceCode: "dev-1", UserCode: "ABCD-EFGH", VerificationURI: "https://frabit.example.com/device", ExpiresIn: 1000, Interval: 1})
+	case frabit.OAuth2TokenPath:
+		switch r.FormValue("grant_type") {
+		case deviceCodeGrant:
+			f.polls = append(f.polls, time.Now())
+			if len(f.pending) > 0 {
+				code := f.pending[0]
+				f.pending = f.pending[1:]
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(frabit.OAuth2Error{Code: code})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access-1", "refresh_token": "refresh-1", "expires_in": f.expiresIn})
+		case "refresh_token":
+			if r.FormValue("refresh_token") != "refresh-1" || r.FormValue("client_id") != DefaultClientID {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(frabit.OAuth2Error{Code: "invalid_grant"})
+				return
+			}
+			f.refreshes++
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access-2", "refresh_token": "refresh-2", "expires_in": 3600})
+		}
+	case "/api/v2/agents":
+		if r.Header.Get("Authorization") != "Bearer access-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	pollUnit = 10 * time.Millisecond
+	api := &fakeAuthServer{pending: []string{"authorization_pending", "slow_down"}, expiresIn: 30}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(frabit.EnvConfig, path)
+	t.Setenv(frabit.EnvProfile, "")
+	t.Setenv(frabit.EnvToken, "")
+	t.Setenv(frabit.EnvBaseURL, "")
+
+	client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shown DeviceCode
+	token, err := DeviceLogin(context.Background(), client, WithPrompt(func(code DeviceCode) error {
+		shown = code
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shown.UserCode != "ABCD-EFGH" || token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" {
+		t.Errorf("code = %+v, token = %+v", shown, token)
+	}
+	// slow_down adds five intervals to the wait before the next poll
+	if len(api.polls) != 3 || api.polls[2].Sub(api.polls[1]) < 6*pollUnit {
+		t.Errorf("polls at %v", api.polls)
+	}
+
+	// the saved profile renews the access token, which expires within the
+	// refresh skew, and saves the rotated refresh token
+	client, err = frabit.NewClientFromConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Agent.ListAgents(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	file, err := frabit.ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := file.Profiles[frabit.DefaultProfile]; api.refreshes != 1 || p.Token != "access-2" || p.RefreshToken != "refresh-2" || p.BaseURL != srv.URL {
+		t.Errorf("refreshes = %d, profile = %+v", api.refreshes, p)
+	}
+}
+
+func TestDeviceLoginErrors(t *testing.T) {
+	pollUnit = time.Millisecond
+	for _, tc := range []struct {
+		code string
+		want error
+	}{
+		{"expired_token", ErrExpired},
+		{"access_denied", ErrAccessDenied},
+	} {
+		srv := httptest.NewServer(&fakeAuthServer{pending: []string{"authorization_pending", tc.code}})
+		client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = DeviceLogin(context.Background(), client, WithoutSave(), WithPrompt(func(DeviceCode) error { return nil }))
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v", tc.code, err)
+		}
+		srv.Close()
+	}
+}
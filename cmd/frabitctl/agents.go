@@ -0,0 +1,57 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func newAgentsCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "agents", Aliases: []string{"agent"}, Short: "Manage Frabit agents"}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List agents",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			agents, err := client.Agent.ListAgents(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, agents)
+		}),
+	}
+
+	deregister := &cobra.Command{
+		Use:   "deregister AGENT",
+		Short: "Remove an agent",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			if err := client.Agent.Deregister(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "agent %s deregistered\n", args[0])
+			return nil
+		}),
+	}
+
+	cmd.AddCommand(list, deregister)
+	return cmd
+}
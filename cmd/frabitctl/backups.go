@@ -0,0 +1,156 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func newBackupsCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "backups", Aliases: []string{"backup"}, Short: "Manage backups, their verification and copies"}
+
+	get := &cobra.Command{
+		Use:   "get",
+		Short: "Show the backup",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			backup, err := client.Backup.GetBackup(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, backup)
+		}),
+	}
+
+	var req frabit.CreateBackupRequest
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Take a backup",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			backup, err := client.Backup.CreateBackup(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, backup)
+		}),
+	}
+	create.Flags().StringVar(&req.Workspace, "workspace", "", "workspace the backup belongs to")
+	create.Flags().StringVar(&req.Name, "name", "", "backup name")
+	create.Flags().StringVar(&req.Owner, "owner", "", "backup owner")
+	create.MarkFlagRequired("name")
+
+	cmd.AddCommand(get, create, newVerifyCmd(g), newVerificationsCmd(g), newCopyCmd(g), newCopiesCmd(g))
+	return cmd
+}
+
+func newVerifyCmd(g *globalOptions) *cobra.Command {
+	var mode string
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "verify BACKUP",
+		Short: "Verify a backup by checksum, structure or a test restore",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			ctx := cmd.Context()
+			report, err := client.Backup.Verify(ctx, args[0], frabit.VerifyMode(mode))
+			if err != nil {
+				return err
+			}
+			for wait && (report.Status == frabit.VerificationPending || report.Status == frabit.VerificationRunning) {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(2 * time.Second):
+				}
+				if report, err = client.Backup.GetVerification(ctx, args[0], report.ID); err != nil {
+					return err
+				}
+			}
+			if err := g.print(cmd, report); err != nil {
+				return err
+			}
+			if report.Status == frabit.VerificationFailed {
+				return fmt.Errorf("backup %s failed verification: %s", args[0], report.Error)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&mode, "mode", string(frabit.VerifyChecksum), "checksum, structural or test_restore")
+	cmd.Flags().BoolVar(&wait, "wait", false, "wait for the verification to finish")
+	return cmd
+}
+
+func newVerificationsCmd(g *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "verifications BACKUP",
+		Short: "List the verifications of a backup",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			reports, err := client.Backup.ListVerifications(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, reports)
+		}),
+	}
+}
+
+func newCopyCmd(g *globalOptions) *cobra.Command {
+	var req frabit.CopyBackupRequest
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "copy BACKUP",
+		Short: "Copy a backup to another storage",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			op, err := client.Backup.CopyWithRetention(cmd.Context(), args[0], req)
+			if err != nil {
+				return err
+			}
+			if wait {
+				err = op.Wait(cmd.Context())
+			}
+			return errors.Join(g.print(cmd, op.Operation), err)
+		}),
+	}
+	cmd.Flags().StringVar(&req.DestinationStorage, "to", "", "destination storage")
+	cmd.Flags().IntVar(&req.RetentionDays, "retention-days", 0, "days to keep the copy, 0 keeps it until deleted")
+	cmd.Flags().BoolVar(&wait, "wait", false, "wait for the copy to finish")
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func newCopiesCmd(g *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "copies BACKUP",
+		Short: "List the copies of a backup",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			copies, err := client.Backup.ListCopies(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, copies)
+		}),
+	}
+}
@@ -0,0 +1,86 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func newClustersCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "clusters", Aliases: []string{"cluster"}, Short: "Manage clusters and their log archiving"}
+
+	get := &cobra.Command{
+		Use:   "get",
+		Short: "Show the cluster",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			cluster, err := client.Cluster.GetCluster(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, cluster)
+		}),
+	}
+
+	var req frabit.CreateClusterRequest
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a cluster",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			cluster, err := client.Cluster.CreateCluster(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, cluster)
+		}),
+	}
+	create.Flags().StringVar(&req.Workspace, "workspace", "", "workspace the cluster belongs to")
+	create.Flags().StringVar(&req.Name, "name", "", "cluster name")
+	create.Flags().StringVar(&req.Owner, "owner", "", "cluster owner")
+	create.MarkFlagRequired("name")
+
+	archive := &cobra.Command{
+		Use:   "archive CLUSTER",
+		Short: "Show the binlog/WAL archive streams of a cluster",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			streams, err := client.Archive.ListArchiveStreams(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, streams)
+		}),
+	}
+
+	window := &cobra.Command{
+		Use:   "recoverable-window CLUSTER",
+		Short: "Show the time ranges a cluster can be restored to",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			w, err := client.Archive.RecoverableWindow(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, w)
+		}),
+	}
+
+	cmd.AddCommand(get, create, archive, window)
+	return cmd
+}
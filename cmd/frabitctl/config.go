@@ -0,0 +1,79 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// configValue is one row of config view.
+type configValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+func newConfigCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "config", Short: "Inspect the client configuration"}
+
+	view := &cobra.Command{
+		Use:   "view",
+		Short: "Show the resolved configuration and where each value came from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := frabit.LoadConfig(g.profile)
+			if err != nil {
+				return err
+			}
+			values := map[string]string{
+				"profile":       cfg.Profile,
+				"base_url":      cfg.BaseURL,
+				"token":         redact(cfg.Token),
+				"token_command": cfg.TokenCommand,
+				"ca_file":       cfg.CAFile,
+				"workspace":     cfg.Workspace,
+			}
+			if cfg.Timeout != 0 {
+				values["timeout"] = cfg.Timeout.String()
+			}
+			flags := map[string]string{"profile": g.profile, "base_url": g.baseURL, "token": redact(g.token)}
+			var rows []configValue
+			for _, key := range frabit.ConfigKeys {
+				row := configValue{Key: key, Value: values[key], Source: cfg.Source(key).String()}
+				if flags[key] != "" {
+					row.Value, row.Source = flags[key], "flag"
+				}
+				rows = append(rows, row)
+			}
+			return g.print(cmd, rows)
+		},
+	}
+
+	cmd.AddCommand(view)
+	return cmd
+}
+
+// redact keeps just enough of a token to tell tokens apart.
+func redact(token string) string {
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+	return token[:4] + strings.Repeat("*", 8)
+}
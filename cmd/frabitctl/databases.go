@@ -0,0 +1,60 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func newDatabasesCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "databases", Aliases: []string{"database", "db"}, Short: "Manage databases"}
+
+	get := &cobra.Command{
+		Use:   "get",
+		Short: "Show the database",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			db, err := client.Database.GetDatabase(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, db)
+		}),
+	}
+
+	var req frabit.CreateDatabaseRequest
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a database",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			db, err := client.Database.CreateDatabase(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, db)
+		}),
+	}
+	create.Flags().StringVar(&req.Workspace, "workspace", "", "workspace the database belongs to")
+	create.Flags().StringVar(&req.Name, "name", "", "database name")
+	create.Flags().StringVar(&req.Owner, "owner", "", "database owner")
+	create.MarkFlagRequired("name")
+
+	cmd.AddCommand(get, create)
+	return cmd
+}
@@ -0,0 +1,57 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/auth"
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func newLoginCmd(g *globalOptions) *cobra.Command {
+	var scopes []string
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in with a browser and save the token in the profile",
+		Long: "Log in with the OAuth2 device flow: confirm the code shown in a browser, and the\n" +
+			"access and refresh tokens are saved in the selected profile of the config file.",
+		Args: cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			prompt := auth.WithPrompt(func(code auth.DeviceCode) error {
+				uri := code.VerificationURI
+				if code.VerificationURIComplete != "" {
+					uri = code.VerificationURIComplete
+				}
+				_, err := fmt.Fprintf(cmd.ErrOrStderr(), "Open %s\nand confirm the code %s\n", uri, code.UserCode)
+				return err
+			})
+			if _, err := auth.DeviceLogin(cmd.Context(), client, prompt, auth.WithScopes(scopes...)); err != nil {
+				return err
+			}
+			profile := frabit.DefaultProfile
+			if cfg := client.Config(); cfg != nil {
+				profile = cfg.Profile
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "logged in, token saved to profile %s\n", profile)
+			return nil
+		}),
+	}
+	cmd.Flags().StringSliceVar(&scopes, "scope", nil, "OAuth2 scopes to request")
+	return cmd
+}
@@ -0,0 +1,114 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command frabitctl manages Frabit from the command line.
+//
+//	frabitctl --base-url https://frabit.example.com --token $TOKEN backups verify 42 --mode structural --wait
+//
+// Without flags the address and credentials come from the selected profile
+// of ~/.frabit/config.yaml and the FRABIT_* environment variables.
+//
+// Every command prints its result as a table, or as JSON or YAML with -o.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := newRootCmd().ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// globalOptions are the flags shared by all commands.
+type globalOptions struct {
+	baseURL string
+	token   string
+	profile string
+	output  string
+}
+
+func newRootCmd() *cobra.Command {
+	g := &globalOptions{}
+	root := &cobra.Command{
+		Use:          "frabitctl",
+		Short:        "Manage Frabit databases, clusters, backups and agents",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			switch g.output {
+			case outputTable, outputJSON, outputYAML:
+				return nil
+			}
+			return fmt.Errorf("unknown output format %q, use table, json or yaml", g.output)
+		},
+	}
+	flags := root.PersistentFlags()
+	// environment fallbacks are applied by the profile, so --help never shows a token
+	flags.StringVar(&g.baseURL, "base-url", "", "Frabit API address [$FRABIT_BASE_URL]")
+	flags.StringVar(&g.token, "token", "", "API token [$FRABIT_TOKEN]")
+	flags.StringVar(&g.profile, "profile", "", "configuration profile [$FRABIT_PROFILE]")
+	flags.StringVarP(&g.output, "output", "o", outputTable, "output format: table, json or yaml")
+
+	root.AddCommand(
+		newDatabasesCmd(g),
+		newClustersCmd(g),
+		newBackupsCmd(g),
+		newRestoresCmd(g),
+		newTeamsCmd(g),
+		newOrgsCmd(g),
+		newAgentsCmd(g),
+		newConfigCmd(g),
+		newLoginCmd(g),
+	)
+	return root
+}
+
+func (g *globalOptions) client() (*frabit.Client, error) {
+	opts := []frabit.ClientOption{frabit.WithProfile(g.profile), frabit.WithUserAgent("frabitctl")}
+	if g.baseURL != "" {
+		opts = append(opts, frabit.WithBaseURL(g.baseURL))
+	}
+	if g.token != "" {
+		opts = append(opts, frabit.WithToken(g.token))
+	}
+	return frabit.NewClient(opts...)
+}
+
+// print writes v to the command's output in the selected format.
+func (g *globalOptions) print(cmd *cobra.Command, v any) error {
+	return render(cmd.OutOrStdout(), g.output, v)
+}
+
+// run adapts a function taking a client to cobra's RunE.
+func (g *globalOptions) run(fn func(cmd *cobra.Command, client *frabit.Client, args []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		client, err := g.client()
+		if err != nil {
+			return err
+		}
+		return fn(cmd, client, args)
+	}
+}
@@ -0,0 +1,148 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func fakeAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/agents":
+			json.NewEncoder(w).Encode([]frabit.Agent{
+				{AgentID: "a1", Name: "db-01", Status: frabit.Active, LastHeartbeat: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+				{AgentID: "a2", Name: "db-02", Status: frabit.UnReachable},
+			})
+		case "/api/v2/clusters/c1/archive-streams":
+			json.NewEncoder(w).Encode([]frabit.ArchiveStream{{ID: "s1", Kind: frabit.ArchiveWAL, Status: frabit.ArchiveLagging, LagSeconds: 12.5}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRootCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestOutputFormats(t *testing.T) {
+	srv := fakeAPI(t)
+	base := []string{"--base-url", srv.URL, "--token", "s3cret"}
+
+	table, err := run(t, append(base, "agents", "list")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "AGENT ID") || !strings.Contains(lines[1], "2024-06-01T12:00:00Z") {
+		t.Fatalf("table output:\n%s", table)
+	}
+	if strings.Contains(lines[0], "INVENTORY") {
+		t.Error("nested inventory rendered as a column")
+	}
+
+	out, err := run(t, append(base, "agents", "list", "-o", "json")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var agents []frabit.Agent
+	if err := json.Unmarshal([]byte(out), &agents); err != nil || len(agents) != 2 || agents[1].Status != frabit.UnReachable {
+		t.Fatalf("json output %q: %v", out, err)
+	}
+
+	out, err = run(t, append(base, "clusters", "archive", "c1", "-o", "yaml")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "lag_seconds: 12.5") || !strings.Contains(out, "kind: wal") {
+		t.Fatalf("yaml output:\n%s", out)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := fakeAPI(t)
+
+	if _, err := run(t, "--base-url", srv.URL, "--token", "wrong", "agents", "list"); !frabit.IsErrorCode(err, frabit.ErrUnauthorized) {
+		t.Errorf("bad token: err = %v", err)
+	}
+	if _, err := run(t, "--base-url", srv.URL, "--token", "s3cret", "-o", "xml", "agents", "list"); err == nil {
+		t.Error("unknown output format accepted")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("FRABIT_CONFIG", "")
+	t.Setenv("FRABIT_BASE_URL", "")
+	if _, err := run(t, "agents", "list"); err == nil || !strings.Contains(err.Error(), "base_url") {
+		t.Errorf("missing base url: err = %v", err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	srv := fakeAPI(t)
+	path := t.TempDir() + "/config.yaml"
+	config := "profiles:\n  test:\n    base_url: " + srv.URL + "\n    token_command: echo s3cret\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FRABIT_CONFIG", path)
+	t.Setenv("FRABIT_BASE_URL", "")
+	t.Setenv("FRABIT_TOKEN", "")
+
+	if _, err := run(t, "--profile", "test", "agents", "list"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := run(t, "--profile", "test", "--token", "flag-token-123", "config", "view", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []configValue
+	if err := json.Unmarshal([]byte(out), &rows); err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{}
+	for _, row := range rows {
+		sources[row.Key] = row.Source
+		if row.Key == "token" && row.Value != "flag********" {
+			t.Errorf("token shown as %q", row.Value)
+		}
+	}
+	if sources["base_url"] != path+" (profile test)" || sources["token"] != "flag" || sources["profile"] != "flag" {
+		t.Errorf("sources = %v", sources)
+	}
+}
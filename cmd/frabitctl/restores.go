@@ -0,0 +1,86 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func newRestoresCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "restores", Aliases: []string{"restore"}, Short: "Restore backups"}
+
+	var req frabit.CreateRestoreRequest
+	var pointInTime string
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Restore a backup into a cluster",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			if pointInTime != "" {
+				t, err := time.Parse(time.RFC3339, pointInTime)
+				if err != nil {
+					return err
+				}
+				req.PointInTime = &t
+			}
+			restore, err := client.Restore.CreateRestore(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, restore)
+		}),
+	}
+	create.Flags().StringVar(&req.BackupID, "backup", "", "backup to restore")
+	create.Flags().StringVar(&req.ClusterID, "cluster", "", "cluster to restore into")
+	create.Flags().StringVar(&pointInTime, "point-in-time", "", "roll forward to this RFC 3339 time using archived logs")
+	create.MarkFlagRequired("backup")
+	create.MarkFlagRequired("cluster")
+
+	get := &cobra.Command{
+		Use:   "get RESTORE",
+		Short: "Show a restore",
+		Args:  cobra.ExactArgs(1),
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			restore, err := client.Restore.GetRestore(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, restore)
+		}),
+	}
+
+	var clusterID string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List restores",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			restores, err := client.Restore.ListRestores(cmd.Context(), clusterID)
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, restores)
+		}),
+	}
+	list.Flags().StringVar(&clusterID, "cluster", "", "only restores into this cluster")
+
+	cmd.AddCommand(create, get, list)
+	return cmd
+}
@@ -0,0 +1,105 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func newTeamsCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "teams", Aliases: []string{"team"}, Short: "Manage teams"}
+
+	get := &cobra.Command{
+		Use:   "get",
+		Short: "Show the team",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			team, err := client.Team.GetTeam(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, team)
+		}),
+	}
+
+	var req frabit.CreateTeamRequest
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a team",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			team, err := client.Team.CreateTeam(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			return g.print(cmd, team)
+		}),
+	}
+	create.Flags().StringVar(&req.Name, "name", "", "team name")
+	create.Flags().StringVar(&req.Description, "description", "", "team description")
+	create.Flags().StringVar(&req.Owner, "owner", "", "team owner")
+	create.MarkFlagRequired("name")
+
+	cmd.AddCommand(get, create)
+	return cmd
+}
+
+func newOrgsCmd(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{Use: "orgs", Aliases: []string{"org"}, Short: "Manage organizations"}
+
+	var createReq frabit.OrgCreateRequest
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create an organization",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			if err := client.Org.CreateOrg(cmd.Context(), createReq); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "organization %s created\n", createReq.Name)
+			return nil
+		}),
+	}
+	create.Flags().StringVar(&createReq.Name, "name", "", "organization name")
+	create.Flags().StringVar(&createReq.Description, "description", "", "organization description")
+	create.Flags().StringVar(&createReq.Country, "country", "", "organization country")
+	create.MarkFlagRequired("name")
+
+	var updateReq frabit.OrgUpdateRequest
+	update := &cobra.Command{
+		Use:   "update",
+		Short: "Update an organization",
+		Args:  cobra.NoArgs,
+		RunE: g.run(func(cmd *cobra.Command, client *frabit.Client, args []string) error {
+			if err := client.Org.UpdateOrg(cmd.Context(), updateReq); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "organization %s updated\n", updateReq.Name)
+			return nil
+		}),
+	}
+	update.Flags().StringVar(&updateReq.Name, "name", "", "organization name")
+	update.Flags().StringVar(&updateReq.Description, "description", "", "organization description")
+	update.Flags().StringVar(&updateReq.Country, "country", "", "organization country")
+	update.MarkFlagRequired("name")
+
+	cmd.AddCommand(create, update)
+	return cmd
+}
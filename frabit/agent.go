@@ -15,22 +15,67 @@
 
 package frabit
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 type AgentService interface {
+	Enroll(ctx context.Context, req EnrollAgentRequest) (*EnrollAgentResponse, error)
 	Register(ctx context.Context, req CreateAgentRequest) error
 	Heartbeat(ctx context.Context, req CreateHeartbeat) error
+	Deregister(ctx context.Context, agentID string) error
+	ListAgents(ctx context.Context) ([]Agent, error)
+	ReportInventory(ctx context.Context, agentID string, inventory AgentInventory) error
+	PushMetrics(ctx context.Context, batch MetricBatch) error
+
+	PollTasks(ctx context.Context, req PollTasksRequest) ([]Task, error)
+	ClaimTask(ctx context.Context, req ClaimTaskRequest) (*TaskLease, error)
+	ExtendLease(ctx context.Context, req ExtendLeaseRequest) (*TaskLease, error)
+	ReportProgress(ctx context.Context, req TaskProgress) error
+	AppendLogs(ctx context.Context, req TaskLogs) error
+	SubmitResult(ctx context.Context, req TaskResult) error
 }
 
 type agentService struct {
 	*Client
 }
 
+// Agent is an agent as seen by the Frabit server.
+type Agent struct {
+	AgentID       string          `json:"agent_id"`
+	Name          string          `json:"name"`
+	Status        AgentStatus     `json:"status"`
+	ClientIP      string          `json:"client_ip"`
+	LastHeartbeat time.Time       `json:"last_heartbeat"`
+	Inventory     *AgentInventory `json:"inventory,omitempty"`
+}
+
 type CreateAgentRequest struct {
 	AgentID  string `json:"agent_id"`
 	Name     string `json:"name"`
 	Status   string `json:"status"`
 	ClientIP string `json:"client_ip"`
+
+	Inventory *AgentInventory `json:"inventory,omitempty"`
+}
+
+// EnrollAgentRequest exchanges a one-time join token for a client certificate
+// signed by the Frabit agent CA.
+type EnrollAgentRequest struct {
+	JoinToken string `json:"join_token"`
+	AgentID   string `json:"agent_id"`
+	// CSR is a PEM encoded PKCS #10 certificate signing request
+	CSR string `json:"csr"`
+}
+
+type EnrollAgentResponse struct {
+	// Certificate is the PEM encoded client certificate, followed by any intermediates
+	Certificate string `json:"certificate"`
+	// CABundle is the PEM encoded CA bundle the server certificate chains to
+	CABundle  string    `json:"ca_bundle"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type CreateHeartbeat struct {
@@ -46,6 +91,19 @@ const (
 	UnReachable AgentStatus = "un_reachable"
 )
 
+func (s *agentService) Enroll(ctx context.Context, req EnrollAgentRequest) (*EnrollAgentResponse, error) {
+	request, err := s.Client.newRequest("post", "/api/v2/agents/enroll", req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &EnrollAgentResponse{}
+	err = s.do(ctx, request, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (s *agentService) Register(ctx context.Context, req CreateAgentRequest) error {
 	request, err := s.Client.newRequest("post", "/api/v2/agents", req)
 	if err != nil {
@@ -61,3 +119,32 @@ func (s *agentService) Heartbeat(ctx context.Context, req CreateHeartbeat) error
 	}
 	return s.do(ctx, request, nil)
 }
+
+func (s *agentService) Deregister(ctx context.Context, agentID string) error {
+	request, err := s.Client.newRequest("delete", fmt.Sprintf("/api/v2/agents/%s", agentID), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
+
+func (s *agentService) ListAgents(ctx context.Context) ([]Agent, error) {
+	request, err := s.Client.newRequest("get", "/api/v2/agents", nil)
+	if err != nil {
+		return nil, err
+	}
+	var agents []Agent
+	err = s.do(ctx, request, &agents)
+	if err != nil {
+		return nil, err
+	}
+	return agents, nil
+}
+
+func (s *agentService) ReportInventory(ctx context.Context, agentID string, inventory AgentInventory) error {
+	request, err := s.Client.newRequest("put", fmt.Sprintf("/api/v2/agents/%s/inventory", agentID), inventory)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
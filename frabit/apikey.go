@@ -0,0 +1,151 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// APIKey is a long-lived credential of a user. The secret itself is only
+// returned when the key is created or rotated.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Prefix is the start of the secret, to recognise a key without storing it.
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key is neither revoked nor expired at t.
+func (k APIKey) Active(t time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || t.Before(k.ExpiresAt))
+}
+
+// APIKeySecret is a key along with its secret.
+type APIKeySecret struct {
+	APIKey
+	Secret string `json:"secret"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is when the key stops working; nil keeps it valid until revoked.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type rotateAPIKeyRequest struct {
+	GracePeriodSeconds int64 `json:"grace_period_seconds"`
+}
+
+// CurrentUser is the identity a request is made with.
+type CurrentUser struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// AuthMethod is how the caller authenticated, e.g. "api_key" or "oauth2".
+	AuthMethod string `json:"auth_method"`
+	// APIKeyID is set when the caller authenticated with an API key.
+	APIKeyID string          `json:"api_key_id,omitempty"`
+	Orgs     []OrgMembership `json:"orgs"`
+	// Scopes are the effective scopes: those of the credential narrowed to
+	// what the user's roles allow.
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope reports whether the caller was granted scope.
+func (u *CurrentUser) HasScope(scope string) bool {
+	for _, s := range u.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type OrgMembership struct {
+	OrgID   string `json:"org_id"`
+	OrgName string `json:"org_name"`
+	Role    string `json:"role"`
+}
+
+func (u *userService) GetCurrentUser(ctx context.Context) (*CurrentUser, error) {
+	request, err := u.Client.newRequest("get", "/api/v2/user/me", nil)
+	if err != nil {
+		return nil, err
+	}
+	user := &CurrentUser{}
+	err = u.do(ctx, request, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *userService) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (*APIKeySecret, error) {
+	request, err := u.Client.newRequest("post", "/api/v2/user/api-keys", req)
+	if err != nil {
+		return nil, err
+	}
+	key := &APIKeySecret{}
+	err = u.do(ctx, request, key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (u *userService) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	request, err := u.Client.newRequest("get", "/api/v2/user/api-keys", nil)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	err = u.do(ctx, request, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (u *userService) RevokeAPIKey(ctx context.Context, keyID string) error {
+	request, err := u.Client.newRequest("delete", fmt.Sprintf("/api/v2/user/api-keys/%s", keyID), nil)
+	if err != nil {
+		return err
+	}
+	return u.do(ctx, request, nil)
+}
+
+func (u *userService) RotateAPIKey(ctx context.Context, keyID string, gracePeriod time.Duration) (*APIKeySecret, error) {
+	body := rotateAPIKeyRequest{GracePeriodSeconds: int64(gracePeriod / time.Second)}
+	request, err := u.Client.newRequest("post", fmt.Sprintf("/api/v2/user/api-keys/%s/rotate", keyID), body)
+	if err != nil {
+		return nil, err
+	}
+	key := &APIKeySecret{}
+	err = u.do(ctx, request, key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,292 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ArchiveService reports on continuous binlog/WAL archiving, the basis of
+// point in time recovery.
+type ArchiveService interface {
+	ListArchiveStreams(ctx context.Context, clusterID string) ([]ArchiveStream, error)
+	GetArchiveStream(ctx context.Context, clusterID, streamID string) (*ArchiveStream, error)
+	// BackupChain lists the backups of a cluster a restore can start from.
+	BackupChain(ctx context.Context, clusterID string) ([]BackupChainEntry, error)
+	// RecoverableWindow merges the backup chain with the archived log
+	// coverage into the time ranges the cluster can be restored to.
+	RecoverableWindow(ctx context.Context, clusterID string) (RecoveryWindow, error)
+}
+
+type archiveService struct {
+	*Client
+}
+
+type ArchiveKind string
+
+const (
+	ArchiveBinlog ArchiveKind = "binlog"
+	ArchiveWAL    ArchiveKind = "wal"
+)
+
+type ArchiveStatus string
+
+const (
+	ArchiveStreaming ArchiveStatus = "streaming"
+	ArchiveLagging   ArchiveStatus = "lagging"
+	ArchiveStopped   ArchiveStatus = "stopped"
+	ArchiveError     ArchiveStatus = "error"
+)
+
+// ArchivePosition is a position in the binary log (File, Position, GTIDSet)
+// or the WAL (File, LSN) together with the commit time it corresponds to.
+type ArchivePosition struct {
+	File     string    `json:"file"`
+	Position uint64    `json:"position,omitempty"`
+	GTIDSet  string    `json:"gtid_set,omitempty"`
+	LSN      string    `json:"lsn,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// ArchiveGap is a stretch of log that is missing from the archive. Nothing
+// between From and To can be replayed.
+type ArchiveGap struct {
+	From       ArchivePosition `json:"from"`
+	To         ArchivePosition `json:"to"`
+	DetectedAt time.Time       `json:"detected_at"`
+}
+
+// ArchiveStream archives the logs of one instance of a cluster to storage.
+type ArchiveStream struct {
+	ID         string        `json:"id"`
+	ClusterID  string        `json:"cluster_id"`
+	InstanceID string        `json:"instance_id"`
+	Kind       ArchiveKind   `json:"kind"`
+	Storage    string        `json:"storage"`
+	Status     ArchiveStatus `json:"status"`
+	Error      string        `json:"error,omitempty"`
+
+	// ArchivedPosition is the last position safely in the archive and
+	// PrimaryPosition the current one on the primary.
+	ArchivedPosition ArchivePosition `json:"archived_position"`
+	PrimaryPosition  ArchivePosition `json:"primary_position"`
+	LagBytes         int64           `json:"lag_bytes"`
+	LagSeconds       float64         `json:"lag_seconds"`
+	Gaps             []ArchiveGap    `json:"gaps,omitempty"`
+
+	// EarliestRecoverable and LatestRecoverable bound the archived logs;
+	// gaps in between are not subtracted.
+	EarliestRecoverable time.Time `json:"earliest_recoverable"`
+	LatestRecoverable   time.Time `json:"latest_recoverable"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Lag is how far the archive is behind the primary.
+func (s *ArchiveStream) Lag() time.Duration {
+	return time.Duration(s.LagSeconds * float64(time.Second))
+}
+
+// Coverage is the time the stream's logs can be replayed over: the archived
+// range minus its gaps.
+func (s *ArchiveStream) Coverage() RecoveryWindow {
+	if s.EarliestRecoverable.IsZero() || s.LatestRecoverable.Before(s.EarliestRecoverable) {
+		return nil
+	}
+	window := RecoveryWindow{{Start: s.EarliestRecoverable, End: s.LatestRecoverable}}
+	for _, gap := range s.Gaps {
+		window = window.subtract(TimeRange{Start: gap.From.Time, End: gap.To.Time})
+	}
+	return window
+}
+
+type BackupChainEntry struct {
+	BackupID string `json:"backup_id"`
+	// Type is "full" or "incremental"; incrementals need their parent.
+	Type     string `json:"type"`
+	ParentID string `json:"parent_id,omitempty"`
+	// ConsistentAt is the point in time the backup restores to.
+	ConsistentAt time.Time `json:"consistent_at"`
+	// Usable is false for failed, expired or deleted backups.
+	Usable bool `json:"usable"`
+}
+
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && !t.After(r.End)
+}
+
+// RecoveryWindow is a sorted list of disjoint time ranges. A range whose
+// Start equals its End is a single restorable point, e.g. a backup without
+// logs to replay after it.
+type RecoveryWindow []TimeRange
+
+func (w RecoveryWindow) Contains(t time.Time) bool {
+	for _, r := range w {
+		if r.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Latest returns the most recent restorable point.
+func (w RecoveryWindow) Latest() (time.Time, bool) {
+	if len(w) == 0 {
+		return time.Time{}, false
+	}
+	return w[len(w)-1].End, true
+}
+
+// merge sorts w and joins overlapping or touching ranges.
+func (w RecoveryWindow) merge() RecoveryWindow {
+	if len(w) == 0 {
+		return nil
+	}
+	sorted := append(RecoveryWindow(nil), w...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	merged := RecoveryWindow{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start.After(last.End) {
+			merged = append(merged, r)
+			continue
+		}
+		if r.End.After(last.End) {
+			last.End = r.End
+		}
+	}
+	return merged
+}
+
+// subtract removes the open interval (gap.Start, gap.End) from w: the
+// positions bounding a gap are themselves archived.
+func (w RecoveryWindow) subtract(gap TimeRange) RecoveryWindow {
+	var out RecoveryWindow
+	for _, r := range w {
+		if !gap.Start.Before(r.End) || !gap.End.After(r.Start) {
+			out = append(out, r)
+			continue
+		}
+		if gap.Start.After(r.Start) {
+			out = append(out, TimeRange{Start: r.Start, End: gap.Start})
+		}
+		if gap.End.Before(r.End) {
+			out = append(out, TimeRange{Start: gap.End, End: r.End})
+		}
+	}
+	return out
+}
+
+// recoverableWindow computes the restorable time ranges: every usable backup
+// whose chain back to a full backup is intact is a restorable point, extended
+// forward for as long as the archived logs cover time without interruption.
+func recoverableWindow(chain []BackupChainEntry, streams []ArchiveStream) RecoveryWindow {
+	var coverage RecoveryWindow
+	for i := range streams {
+		coverage = append(coverage, streams[i].Coverage()...)
+	}
+	coverage = coverage.merge()
+
+	byID := make(map[string]BackupChainEntry, len(chain))
+	for _, b := range chain {
+		byID[b.BackupID] = b
+	}
+	var intact func(b BackupChainEntry, depth int) bool
+	intact = func(b BackupChainEntry, depth int) bool {
+		if !b.Usable || depth > len(chain) {
+			return false
+		}
+		if b.Type != "incremental" {
+			return true
+		}
+		parent, ok := byID[b.ParentID]
+		return ok && intact(parent, depth+1)
+	}
+
+	var window RecoveryWindow
+	for _, b := range chain {
+		if !intact(b, 0) {
+			continue
+		}
+		r := TimeRange{Start: b.ConsistentAt, End: b.ConsistentAt}
+		for _, c := range coverage {
+			if c.Contains(b.ConsistentAt) {
+				r.End = c.End
+				break
+			}
+		}
+		window = append(window, r)
+	}
+	return window.merge()
+}
+
+func (s *archiveService) ListArchiveStreams(ctx context.Context, clusterID string) ([]ArchiveStream, error) {
+	request, err := s.Client.newRequest("get", fmt.Sprintf("/api/v2/clusters/%s/archive-streams", clusterID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var streams []ArchiveStream
+	err = s.do(ctx, request, &streams)
+	if err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+func (s *archiveService) GetArchiveStream(ctx context.Context, clusterID, streamID string) (*ArchiveStream, error) {
+	request, err := s.Client.newRequest("get", fmt.Sprintf("/api/v2/clusters/%s/archive-streams/%s", clusterID, streamID), nil)
+	if err != nil {
+		return nil, err
+	}
+	stream := &ArchiveStream{}
+	err = s.do(ctx, request, stream)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *archiveService) BackupChain(ctx context.Context, clusterID string) ([]BackupChainEntry, error) {
+	request, err := s.Client.newRequest("get", fmt.Sprintf("/api/v2/clusters/%s/backup-chain", clusterID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var chain []BackupChainEntry
+	err = s.do(ctx, request, &chain)
+	if err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+func (s *archiveService) RecoverableWindow(ctx context.Context, clusterID string) (RecoveryWindow, error) {
+	chain, err := s.BackupChain(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	streams, err := s.ListArchiveStreams(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return recoverableWindow(chain, streams), nil
+}
@@ -0,0 +1,61 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecoverableWindow(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 6, 1, hour, 0, 0, 0, time.UTC) }
+	pos := func(hour int) ArchivePosition { return ArchivePosition{Time: at(hour)} }
+
+	chain := []BackupChainEntry{
+		{BackupID: "full-1", Type: "full", ConsistentAt: at(1), Usable: true},
+		{BackupID: "inc-1", Type: "incremental", ParentID: "full-1", ConsistentAt: at(5), Usable: true},
+		{BackupID: "full-2", Type: "full", ConsistentAt: at(12), Usable: true},
+		// parent expired: not restorable
+		{BackupID: "inc-2", Type: "incremental", ParentID: "gone", ConsistentAt: at(15), Usable: true},
+		{BackupID: "full-3", Type: "full", ConsistentAt: at(20), Usable: false},
+		{BackupID: "full-4", Type: "full", ConsistentAt: at(22), Usable: true},
+	}
+	streams := []ArchiveStream{
+		// binlogs from 00:00 to 10:00 with 03:00-04:00 missing
+		{EarliestRecoverable: at(0), LatestRecoverable: at(10), Gaps: []ArchiveGap{{From: pos(3), To: pos(4)}}},
+		// the new primary after a failover archives from 11:00 on
+		{EarliestRecoverable: at(11), LatestRecoverable: at(18)},
+	}
+
+	got := recoverableWindow(chain, streams)
+	want := RecoveryWindow{
+		{Start: at(1), End: at(3)},
+		{Start: at(5), End: at(10)},
+		{Start: at(12), End: at(18)},
+		{Start: at(22), End: at(22)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("window =\n%v\nwant\n%v", got, want)
+	}
+
+	if !got.Contains(at(7)) || got.Contains(at(4)) || got.Contains(at(19)) || !got.Contains(at(22)) {
+		t.Error("Contains disagrees with the window")
+	}
+	if latest, ok := got.Latest(); !ok || !latest.Equal(at(22)) {
+		t.Errorf("Latest = %v, %v", latest, ok)
+	}
+}
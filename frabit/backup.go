@@ -20,6 +20,29 @@ import "context"
 type BackupService interface {
 	GetBackup(ctx context.Context) (*Backup, error)
 	CreateBackup(ctx context.Context, req CreateBackupRequest) (*Backup, error)
+
+	// Verify starts a verification of the backup. Checksum verifications
+	// usually finish right away; poll the others with GetVerification.
+	Verify(ctx context.Context, backupID string, mode VerifyMode) (*VerificationReport, error)
+	GetVerification(ctx context.Context, backupID, verificationID string) (*VerificationReport, error)
+	ListVerifications(ctx context.Context, backupID string) ([]VerificationReport, error)
+
+	ListVerificationSchedules(ctx context.Context, policyID string) ([]VerificationSchedule, error)
+	CreateVerificationSchedule(ctx context.Context, req CreateVerificationScheduleRequest) (*VerificationSchedule, error)
+	DeleteVerificationSchedule(ctx context.Context, policyID, scheduleID string) error
+
+	// Copy copies the backup to another storage. The copy is kept until it
+	// is deleted; use CopyWithRetention to let it expire.
+	Copy(ctx context.Context, backupID, destinationStorage string) (*OperationHandle, error)
+	CopyWithRetention(ctx context.Context, backupID string, req CopyBackupRequest) (*OperationHandle, error)
+	GetOperation(ctx context.Context, operationID string) (*Operation, error)
+	ListCopies(ctx context.Context, backupID string) ([]BackupCopy, error)
+	SetCopyRetention(ctx context.Context, backupID, copyID string, retentionDays int) (*BackupCopy, error)
+	DeleteCopy(ctx context.Context, backupID, copyID string) error
+
+	ListCopyRules(ctx context.Context, policyID string) ([]BackupCopyRule, error)
+	CreateCopyRule(ctx context.Context, req CreateCopyRuleRequest) (*BackupCopyRule, error)
+	DeleteCopyRule(ctx context.Context, policyID, ruleID string) error
 }
 
 type backupService struct {
@@ -27,19 +50,30 @@ type backupService struct {
 }
 
 type Backup struct {
+	ID        string `json:"id"`
 	Workspace string `json:"workspace"`
 	Name      string `json:"name"`
 	Owner     string `json:"owner"`
+	// Compression is the algorithm the artifact was compressed with
+	Compression string `json:"compression,omitempty"`
+	// KeyID names the master key wrapping the artifact's data key; empty when
+	// the artifact is not encrypted
+	KeyID string `json:"key_id,omitempty"`
+	// Checksum is "sha256:<hex>" of the stored artifact
+	Checksum string `json:"checksum,omitempty"`
 }
 
 type CreateBackupRequest struct {
-	Workspace string `json:"workspace"`
-	Name      string `json:"name"`
-	Owner     string `json:"owner"`
+	Workspace   string `json:"workspace"`
+	Name        string `json:"name"`
+	Owner       string `json:"owner"`
+	Compression string `json:"compression,omitempty"`
+	KeyID       string `json:"key_id,omitempty"`
+	Checksum    string `json:"checksum,omitempty"`
 }
 
 func (u *backupService) GetBackup(ctx context.Context) (*Backup, error) {
-	req, _ := u.Client.newRequest("get", "database", nil)
+	req, _ := u.Client.newRequest("get", "backup", nil)
 	cls := &Backup{}
 	err := u.Client.do(ctx, req, cls)
 	if err != nil {
@@ -50,7 +84,7 @@ func (u *backupService) GetBackup(ctx context.Context) (*Backup, error) {
 }
 
 func (u *backupService) CreateBackup(ctx context.Context, CreateReq CreateBackupRequest) (*Backup, error) {
-	req, _ := u.Client.newRequest("post", "database", CreateReq)
+	req, _ := u.Client.newRequest("post", "backup", CreateReq)
 	user := &Backup{}
 	err := u.Client.do(ctx, req, user)
 	if err != nil {
@@ -0,0 +1,167 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BackupCopy is a copy of a backup kept in another storage, e.g. another
+// region. Copies expire on their own retention, independent of the backup
+// they were copied from.
+type BackupCopy struct {
+	ID       string          `json:"id"`
+	BackupID string          `json:"backup_id"`
+	Storage  string          `json:"storage"`
+	Key      string          `json:"key"`
+	Status   OperationStatus `json:"status"`
+	Checksum string          `json:"checksum,omitempty"`
+	Bytes    int64           `json:"bytes"`
+	// RetentionDays of 0 keeps the copy until it is deleted.
+	RetentionDays int       `json:"retention_days"`
+	ExpiresAt     time.Time `json:"expires_at,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type CopyBackupRequest struct {
+	DestinationStorage string `json:"destination_storage"`
+	RetentionDays      int    `json:"retention_days,omitempty"`
+}
+
+// BackupCopyRule copies every new backup of a policy that matches
+// BackupTypes to DestinationStorage.
+type BackupCopyRule struct {
+	ID                 string `json:"id"`
+	PolicyID           string `json:"policy_id"`
+	DestinationStorage string `json:"destination_storage"`
+	// BackupTypes limits the rule, e.g. to "full" backups; empty copies all.
+	BackupTypes   []string `json:"backup_types"`
+	RetentionDays int      `json:"retention_days"`
+	Enabled       bool     `json:"enabled"`
+	CreatedAt     string   `json:"created_at"`
+}
+
+type CreateCopyRuleRequest struct {
+	PolicyID           string   `json:"-"`
+	DestinationStorage string   `json:"destination_storage"`
+	BackupTypes        []string `json:"backup_types,omitempty"`
+	RetentionDays      int      `json:"retention_days"`
+	Enabled            bool     `json:"enabled"`
+}
+
+type updateCopyRequest struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+func (u *backupService) Copy(ctx context.Context, backupID, destinationStorage string) (*OperationHandle, error) {
+	return u.CopyWithRetention(ctx, backupID, CopyBackupRequest{DestinationStorage: destinationStorage})
+}
+
+func (u *backupService) CopyWithRetention(ctx context.Context, backupID string, req CopyBackupRequest) (*OperationHandle, error) {
+	request, err := u.Client.newRequest("post", fmt.Sprintf("/api/v2/backups/%s/copies", backupID), req)
+	if err != nil {
+		return nil, err
+	}
+	handle := &OperationHandle{get: u.GetOperation}
+	err = u.do(ctx, request, &handle.Operation)
+	if err != nil {
+		return nil, err
+	}
+	return handle, nil
+}
+
+func (u *backupService) GetOperation(ctx context.Context, operationID string) (*Operation, error) {
+	request, err := u.Client.newRequest("get", fmt.Sprintf("/api/v2/operations/%s", operationID), nil)
+	if err != nil {
+		return nil, err
+	}
+	op := &Operation{}
+	err = u.do(ctx, request, op)
+	if err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (u *backupService) ListCopies(ctx context.Context, backupID string) ([]BackupCopy, error) {
+	request, err := u.Client.newRequest("get", fmt.Sprintf("/api/v2/backups/%s/copies", backupID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var copies []BackupCopy
+	err = u.do(ctx, request, &copies)
+	if err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
+
+func (u *backupService) SetCopyRetention(ctx context.Context, backupID, copyID string, retentionDays int) (*BackupCopy, error) {
+	request, err := u.Client.newRequest("patch", fmt.Sprintf("/api/v2/backups/%s/copies/%s", backupID, copyID), updateCopyRequest{RetentionDays: retentionDays})
+	if err != nil {
+		return nil, err
+	}
+	backupCopy := &BackupCopy{}
+	err = u.do(ctx, request, backupCopy)
+	if err != nil {
+		return nil, err
+	}
+	return backupCopy, nil
+}
+
+func (u *backupService) DeleteCopy(ctx context.Context, backupID, copyID string) error {
+	request, err := u.Client.newRequest("delete", fmt.Sprintf("/api/v2/backups/%s/copies/%s", backupID, copyID), nil)
+	if err != nil {
+		return err
+	}
+	return u.do(ctx, request, nil)
+}
+
+func (u *backupService) ListCopyRules(ctx context.Context, policyID string) ([]BackupCopyRule, error) {
+	request, err := u.Client.newRequest("get", fmt.Sprintf("/api/v2/backup-policies/%s/copy-rules", policyID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var rules []BackupCopyRule
+	err = u.do(ctx, request, &rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (u *backupService) CreateCopyRule(ctx context.Context, req CreateCopyRuleRequest) (*BackupCopyRule, error) {
+	request, err := u.Client.newRequest("post", fmt.Sprintf("/api/v2/backup-policies/%s/copy-rules", req.PolicyID), req)
+	if err != nil {
+		return nil, err
+	}
+	rule := &BackupCopyRule{}
+	err = u.do(ctx, request, rule)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (u *backupService) DeleteCopyRule(ctx context.Context, policyID, ruleID string) error {
+	request, err := u.Client.newRequest("delete", fmt.Sprintf("/api/v2/backup-policies/%s/copy-rules/%s", policyID, ruleID), nil)
+	if err != nil {
+		return err
+	}
+	return u.do(ctx, request, nil)
+}
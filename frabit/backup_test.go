@@ -14,3 +14,56 @@
 // limitations under the License.
 
 package frabit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCopyWait(t *testing.T) {
+	operationPollMin, operationPollMax = time.Millisecond, 5*time.Millisecond
+	defer func() { operationPollMin, operationPollMax = time.Second, 30*time.Second }()
+
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v2/backups/b1/copies":
+			var req CopyBackupRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.DestinationStorage != "s3-eu-west-1" {
+				http.Error(w, "bad destination", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(Operation{ID: "op1", Status: OperationPending})
+		case r.URL.Path == "/api/v2/operations/op1":
+			status := OperationRunning
+			if polls.Add(1) >= 3 {
+				status = OperationSucceeded
+			}
+			json.NewEncoder(w).Encode(Operation{ID: "op1", Status: status, ResourceID: "copy-1"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err := client.Backup.Copy(context.Background(), "b1", "s3-eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := op.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if op.ResourceID != "copy-1" || polls.Load() != 3 {
+		t.Fatalf("operation = %+v after %d polls", op.Operation, polls.Load())
+	}
+}
@@ -18,12 +18,15 @@ package frabit
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/hashicorp/go-cleanhttp"
 )
@@ -38,6 +41,8 @@ const (
 	ErrInternal          ErrorCode = "internal"
 	ErrInvalid           ErrorCode = "invalid"
 	ErrNotFound          ErrorCode = "not_found"
+	ErrUnauthorized      ErrorCode = "unauthorized"
+	ErrConflict          ErrorCode = "conflict"
 	ErrResponseMalformed ErrorCode = "response_malformed"
 )
 
@@ -47,12 +52,31 @@ type Client struct {
 	UserAgent string
 	Token     string
 	Headers   map[string]string
+	// Workspace is the default workspace of the profile the client was
+	// built from.
+	Workspace string
+	tls       *tls.Config
+	transport *transportSettings
+	config    *Config
+
+	credentials     CredentialsProvider
+	middlewares     []Middleware
+	callMiddlewares []CallMiddleware
+
+	logger         *slog.Logger
+	redactedFields []string
 
 	// services used for communicate with the Frabit API
-	Database DatabaseService
-	Org      OrgService
-	Team     TeamService
-	Agent    AgentService
+	Database     DatabaseService
+	Org          OrgService
+	Team         TeamService
+	Cluster      ClusterService
+	Restore      RestoreService
+	Agent        AgentService
+	Backup       BackupService
+	Archive      ArchiveService
+	Notification NotificationService
+	User         UserService
 }
 
 type service struct {
@@ -61,6 +85,8 @@ type service struct {
 
 type ClientOption func(client *Client) error
 
+// WithBaseURL sets the address of the Frabit API. A unix:///path/to.sock
+// address talks to a local agent over a unix socket.
 func WithBaseURL(baseUrl string) ClientOption {
 	return func(c *Client) error {
 		ParseURL, err := url.Parse(baseUrl)
@@ -69,6 +95,10 @@ func WithBaseURL(baseUrl string) ClientOption {
 		}
 
 		c.BaseURL = ParseURL
+		if ParseURL.Scheme == "unix" {
+			c.withUnixSocket(ParseURL.Path)
+		}
+		c.overrideConfig("base_url", func(cfg *Config) { cfg.BaseURL = baseUrl })
 		return nil
 	}
 }
@@ -76,6 +106,8 @@ func WithBaseURL(baseUrl string) ClientOption {
 func WithToken(token string) ClientOption {
 	return func(c *Client) error {
 		c.Token = token
+		c.credentials = nil
+		c.overrideConfig("token", func(cfg *Config) { cfg.Token, cfg.TokenCommand = token, "" })
 		return nil
 	}
 }
@@ -111,30 +143,114 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 		}
 	}
 
+	if c.BaseURL == nil && c.config != nil {
+		return nil, fmt.Errorf("frabit: profile %q has no base_url and $%s is not set", c.config.Profile, EnvBaseURL)
+	}
+
+	if err := c.applyTransportConfig(); err != nil {
+		return nil, err
+	}
+
 	c.Database = &databaseService{c}
 	c.Org = &orgService{c}
 	c.Team = &teamService{c}
+	c.Cluster = &clusterService{c}
+	c.Restore = &restoreService{c}
 	c.Agent = &agentService{c}
+	c.Backup = &backupService{c}
+	c.Archive = &archiveService{c}
+	c.Notification = &notificationService{c}
+	c.User = &userService{c}
 
 	return c, nil
 }
 
+// HTTPClient returns the HTTP client requests are sent with, for talking to
+// endpoints outside the API such as the OAuth2 ones.
+func (c *Client) HTTPClient() *http.Client {
+	return c.client
+}
+
 func (c *Client) do(ctx context.Context, req *http.Request, body interface{}) error {
+	call, ok := CallFromContext(ctx)
+	if !ok {
+		call = callerCall()
+	}
+	call.Attempt = 1
+	send := CallFunc(func(ctx context.Context) error {
+		return c.send(ctx, req, body)
+	})
+	for i := len(c.callMiddlewares) - 1; i >= 0; i-- {
+		send = c.callMiddlewares[i](send)
+	}
+	return send(context.WithValue(ctx, callKey{}, call))
+}
+
+// send makes the requests of one call: req and, after a 401, its resend.
+func (c *Client) send(ctx context.Context, req *http.Request, body interface{}) error {
+	call, _ := CallFromContext(ctx)
 	req = req.WithContext(ctx)
-	resp, err := c.client.Do(req)
+	creds := c.credentialsProvider()
+	resp, token, err := c.sendRequest(req, creds)
 	if err != nil {
 		return err
 	}
+
+	// the token may have been revoked or rotated, retry once with a fresh one
+	if resp.StatusCode == http.StatusUnauthorized && token != "" {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		creds.Invalidate(token)
+		call.Attempt++
+		if req, err = rewind(req.WithContext(context.WithValue(ctx, callKey{}, call))); err != nil {
+			return err
+		}
+		if resp, _, err = c.sendRequest(req, creds); err != nil {
+			return err
+		}
+	}
 	defer resp.Body.Close()
 
 	return c.handleResponse(ctx, resp, body)
 }
 
+// sendRequest authenticates req with a token from creds, if any, and sends it.
+func (c *Client) sendRequest(req *http.Request, creds CredentialsProvider) (*http.Response, string, error) {
+	var token string
+	if creds != nil {
+		var err error
+		if token, err = creds.Token(req.Context()); err != nil {
+			return nil, "", fmt.Errorf("frabit: obtaining credentials: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.roundTrip()(req)
+	return resp, token, err
+}
+
+// rewind returns a copy of req with a fresh body, for sending it again.
+func rewind(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return retry, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("frabit: request body cannot be replayed")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retry.Body = body
+	return retry, nil
+}
+
 func (c *Client) newRequest(method string, path string, body interface{}) (*http.Request, error) {
 	addr, err := c.BaseURL.Parse(path)
 	if err != nil {
 		return nil, err
 	}
+	method = strings.ToUpper(method)
 	var req *http.Request
 	switch method {
 	case http.MethodGet:
@@ -145,13 +261,12 @@ func (c *Client) newRequest(method string, path string, body interface{}) (*http
 	default:
 		buf := new(bytes.Buffer)
 		if body != nil {
-			err := json.NewDecoder(buf).Decode(body)
+			err := json.NewEncoder(buf).Encode(body)
 			if err != nil {
 				return nil, err
 			}
 		}
 		req, err = http.NewRequest(method, addr.String(), buf)
-		fmt.Printf("request detail:%s", req.RequestURI)
 		if err != nil {
 			return nil, err
 		}
@@ -177,7 +292,20 @@ func (c *Client) handleResponse(ctx context.Context, resp *http.Response, body i
 
 	// check http status
 	if resp.StatusCode >= 400 {
-		return nil
+		apiErr := &Error{
+			msg:  fmt.Sprintf("request failed with status %d", resp.StatusCode),
+			Code: ErrorCodeFromStatus(resp.StatusCode),
+			Meta: map[string]string{
+				"body":        string(out),
+				"http_status": http.StatusText(resp.StatusCode),
+			},
+		}
+		if id := resp.Header.Get(RequestIDHeader); id != "" {
+			apiErr.Meta["request_id"] = id
+		} else if resp.Request != nil && resp.Request.Header.Get(RequestIDHeader) != "" {
+			apiErr.Meta["request_id"] = resp.Request.Header.Get(RequestIDHeader)
+		}
+		return apiErr
 	}
 
 	if body == nil || resp.StatusCode == http.StatusNoContent {
@@ -208,3 +336,28 @@ type Error struct {
 }
 
 func (e Error) Error() string { return e.msg }
+
+// IsErrorCode reports whether err is a Frabit API error carrying the given code.
+func IsErrorCode(err error, code ErrorCode) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == code
+	}
+	return false
+}
+
+// ErrorCodeFromStatus is the code of the Error returned for an HTTP status.
+func ErrorCodeFromStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrInvalid
+	default:
+		return ErrInternal
+	}
+}
@@ -18,8 +18,8 @@ package frabit
 import "context"
 
 type ClusterService interface {
-	GetTeam(ctx context.Context) (*Cluster, error)
-	CreateTeam(ctx context.Context, req CreateClusterRequest) (*Cluster, error)
+	GetCluster(ctx context.Context) (*Cluster, error)
+	CreateCluster(ctx context.Context, req CreateClusterRequest) (*Cluster, error)
 }
 
 type clusterService struct {
@@ -38,8 +38,8 @@ type CreateClusterRequest struct {
 	Owner     string `json:"owner"`
 }
 
-func (u *clusterService) GetUser(ctx context.Context) (*Cluster, error) {
-	req, _ := u.Client.newRequest("get", "database", nil)
+func (u *clusterService) GetCluster(ctx context.Context) (*Cluster, error) {
+	req, _ := u.Client.newRequest("get", "cluster", nil)
 	cls := &Cluster{}
 	err := u.Client.do(ctx, req, cls)
 	if err != nil {
@@ -49,13 +49,13 @@ func (u *clusterService) GetUser(ctx context.Context) (*Cluster, error) {
 	return cls, nil
 }
 
-func (u *clusterService) CreateUser(ctx context.Context, CreateReq CreateClusterRequest) (*User, error) {
-	req, _ := u.Client.newRequest("post", "database", CreateReq)
-	user := &User{}
-	err := u.Client.do(ctx, req, user)
+func (u *clusterService) CreateCluster(ctx context.Context, CreateReq CreateClusterRequest) (*Cluster, error) {
+	req, _ := u.Client.newRequest("post", "cluster", CreateReq)
+	cls := &Cluster{}
+	err := u.Client.do(ctx, req, cls)
 	if err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return cls, nil
 }
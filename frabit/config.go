@@ -0,0 +1,420 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by LoadConfig. Each overrides the value of the
+// same name in the selected profile.
+const (
+	EnvConfig       = "FRABIT_CONFIG"
+	EnvProfile      = "FRABIT_PROFILE"
+	EnvBaseURL      = "FRABIT_BASE_URL"
+	EnvToken        = "FRABIT_TOKEN"
+	EnvTokenCommand = "FRABIT_TOKEN_COMMAND"
+	EnvCAFile       = "FRABIT_CA_FILE"
+	EnvTimeout      = "FRABIT_TIMEOUT"
+	EnvWorkspace    = "FRABIT_WORKSPACE"
+)
+
+// DefaultProfile is used when neither the caller, $FRABIT_PROFILE nor the
+// config file's current_profile name one.
+const DefaultProfile = "default"
+
+// Profile is one named entry of the config file. Its Timeout is applied
+// with WithTimeout, so it also bounds long polls.
+type Profile struct {
+	BaseURL      string        `yaml:"base_url,omitempty"`
+	Token        string        `yaml:"token,omitempty"`
+	TokenCommand string        `yaml:"token_command,omitempty"`
+	CAFile       string        `yaml:"ca_file,omitempty"`
+	Timeout      time.Duration `yaml:"timeout,omitempty"`
+	Workspace    string        `yaml:"workspace,omitempty"`
+
+	// Set by a device login: Token is then an access token that is renewed
+	// with the refresh token once it expires.
+	RefreshToken string    `yaml:"refresh_token,omitempty"`
+	TokenExpiry  time.Time `yaml:"token_expiry,omitempty"`
+	ClientID     string    `yaml:"client_id,omitempty"`
+}
+
+// ConfigFile is the layout of ~/.frabit/config.yaml:
+//
+//	current_profile: prod
+//	profiles:
+//	  prod:
+//	    base_url: https://frabit.example.com
+//	    token_command: vault read -field=token secret/frabit
+//	    ca_file: /etc/frabit/ca.pem
+//	    timeout: 30s
+//	    workspace: payments
+type ConfigFile struct {
+	CurrentProfile string             `yaml:"current_profile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// SourceKind says where a configuration value came from.
+type SourceKind string
+
+const (
+	SourceDefault SourceKind = "default"
+	SourceFile    SourceKind = "file"
+	SourceEnv     SourceKind = "env"
+	SourceOption  SourceKind = "option"
+)
+
+// Source is the origin of one configuration value. Name is the config file
+// path for SourceFile and the variable name for SourceEnv.
+type Source struct {
+	Kind    SourceKind
+	Name    string
+	Profile string
+}
+
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceFile:
+		return fmt.Sprintf("%s (profile %s)", s.Name, s.Profile)
+	case SourceEnv:
+		return "$" + s.Name
+	case SourceOption:
+		return "client option"
+	default:
+		return string(SourceDefault)
+	}
+}
+
+// Config is a profile resolved against the environment. Source reports for
+// each key, named as in the config file, where its value came from.
+type Config struct {
+	// Path is the config file read, empty if there was none.
+	Path    string
+	Profile string
+
+	BaseURL      string
+	Token        string
+	TokenCommand string
+	CAFile       string
+	Timeout      time.Duration
+	Workspace    string
+
+	RefreshToken string
+	TokenExpiry  time.Time
+	ClientID     string
+
+	sources map[string]Source
+}
+
+// ConfigKeys lists the keys Source accepts, in display order.
+var ConfigKeys = []string{"profile", "base_url", "token", "token_command", "ca_file", "timeout", "workspace"}
+
+// Source returns where the value of key came from.
+func (c *Config) Source(key string) Source {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	return Source{Kind: SourceDefault}
+}
+
+func (c *Config) set(key string, s Source) {
+	if c.sources == nil {
+		c.sources = make(map[string]Source)
+	}
+	c.sources[key] = s
+}
+
+// DefaultConfigPath returns $FRABIT_CONFIG, or ~/.frabit/config.yaml.
+func DefaultConfigPath() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".frabit", "config.yaml"), nil
+}
+
+// ReadConfigFile parses the config file at path. Unknown keys are rejected so
+// that a misspelt setting does not silently fall back to its default.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f ConfigFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("frabit: parsing %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// LoadConfig resolves the named profile. An empty name selects
+// $FRABIT_PROFILE, then the file's current_profile, then DefaultProfile.
+// The FRABIT_* variables are laid over the profile, so the environment wins
+// over the file. A missing config file is only an error when it was named
+// by $FRABIT_CONFIG or a profile was asked for explicitly.
+func LoadConfig(name string) (*Config, error) {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+
+	profileSource := Source{Kind: SourceOption}
+	if name == "" {
+		if name = os.Getenv(EnvProfile); name != "" {
+			profileSource = Source{Kind: SourceEnv, Name: EnvProfile}
+		}
+	}
+	explicit := name != ""
+
+	file, err := ReadConfigFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !explicit && os.Getenv(EnvConfig) == "":
+		file = &ConfigFile{}
+	case err != nil:
+		return nil, err
+	default:
+		cfg.Path = path
+	}
+
+	if name == "" && file.CurrentProfile != "" {
+		name = file.CurrentProfile
+		profileSource = Source{Kind: SourceFile, Name: path, Profile: name}
+	}
+	if name == "" {
+		name, profileSource = DefaultProfile, Source{Kind: SourceDefault}
+	}
+	cfg.Profile = name
+	cfg.set("profile", profileSource)
+
+	profile, ok := file.Profiles[name]
+	if !ok && explicit {
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("frabit: profile %q requested but %s does not exist", name, path)
+		}
+		return nil, fmt.Errorf("frabit: profile %q not found in %s", name, path)
+	}
+	if ok {
+		cfg.applyProfile(profile, Source{Kind: SourceFile, Name: path, Profile: name})
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyProfile(p Profile, src Source) {
+	c.setString("base_url", &c.BaseURL, p.BaseURL, src)
+	c.setToken(p.Token, p.TokenCommand, src)
+	if p.RefreshToken != "" {
+		c.RefreshToken, c.TokenExpiry, c.ClientID = p.RefreshToken, p.TokenExpiry, p.ClientID
+	}
+	c.setString("ca_file", &c.CAFile, p.CAFile, src)
+	c.setString("workspace", &c.Workspace, p.Workspace, src)
+	if p.Timeout != 0 {
+		c.Timeout = p.Timeout
+		c.set("timeout", src)
+	}
+}
+
+func (c *Config) applyEnv() error {
+	env := func(key string) Source { return Source{Kind: SourceEnv, Name: key} }
+	c.setString("base_url", &c.BaseURL, os.Getenv(EnvBaseURL), env(EnvBaseURL))
+	if token := os.Getenv(EnvToken); token != "" {
+		c.setToken(token, "", env(EnvToken))
+	} else if command := os.Getenv(EnvTokenCommand); command != "" {
+		c.setToken("", command, env(EnvTokenCommand))
+	}
+	c.setString("ca_file", &c.CAFile, os.Getenv(EnvCAFile), env(EnvCAFile))
+	c.setString("workspace", &c.Workspace, os.Getenv(EnvWorkspace), env(EnvWorkspace))
+	if v := os.Getenv(EnvTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("frabit: invalid $%s: %w", EnvTimeout, err)
+		}
+		c.Timeout = d
+		c.set("timeout", env(EnvTimeout))
+	}
+	return nil
+}
+
+func (c *Config) setString(key string, field *string, value string, src Source) {
+	if value != "" {
+		*field = value
+		c.set(key, src)
+	}
+}
+
+// setToken replaces both token settings at once: a token from a higher
+// precedence source also overrides a token command from a lower one.
+func (c *Config) setToken(token, command string, src Source) {
+	if token == "" && command == "" {
+		return
+	}
+	c.Token, c.TokenCommand = token, command
+	c.RefreshToken, c.TokenExpiry, c.ClientID = "", time.Time{}, ""
+	delete(c.sources, "token")
+	delete(c.sources, "token_command")
+	if token != "" {
+		c.set("token", src)
+	} else {
+		c.set("token_command", src)
+	}
+}
+
+// apply configures c from the resolved profile. A token command is run when
+// the first request needs a token.
+func (cfg *Config) apply(c *Client) error {
+	if cfg.BaseURL != "" {
+		if err := WithBaseURL(cfg.BaseURL)(c); err != nil {
+			return err
+		}
+	}
+	c.Token = cfg.Token
+	c.credentials = nil
+	switch {
+	case cfg.TokenCommand != "":
+		c.credentials = shellCredentials(cfg.TokenCommand)
+	case cfg.RefreshToken != "":
+		c.credentials = cfg.refreshCredentials(c)
+	}
+	if cfg.CAFile != "" {
+		if err := WithRootCAs(cfg.CAFile)(c); err != nil {
+			return err
+		}
+	}
+	if cfg.Timeout != 0 {
+		if err := WithTimeout(cfg.Timeout)(c); err != nil {
+			return err
+		}
+	}
+	c.Workspace = cfg.Workspace
+	c.config = cfg
+	return nil
+}
+
+// refreshCredentials renews the profile's access token with its refresh
+// token and writes the new tokens back to the config file.
+func (cfg *Config) refreshCredentials(c *Client) CredentialsProvider {
+	r := &refreshToken{
+		clientID: cfg.ClientID,
+		refresh:  cfg.RefreshToken,
+		client:   c,
+	}
+	if cfg.Path != "" {
+		path, name := cfg.Path, cfg.Profile
+		r.save = func(tok *oauth2Token, expiry time.Time) error {
+			return UpdateProfile(path, name, func(p *Profile) {
+				p.Token, p.RefreshToken, p.TokenExpiry = tok.AccessToken, tok.RefreshToken, expiry
+			})
+		}
+	}
+	return &cachedToken{now: time.Now, fetch: r.fetch, token: cfg.Token, expiry: cfg.TokenExpiry}
+}
+
+// UpdateProfile applies update to the named profile of the config file at
+// path, creating the file and the profile as needed. The file is rewritten
+// as a whole, so comments in it are lost.
+func UpdateProfile(path, name string, update func(p *Profile)) error {
+	file, err := ReadConfigFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = &ConfigFile{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if file.Profiles == nil {
+		file.Profiles = make(map[string]Profile)
+	}
+	profile := file.Profiles[name]
+	update(&profile)
+	file.Profiles[name] = profile
+	return WriteConfigFile(path, file)
+}
+
+// WriteConfigFile atomically replaces the config file at path. It holds
+// credentials, so it is only readable by its owner.
+func WriteConfigFile(path string, file *ConfigFile) error {
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".config-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// WithProfile configures the client from the named profile of the config
+// file with the environment laid over it, see LoadConfig. Options after it
+// override the profile; NewClient fails if none of them sets a base URL.
+func WithProfile(name string) ClientOption {
+	return func(c *Client) error {
+		cfg, err := LoadConfig(name)
+		if err != nil {
+			return err
+		}
+		return cfg.apply(c)
+	}
+}
+
+// NewClientFromConfig is NewClient configured from the profile selected by
+// $FRABIT_PROFILE or the config file, followed by opts.
+func NewClientFromConfig(opts ...ClientOption) (*Client, error) {
+	return NewClient(append([]ClientOption{WithProfile("")}, opts...)...)
+}
+
+// Config returns the configuration the client was built from, or nil when
+// it was not built from a profile.
+func (c *Client) Config() *Config {
+	return c.config
+}
+
+// overrideConfig records that an option replaced the profile's value of key.
+func (c *Client) overrideConfig(key string, update func(cfg *Config)) {
+	if c.config != nil {
+		update(c.config)
+		c.config.set(key, Source{Kind: SourceOption})
+	}
+}
@@ -0,0 +1,172 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+current_profile: staging
+profiles:
+  staging:
+    base_url: https://staging.example.com
+    token: file-token
+    timeout: 15s
+    workspace: payments
+  prod:
+    base_url: https://prod.example.com
+    token_command: echo command-token
+`
+
+// isolateConfig points the config at a fresh file and clears the FRABIT_*
+// variables of the test environment.
+func isolateConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if content != "" {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv(EnvConfig, path)
+	for _, key := range []string{EnvProfile, EnvBaseURL, EnvToken, EnvTokenCommand, EnvCAFile, EnvTimeout, EnvWorkspace} {
+		t.Setenv(key, "")
+	}
+	return path
+}
+
+func TestLoadConfigSources(t *testing.T) {
+	path := isolateConfig(t, testConfig)
+	t.Setenv(EnvWorkspace, "ledger")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Profile != "staging" || cfg.BaseURL != "https://staging.example.com" || cfg.Token != "file-token" || cfg.Timeout != 15*time.Second {
+		t.Errorf("config = %+v", cfg)
+	}
+	if cfg.Workspace != "ledger" || cfg.Source("workspace") != (Source{Kind: SourceEnv, Name: EnvWorkspace}) {
+		t.Errorf("workspace = %q from %v", cfg.Workspace, cfg.Source("workspace"))
+	}
+	if got := cfg.Source("base_url"); got != (Source{Kind: SourceFile, Name: path, Profile: "staging"}) {
+		t.Errorf("base_url source = %v", got)
+	}
+	if got := cfg.Source("ca_file"); got.Kind != SourceDefault {
+		t.Errorf("ca_file source = %v", got)
+	}
+
+	// a token from the environment replaces the profile's token command
+	t.Setenv(EnvToken, "env-token")
+	cfg, err = LoadConfig("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "env-token" || cfg.TokenCommand != "" || cfg.Source("token").Name != EnvToken || cfg.Source("token_command").Kind != SourceDefault {
+		t.Errorf("token = %q/%q from %v", cfg.Token, cfg.TokenCommand, cfg.Source("token"))
+	}
+	if cfg.Source("profile").Kind != SourceOption {
+		t.Errorf("profile source = %v", cfg.Source("profile"))
+	}
+
+	if _, err := LoadConfig("missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing profile: %v", err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	isolateConfig(t, "profiles:\n  default:\n    base_uri: https://typo.example.com\n")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "base_uri") {
+		t.Errorf("unknown key: %v", err)
+	}
+
+	isolateConfig(t, "")
+	if _, err := LoadConfig(""); err == nil {
+		t.Error("missing $FRABIT_CONFIG file accepted")
+	}
+
+	t.Setenv(EnvConfig, "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(EnvBaseURL, "https://env.example.com")
+	t.Setenv(EnvTimeout, "soon")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), EnvTimeout) {
+		t.Errorf("bad timeout: %v", err)
+	}
+	t.Setenv(EnvTimeout, "")
+	cfg, err := LoadConfig("")
+	if err != nil || cfg.Path != "" || cfg.Profile != DefaultProfile || cfg.BaseURL != "https://env.example.com" {
+		t.Errorf("no config file: %+v, %v", cfg, err)
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	isolateConfig(t, testConfig)
+
+	c, err := NewClient(WithProfile("prod"), WithBaseURL("https://override.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := c.credentialsProvider().Token(context.Background())
+	if err != nil || token != "command-token" || c.Config().Source("token_command").Kind != SourceFile {
+		t.Errorf("token = %q, %v from %v", token, err, c.Config().Source("token_command"))
+	}
+	if c.BaseURL.Host != "override.example.com" || c.Config().BaseURL != "https://override.example.com" || c.Config().Source("base_url").Kind != SourceOption {
+		t.Errorf("base url = %s from %v", c.BaseURL, c.Config().Source("base_url"))
+	}
+
+	c, err = NewClientFromConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Workspace != "payments" || c.client.Timeout != 15*time.Second {
+		t.Errorf("workspace = %q, timeout = %v", c.Workspace, c.client.Timeout)
+	}
+
+	isolateConfig(t, "profiles:\n  default:\n    workspace: payments\n")
+	if _, err := NewClientFromConfig(); err == nil || !strings.Contains(err.Error(), "base_url") {
+		t.Errorf("no base url: %v", err)
+	}
+}
+
+func TestRefreshTokenUsesFinalBaseURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != OAuth2TokenPath || r.FormValue("refresh_token") != "refresh-1" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access-2", "expires_in": 3600})
+	}))
+	defer srv.Close()
+	// a profile from a login whose base URL comes from an option instead
+	isolateConfig(t, "profiles:\n  default:\n    refresh_token: refresh-1\n")
+
+	c, err := NewClient(WithProfile(""), WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := c.credentialsProvider().Token(context.Background()); err != nil || token != "access-2" {
+		t.Errorf("token = %q, %v", token, err)
+	}
+}
@@ -0,0 +1,367 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// tokenRefreshSkew is how long before its expiry a token is replaced.
+const tokenRefreshSkew = time.Minute
+
+// OAuth2TokenPath is the token endpoint of the Frabit API.
+const OAuth2TokenPath = "/api/v2/auth/token"
+
+// CredentialsProvider supplies the bearer token sent with every request.
+type CredentialsProvider interface {
+	// Token returns the token for the next request.
+	Token(ctx context.Context) (string, error)
+	// Invalidate is called with a token the server rejected with a 401, so
+	// the next call to Token fetches a fresh one.
+	Invalidate(token string)
+}
+
+// WithCredentials authenticates requests with the tokens of p. It replaces
+// a token set with WithToken or by a profile.
+func WithCredentials(p CredentialsProvider) ClientOption {
+	return func(c *Client) error {
+		c.credentials = p
+		c.overrideConfig("token", func(cfg *Config) { cfg.Token, cfg.TokenCommand = "", "" })
+		return nil
+	}
+}
+
+// StaticToken always returns token.
+func StaticToken(token string) CredentialsProvider {
+	return staticToken(token)
+}
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
+func (staticToken) Invalidate(string)                       {}
+
+// EnvCredentials reads the token from the environment variable name on every
+// request.
+func EnvCredentials(name string) CredentialsProvider {
+	return envCredentials(name)
+}
+
+type envCredentials string
+
+func (e envCredentials) Token(context.Context) (string, error) {
+	token := os.Getenv(string(e))
+	if token == "" {
+		return "", fmt.Errorf("frabit: $%s is not set", string(e))
+	}
+	return token, nil
+}
+
+func (envCredentials) Invalidate(string) {}
+
+// FileCredentials reads the token from path, such as a mounted secret, and
+// rereads it whenever the file changes.
+func FileCredentials(path string) CredentialsProvider {
+	return &fileCredentials{path: path}
+}
+
+type fileCredentials struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+func (f *fileCredentials) Token(context.Context) (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("frabit: token file %s is empty", f.path)
+	}
+	f.token, f.modTime, f.size = token, info.ModTime(), info.Size()
+	return token, nil
+}
+
+func (f *fileCredentials) Invalidate(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token == token {
+		f.token = ""
+	}
+}
+
+// cachedToken holds a token fetched by a slower provider until shortly
+// before it expires, or until it is invalidated when it has no expiry.
+type cachedToken struct {
+	fetch func(ctx context.Context) (string, time.Time, error)
+	now   func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (c *cachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && (c.expiry.IsZero() || c.now().Add(tokenRefreshSkew).Before(c.expiry)) {
+		return c.token, nil
+	}
+	token, expiry, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expiry = token, expiry
+	return token, nil
+}
+
+func (c *cachedToken) Invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// ExecCredentials runs an external command for the token. The command prints
+// either the bare token or a JSON object
+//
+//	{"token": "...", "expires_at": "2024-06-01T12:00:00Z"}
+//
+// and is run again once the token expires or is rejected.
+func ExecCredentials(name string, args ...string) CredentialsProvider {
+	return &cachedToken{now: time.Now, fetch: func(ctx context.Context) (string, time.Time, error) {
+		return execToken(ctx, name, args)
+	}}
+}
+
+// shellCredentials is ExecCredentials for a command line run by the shell.
+func shellCredentials(command string) CredentialsProvider {
+	if os.PathSeparator == '\\' {
+		return ExecCredentials("cmd", "/C", command)
+	}
+	return ExecCredentials("sh", "-c", command)
+}
+
+const execTokenTimeout = 30 * time.Second
+
+func execToken(ctx context.Context, name string, args []string) (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, execTokenTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("frabit: token command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) > 0 && out[0] == '{' {
+		var cred struct {
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if err := json.Unmarshal(out, &cred); err != nil {
+			return "", time.Time{}, fmt.Errorf("frabit: malformed token command output: %w", err)
+		}
+		out = []byte(cred.Token)
+		if cred.Token != "" {
+			return cred.Token, cred.ExpiresAt, nil
+		}
+	}
+	if len(out) == 0 {
+		return "", time.Time{}, errors.New("frabit: token command printed no token")
+	}
+	return string(out), time.Time{}, nil
+}
+
+// OAuth2Config describes an OAuth2 client credentials grant.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// HTTPClient makes the token requests; a default client is used when nil.
+	HTTPClient *http.Client
+}
+
+// OAuth2ClientCredentials obtains tokens with the OAuth2 client credentials
+// grant and requests a new one shortly before the current one expires.
+func OAuth2ClientCredentials(cfg OAuth2Config) CredentialsProvider {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = cleanhttp.DefaultClient()
+	}
+	return &cachedToken{now: time.Now, fetch: cfg.fetch}
+}
+
+// OAuth2Error is the error response of a token endpoint.
+type OAuth2Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuth2Error) Error() string {
+	if e.Description != "" {
+		return "oauth2: " + e.Code + ": " + e.Description
+	}
+	return "oauth2: " + e.Code
+}
+
+type oauth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (cfg OAuth2Config) fetch(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	tok, err := postTokenForm(ctx, cfg.HTTPClient, cfg.TokenURL, form, cfg.ClientID, cfg.ClientSecret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var expiry time.Time
+	if tok.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return tok.AccessToken, expiry, nil
+}
+
+// refreshToken renews access tokens with the OAuth2 refresh token grant.
+type refreshToken struct {
+	clientID string
+	// client is read on use, as options after the profile may change it
+	client *Client
+	// save persists the tokens of a successful refresh, if set
+	save func(tok *oauth2Token, expiry time.Time) error
+
+	mu      sync.Mutex
+	refresh string
+}
+
+func (r *refreshToken) fetch(ctx context.Context) (string, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client.BaseURL == nil {
+		return "", time.Time{}, errors.New("frabit: refreshing token: no base URL configured")
+	}
+	tokenURL, err := r.client.BaseURL.Parse(OAuth2TokenPath)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {r.refresh}}
+	tok, err := postTokenForm(ctx, r.client.client, tokenURL.String(), form, r.clientID, "")
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("frabit: refreshing token, log in again: %w", err)
+	}
+	// servers may rotate refresh tokens on every use
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = r.refresh
+	}
+	r.refresh = tok.RefreshToken
+	var expiry time.Time
+	if tok.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	if r.save != nil {
+		// the token is usable even if it could not be saved
+		if err := r.save(tok, expiry); err != nil && r.client.logger != nil {
+			r.client.logger.WarnContext(ctx, "saving refreshed frabit token failed", slog.String("error", err.Error()))
+		}
+	}
+	return tok.AccessToken, expiry, nil
+}
+
+// postTokenForm posts form to an OAuth2 endpoint, authenticating with HTTP
+// basic auth when clientSecret is set and with a client_id field otherwise.
+func postTokenForm(ctx context.Context, client *http.Client, tokenURL string, form url.Values, clientID, clientSecret string) (*oauth2Token, error) {
+	if clientSecret == "" {
+		form.Set("client_id", clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", jsonMediaType)
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		oerr := &OAuth2Error{}
+		if json.NewDecoder(resp.Body).Decode(oerr) == nil && oerr.Code != "" {
+			return nil, oerr
+		}
+		return nil, fmt.Errorf("oauth2: token request failed with status %d", resp.StatusCode)
+	}
+	var tok oauth2Token
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oauth2: malformed token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, errors.New("oauth2: token response has no access_token")
+	}
+	return &tok, nil
+}
+
+// credentialsProvider returns the provider of the client, falling back to
+// the static Token.
+func (c *Client) credentialsProvider() CredentialsProvider {
+	if c.credentials != nil {
+		return c.credentials
+	}
+	if c.Token != "" {
+		return StaticToken(c.Token)
+	}
+	return nil
+}
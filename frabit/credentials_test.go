@@ -0,0 +1,205 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer accepts requests carrying the current token and records the
+// tokens it saw.
+type tokenServer struct {
+	valid atomic.Value
+	seen  []string
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	s.seen = append(s.seen, auth)
+	if auth != "Bearer "+s.valid.Load().(string) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// rotatingToken hands out "old" until it is invalidated.
+type rotatingToken struct {
+	current     string
+	invalidated []string
+}
+
+func (r *rotatingToken) Token(context.Context) (string, error) { return r.current, nil }
+func (r *rotatingToken) Invalidate(token string) {
+	r.invalidated = append(r.invalidated, token)
+	r.current = "new"
+}
+
+func TestCredentialsRetryAfterUnauthorized(t *testing.T) {
+	api := &tokenServer{}
+	api.valid.Store("new")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	creds := &rotatingToken{current: "old"}
+	c, err := NewClient(WithBaseURL(srv.URL), WithCredentials(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func() (map[string]string, error) {
+		req, err := c.newRequest("post", "echo", map[string]string{"hello": "world"})
+		if err != nil {
+			return nil, err
+		}
+		var out map[string]string
+		return out, c.do(context.Background(), req, &out)
+	}
+
+	// the retry carries the fresh token and the original body
+	out, err := call()
+	if err != nil || out["hello"] != "world" {
+		t.Fatalf("retry: %v, %v", out, err)
+	}
+	if len(api.seen) != 2 || api.seen[0] != "Bearer old" || api.seen[1] != "Bearer new" || len(creds.invalidated) != 1 {
+		t.Errorf("tokens seen = %q, invalidated = %q", api.seen, creds.invalidated)
+	}
+
+	// a token that stays invalid is retried once only
+	api.valid.Store("other")
+	api.seen = nil
+	if _, err := call(); !IsErrorCode(err, ErrUnauthorized) || len(api.seen) != 2 {
+		t.Errorf("invalid token: %v after %d attempts", err, len(api.seen))
+	}
+}
+
+func TestFileCredentialsRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	write := func(token string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write("old", now)
+	p := FileCredentials(path)
+	if token, err := p.Token(context.Background()); err != nil || token != "old" {
+		t.Fatalf("token = %q, %v", token, err)
+	}
+	write("new", now.Add(time.Second))
+	if token, err := p.Token(context.Background()); err != nil || token != "new" {
+		t.Fatalf("rotated token = %q, %v", token, err)
+	}
+	write("", now.Add(2*time.Second))
+	if _, err := p.Token(context.Background()); err == nil {
+		t.Error("empty token file accepted")
+	}
+}
+
+func TestStaticAndEnvCredentials(t *testing.T) {
+	api := &tokenServer{}
+	api.valid.Store("s3cret")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	t.Setenv("TEST_FRABIT_TOKEN", "s3cret")
+	for name, opt := range map[string]ClientOption{
+		"WithToken": WithToken("s3cret"),
+		"static":    WithCredentials(StaticToken("s3cret")),
+		"env":       WithCredentials(EnvCredentials("TEST_FRABIT_TOKEN")),
+	} {
+		c, err := NewClient(WithBaseURL(srv.URL), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := c.newRequest("get", "echo", nil)
+		if err := c.do(context.Background(), req, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestExecCredentials(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	p := ExecCredentials("sh", "-c", fmt.Sprintf(`echo '{"token":"from-exec","expires_at":"%s"}'`, expiry))
+	token, err := p.Token(context.Background())
+	if err != nil || token != "from-exec" {
+		t.Fatalf("token = %q, %v", token, err)
+	}
+	if cached := p.(*cachedToken); cached.expiry.IsZero() {
+		t.Error("expiry not parsed")
+	}
+
+	if _, err := ExecCredentials("sh", "-c", "echo denied >&2; exit 3").Token(context.Background()); err == nil {
+		t.Error("failing command accepted")
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var issued atomic.Int32
+	expiresIn := atomic.Int64{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "agent" || secret != "pw" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "backup restore" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(OAuth2Error{Code: "invalid_client"})
+			return
+		}
+		n := issued.Add(1)
+		_ = json.NewEncoder(w).Encode(oauth2Token{AccessToken: fmt.Sprintf("tok-%d", n), TokenType: "Bearer", ExpiresIn: expiresIn.Load()})
+	}))
+	defer srv.Close()
+
+	cfg := OAuth2Config{TokenURL: srv.URL, ClientID: "agent", ClientSecret: "pw", Scopes: []string{"backup", "restore"}}
+	p := OAuth2ClientCredentials(cfg)
+	ctx := context.Background()
+
+	// a token about to expire is refreshed on every use
+	expiresIn.Store(30)
+	first, _ := p.Token(ctx)
+	second, err := p.Token(ctx)
+	if err != nil || first != "tok-1" || second != "tok-2" {
+		t.Fatalf("tokens = %q, %q, %v", first, second, err)
+	}
+
+	expiresIn.Store(3600)
+	p.Invalidate("tok-2")
+	third, _ := p.Token(ctx)
+	fourth, _ := p.Token(ctx)
+	if third != "tok-3" || fourth != "tok-3" {
+		t.Errorf("tokens = %q, %q", third, fourth)
+	}
+
+	cfg.ClientSecret = "wrong"
+	var oerr *OAuth2Error
+	if _, err := OAuth2ClientCredentials(cfg).Token(ctx); !errors.As(err, &oerr) || oerr.Code != "invalid_client" {
+		t.Errorf("bad secret: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import "time"
+
+// AgentInventory describes the host an agent runs on and what the agent is
+// able to do there, so Frabit can schedule work to the right agent.
+type AgentInventory struct {
+	Hostname       string          `json:"hostname"`
+	OS             string          `json:"os"`
+	Kernel         string          `json:"kernel"`
+	Arch           string          `json:"arch"`
+	CPU            CPUInfo         `json:"cpu"`
+	MemoryBytes    uint64          `json:"memory_bytes"`
+	Disks          []DiskInfo      `json:"disks"`
+	Engines        []EngineInfo    `json:"engines"`
+	ListeningPorts []ListeningPort `json:"listening_ports"`
+	AgentVersion   string          `json:"agent_version"`
+	Capabilities   []string        `json:"capabilities"`
+	CollectedAt    time.Time       `json:"collected_at"`
+}
+
+type CPUInfo struct {
+	Model string `json:"model"`
+	Cores int    `json:"cores"`
+}
+
+type DiskInfo struct {
+	Name       string `json:"name"`
+	SizeBytes  uint64 `json:"size_bytes"`
+	Rotational bool   `json:"rotational"`
+}
+
+// EngineInfo is a database engine found installed on the host.
+type EngineInfo struct {
+	Engine  string `json:"engine"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+type ListeningPort struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+}
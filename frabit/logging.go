@@ -0,0 +1,245 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LevelDump is the level of the full request and response dumps, below
+// slog.LevelDebug where the one line summaries are logged.
+const LevelDump = slog.LevelDebug - 4
+
+// maxDumpBody caps the body logged in a dump.
+const maxDumpBody = 64 << 10
+
+const redacted = "REDACTED"
+
+// sensitiveWords mark header, query and JSON field names whose values are
+// never logged.
+var sensitiveWords = []string{"password", "passwd", "secret", "token", "api_key", "apikey", "api-key", "private_key", "credential", "authorization", "cookie"}
+
+// WithLogger logs a summary of every request at debug level, failures at
+// warn level, and dumps of the requests and responses at LevelDump.
+// Credentials, passwords and the fields given to WithRedactedFields are
+// redacted.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithRedactedFields adds JSON field names, matched case insensitively,
+// whose values the logger replaces with REDACTED.
+func WithRedactedFields(fields ...string) ClientOption {
+	return func(c *Client) error {
+		c.redactedFields = append(c.redactedFields, fields...)
+		return nil
+	}
+}
+
+// LogRequests is the logging of WithLogger as a middleware, for placing it
+// among other middlewares.
+func LogRequests(logger *slog.Logger) Middleware {
+	return newRequestLogger(logger, nil).middleware
+}
+
+type requestLogger struct {
+	logger *slog.Logger
+	fields map[string]bool
+}
+
+func newRequestLogger(logger *slog.Logger, fields []string) *requestLogger {
+	l := &requestLogger{logger: logger, fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		l.fields[strings.ToLower(f)] = true
+	}
+	return l
+}
+
+func (l *requestLogger) middleware(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		ctx := req.Context()
+		dump := l.logger.Enabled(ctx, LevelDump)
+		if dump {
+			l.logger.LogAttrs(ctx, LevelDump, "frabit api request",
+				slog.String("method", req.Method),
+				slog.String("url", l.redactURL(req.URL)),
+				l.headers(req.Header),
+				slog.String("body", l.requestBody(req)),
+			)
+		}
+
+		start := time.Now()
+		resp, err := next(req)
+		attrs := []slog.Attr{
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if call, ok := CallFromContext(ctx); ok {
+			attrs = append(attrs, slog.String("call", call.String()))
+		}
+		if id := req.Header.Get(RequestIDHeader); id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+		level := slog.LevelDebug
+		if err != nil {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.String("error", err.Error()))
+		} else {
+			if resp.StatusCode >= 500 {
+				level = slog.LevelWarn
+			}
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		}
+		l.logger.LogAttrs(ctx, level, "frabit api request finished", attrs...)
+
+		if dump && err == nil {
+			l.logger.LogAttrs(ctx, LevelDump, "frabit api response",
+				slog.Int("status", resp.StatusCode),
+				l.headers(resp.Header),
+				slog.String("body", l.responseBody(resp)),
+			)
+		}
+		return resp, err
+	}
+}
+
+func (l *requestLogger) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	if l.fields[name] {
+		return true
+	}
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *requestLogger) headers(h http.Header) slog.Attr {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(h.Values(name), ", ")
+		if l.sensitive(name) {
+			value = redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group("headers", attrs...)
+}
+
+func (l *requestLogger) redactURL(u *url.URL) string {
+	u = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if l.sensitive(name) {
+				query[name] = []string{redacted}
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// requestBody reads a copy of the request body, leaving the request intact.
+func (l *requestLogger) requestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	if req.GetBody == nil {
+		return "<body not replayable>"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxDumpBody+1))
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return l.redactBody(data)
+}
+
+// responseBody reads the response body and puts it back for the caller.
+func (l *requestLogger) responseBody(resp *http.Response) string {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return l.redactBody(data)
+}
+
+// redactBody returns a JSON body with the sensitive fields replaced. Other
+// bodies might hold anything, so only their size is logged.
+func (l *requestLogger) redactBody(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if len(data) > maxDumpBody {
+		return fmt.Sprintf("<more than %d bytes>", maxDumpBody)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(data))
+	}
+	out, err := json.Marshal(l.redactValue(v))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(data))
+	}
+	return string(out)
+}
+
+func (l *requestLogger) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if l.sensitive(k) {
+				v[k] = redacted
+			} else {
+				v[k] = l.redactValue(field)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = l.redactValue(v[i])
+		}
+	}
+	return v
+}
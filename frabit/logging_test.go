@@ -0,0 +1,80 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerRedacts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc123")
+		_, _ = w.Write([]byte(`{"workspace":"demo","name":"alice","owner":"ops","credentials":{"join_token":"jt-777"},"pin":"4242"}`))
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: LevelDump}))
+	client, err := NewClient(WithBaseURL(srv.URL), WithToken("tok-s3cret"), WithLogger(logger), WithRedactedFields("PIN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := client.User.CreateUser(context.Background(), CreateUserRequest{Login: "alice", Password: "hunter2"})
+	if err != nil || user.Name != "alice" {
+		t.Fatalf("CreateUser = %+v, %v", user, err)
+	}
+
+	out := logs.String()
+	for _, secret := range []string{"hunter2", "tok-s3cret", "abc123", "jt-777", "4242"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%q logged:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{`\"login\":\"alice\"`, `\"password\":\"REDACTED\"`, "headers.Authorization=REDACTED", "call=User.CreateUser", "status=200"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%s missing from:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "\n"); n != 3 {
+		t.Errorf("%d lines logged, want request, summary and response", n)
+	}
+
+	// at debug level only the summary is logged
+	logs.Reset()
+	client, _ = NewClient(WithBaseURL(srv.URL), WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	if _, err := client.User.GetUser(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out := logs.String(); strings.Count(out, "\n") != 1 || !strings.Contains(out, "frabit api request finished") {
+		t.Errorf("debug logs:\n%s", out)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	l := newRequestLogger(slog.Default(), nil)
+	if got := l.redactBody([]byte("password=hunter2")); got != "<16 bytes>" {
+		t.Errorf("non-JSON body = %q", got)
+	}
+	if got := l.redactBody([]byte(`[{"client_secret":"x","size":12345678901234567890}]`)); got != `[{"client_secret":"REDACTED","size":12345678901234567890}]` {
+		t.Errorf("JSON body = %q", got)
+	}
+}
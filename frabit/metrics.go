@@ -0,0 +1,64 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// MetricSample is a single observation of a database or host metric.
+type MetricSample struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     float64           `json:"value"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+type MetricBatch struct {
+	AgentID string         `json:"agent_id"`
+	Samples []MetricSample `json:"samples"`
+}
+
+// PushMetrics sends a batch of samples. The body is gzip compressed since
+// status counters compress very well and agents often sit behind slow links.
+func (s *agentService) PushMetrics(ctx context.Context, batch MetricBatch) error {
+	request, err := s.Client.newRequest("post", fmt.Sprintf("/api/v2/agents/%s/metrics", batch.AgentID), nil)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	if err := json.NewEncoder(zw).Encode(batch); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	body := buf.Bytes()
+	request.Body = io.NopCloser(bytes.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	request.ContentLength = int64(len(body))
+	request.Header.Set("Content-Encoding", "gzip")
+
+	return s.do(ctx, request, nil)
+}
@@ -0,0 +1,168 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// RoundTripFunc sends one HTTP request to the Frabit API.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of every request the client makes. The
+// request carries the credentials, and its context the Call being made.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// WithMiddleware adds middlewares around the requests of the client. The
+// first middleware given is the outermost one.
+func WithMiddleware(middlewares ...Middleware) ClientOption {
+	return func(c *Client) error {
+		c.middlewares = append(c.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// CallFunc makes one SDK call, which may take more than one request.
+type CallFunc func(ctx context.Context) error
+
+// CallMiddleware wraps every SDK call once, around all the requests it
+// takes. The context carries the Call being made and the returned error is
+// the outcome of the call.
+type CallMiddleware func(next CallFunc) CallFunc
+
+// WithCallMiddleware adds middlewares around the calls of the client. The
+// first middleware given is the outermost one.
+func WithCallMiddleware(middlewares ...CallMiddleware) ClientOption {
+	return func(c *Client) error {
+		c.callMiddlewares = append(c.callMiddlewares, middlewares...)
+		return nil
+	}
+}
+
+// Call names the SDK method a request is made for, such as Backup and
+// Verify for client.Backup.Verify.
+type Call struct {
+	Service   string
+	Operation string
+	// Attempt is 1, or 2 when the request is resent after a 401.
+	Attempt int
+}
+
+func (c Call) String() string {
+	if c.Service == "" {
+		return c.Operation
+	}
+	return c.Service + "." + c.Operation
+}
+
+type callKey struct{}
+
+// CallFromContext returns the call a request is made for.
+func CallFromContext(ctx context.Context) (Call, bool) {
+	call, ok := ctx.Value(callKey{}).(Call)
+	return call, ok
+}
+
+// roundTrip is the client's HTTP client wrapped in its middlewares.
+func (c *Client) roundTrip() RoundTripFunc {
+	rt := RoundTripFunc(c.client.Do)
+	if c.logger != nil {
+		rt = newRequestLogger(c.logger, c.redactedFields).middleware(rt)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		rt = c.middlewares[i](rt)
+	}
+	return rt
+}
+
+// callerCall finds the service method on the stack above do. Services are
+// the *fooService types, so callers need not name themselves.
+func callerCall() Call {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if call, ok := parseCall(frame.Function); ok {
+			return call
+		}
+		if !more {
+			return Call{}
+		}
+	}
+}
+
+// parseCall turns "github.com/.../frabit.(*backupService).Copy.func1" into
+// Call{"Backup", "Copy"}.
+func parseCall(function string) (Call, bool) {
+	name := function[strings.LastIndex(function, "/")+1:]
+	recv, method, ok := strings.Cut(name, ".(*")
+	if !ok || recv != "frabit" {
+		return Call{}, false
+	}
+	recv, method, ok = strings.Cut(method, ").")
+	if !ok || !strings.HasSuffix(recv, "Service") || recv == "Service" {
+		return Call{}, false
+	}
+	method, _, _ = strings.Cut(method, ".")
+	service := []rune(strings.TrimSuffix(recv, "Service"))
+	service[0] = unicode.ToUpper(service[0])
+	return Call{Service: string(service), Operation: method}, true
+}
+
+// RequestIDHeader carries the id that ties a request to the server's logs.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID makes the RequestID middleware send id, to propagate
+// the id of an incoming request to the Frabit API.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID sets the X-Request-Id header to the id in the request context,
+// or to a random one. Error responses record it in Error.Meta["request_id"].
+func RequestID() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(RequestIDHeader) == "" {
+				id := RequestIDFromContext(req.Context())
+				if id == "" {
+					id = newRequestID()
+				}
+				req.Header.Set(RequestIDHeader, id)
+			}
+			return next(req)
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,146 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var gotID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get(RequestIDHeader)
+		switch r.URL.Path {
+		case "/api/v2/user/me":
+			_, _ = w.Write([]byte(`{"id":"u1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var calls []string
+	record := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			call, _ := CallFromContext(req.Context())
+			calls = append(calls, call.String())
+			req.Header.Set("X-Audit", "on")
+			return next(req)
+		}
+	}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, err := NewClient(WithBaseURL(srv.URL), WithToken("s3cret"), WithMiddleware(record, RequestID(), LogRequests(logger)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := ContextWithRequestID(context.Background(), "req-42")
+	if _, err := client.User.GetCurrentUser(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if gotID != "req-42" {
+		t.Errorf("request id = %q", gotID)
+	}
+	_, err = client.Backup.ListCopies(context.Background(), "b1")
+	if !IsErrorCode(err, ErrNotFound) || len(gotID) != 32 || err.(*Error).Meta["request_id"] != gotID {
+		t.Errorf("generated request id %q, err = %v", gotID, err)
+	}
+
+	if len(calls) != 2 || calls[0] != "User.GetCurrentUser" || calls[1] != "Backup.ListCopies" {
+		t.Errorf("calls = %q", calls)
+	}
+	if out := logs.String(); !strings.Contains(out, "call=User.GetCurrentUser") || !strings.Contains(out, "status=404") || strings.Contains(out, "s3cret") {
+		t.Errorf("logs:\n%s", out)
+	}
+}
+
+func TestMiddlewareFaultInjection(t *testing.T) {
+	unavailable := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("down"))}, nil
+		}
+	}
+	client, err := NewClient(WithBaseURL("http://frabit.invalid"), WithMiddleware(unavailable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Archive.ListArchiveStreams(context.Background(), "c1"); !IsErrorCode(err, ErrInternal) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCallMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"u1"}`))
+	}))
+	defer srv.Close()
+
+	var calls, requests []string
+	var results []error
+	outcome := func(next CallFunc) CallFunc {
+		return func(ctx context.Context) error {
+			call, _ := CallFromContext(ctx)
+			calls = append(calls, call.String())
+			err := next(ctx)
+			results = append(results, err)
+			return err
+		}
+	}
+	attempts := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			call, _ := CallFromContext(req.Context())
+			requests = append(requests, fmt.Sprint(call.Attempt))
+			return next(req)
+		}
+	}
+	client, err := NewClient(WithBaseURL(srv.URL), WithCredentials(&rotatingToken{current: "stale"}),
+		WithCallMiddleware(outcome), WithMiddleware(attempts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.User.GetCurrentUser(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != "User.GetCurrentUser" || results[0] != nil || strings.Join(requests, ",") != "1,2" {
+		t.Errorf("calls = %q with results %v, attempts %q", calls, results, requests)
+	}
+}
+
+func TestParseCall(t *testing.T) {
+	for fn, want := range map[string]Call{
+		"github.com/frabits/frabit-go-sdk/frabit.(*backupService).Copy.func1": {Service: "Backup", Operation: "Copy"},
+		"github.com/frabits/frabit-go-sdk/frabit.(*userService).GetUser":      {Service: "User", Operation: "GetUser"},
+		"github.com/frabits/frabit-go-sdk/frabit.(*Client).do":                {},
+		"github.com/frabits/frabit-go-sdk/agent.(*fooService).Run":            {},
+	} {
+		if got, _ := parseCall(fn); got != want {
+			t.Errorf("parseCall(%s) = %+v", fn, got)
+		}
+	}
+}
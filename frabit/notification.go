@@ -0,0 +1,263 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type NotificationService interface {
+	ListChannels(ctx context.Context) ([]NotificationChannel, error)
+	GetChannel(ctx context.Context, channelID string) (*NotificationChannel, error)
+	CreateChannel(ctx context.Context, req CreateChannelRequest) (*NotificationChannel, error)
+	UpdateChannel(ctx context.Context, channelID string, req CreateChannelRequest) (*NotificationChannel, error)
+	DeleteChannel(ctx context.Context, channelID string) error
+	// TestChannel asks the server to send a test notification through the channel.
+	TestChannel(ctx context.Context, channelID string) error
+
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	Subscribe(ctx context.Context, req SubscribeRequest) (*Subscription, error)
+	Unsubscribe(ctx context.Context, subscriptionID string) error
+}
+
+type notificationService struct {
+	*Client
+}
+
+type ChannelType string
+
+const (
+	ChannelEmail     ChannelType = "email"
+	ChannelWebhook   ChannelType = "webhook"
+	ChannelSlack     ChannelType = "slack"
+	ChannelDingTalk  ChannelType = "dingtalk"
+	ChannelFeishu    ChannelType = "feishu"
+	ChannelPagerDuty ChannelType = "pagerduty"
+)
+
+type EventType string
+
+const (
+	EventBackupSucceeded  EventType = "backup.succeeded"
+	EventBackupFailed     EventType = "backup.failed"
+	EventRestoreSucceeded EventType = "restore.succeeded"
+	EventRestoreFailed    EventType = "restore.failed"
+	EventAgentUnreachable EventType = "agent.unreachable"
+	EventClusterFailover  EventType = "cluster.failover"
+	EventTest             EventType = "notification.test"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// ChannelConfig holds the settings of every channel type; only the fields of
+// the channel's own type are used.
+type ChannelConfig struct {
+	// URL is the webhook, Slack, DingTalk or Feishu endpoint; for PagerDuty it
+	// overrides the default Events API v2 endpoint.
+	URL string `json:"url,omitempty"`
+	// Secret signs webhook bodies and DingTalk/Feishu requests.
+	Secret  string            `json:"secret,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// RoutingKey is the PagerDuty integration key.
+	RoutingKey string `json:"routing_key,omitempty"`
+
+	SMTPHost     string   `json:"smtp_host,omitempty"`
+	SMTPPort     int      `json:"smtp_port,omitempty"`
+	SMTPUsername string   `json:"smtp_username,omitempty"`
+	SMTPPassword string   `json:"smtp_password,omitempty"`
+	From         string   `json:"from,omitempty"`
+	To           []string `json:"to,omitempty"`
+}
+
+type NotificationChannel struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Type      ChannelType   `json:"type"`
+	Config    ChannelConfig `json:"config"`
+	Enabled   bool          `json:"enabled"`
+	CreatedAt string        `json:"created_at"`
+	UpdatedAt string        `json:"updated_at"`
+}
+
+type CreateChannelRequest struct {
+	Name    string        `json:"name"`
+	Type    ChannelType   `json:"type"`
+	Config  ChannelConfig `json:"config"`
+	Enabled bool          `json:"enabled"`
+}
+
+// Subscription routes events to a channel. An empty Events list matches every
+// event; MinSeverity drops anything less severe.
+type Subscription struct {
+	ID          string      `json:"id"`
+	ChannelID   string      `json:"channel_id"`
+	Events      []EventType `json:"events"`
+	MinSeverity Severity    `json:"min_severity,omitempty"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+type SubscribeRequest struct {
+	ChannelID   string      `json:"channel_id"`
+	Events      []EventType `json:"events"`
+	MinSeverity Severity    `json:"min_severity,omitempty"`
+}
+
+// Notification is a single message delivered to the subscribed channels.
+type Notification struct {
+	Event    EventType         `json:"event"`
+	Severity Severity          `json:"severity"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Time     time.Time         `json:"time"`
+}
+
+// Matches reports whether n is routed to the subscription's channel.
+func (s Subscription) Matches(n Notification) bool {
+	if severityRank(n.Severity) < severityRank(s.MinSeverity) {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, event := range s.Events {
+		if event == n.Event {
+			return true
+		}
+	}
+	return false
+}
+
+func severityRank(s Severity) int {
+	switch s {
+	case SeverityWarning:
+		return 1
+	case SeverityCritical:
+		return 2
+	}
+	return 0
+}
+
+func (s *notificationService) ListChannels(ctx context.Context) ([]NotificationChannel, error) {
+	request, err := s.Client.newRequest("get", "/api/v2/notifications/channels", nil)
+	if err != nil {
+		return nil, err
+	}
+	var channels []NotificationChannel
+	err = s.do(ctx, request, &channels)
+	if err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+func (s *notificationService) GetChannel(ctx context.Context, channelID string) (*NotificationChannel, error) {
+	request, err := s.Client.newRequest("get", fmt.Sprintf("/api/v2/notifications/channels/%s", channelID), nil)
+	if err != nil {
+		return nil, err
+	}
+	channel := &NotificationChannel{}
+	err = s.do(ctx, request, channel)
+	if err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (s *notificationService) CreateChannel(ctx context.Context, req CreateChannelRequest) (*NotificationChannel, error) {
+	request, err := s.Client.newRequest("post", "/api/v2/notifications/channels", req)
+	if err != nil {
+		return nil, err
+	}
+	channel := &NotificationChannel{}
+	err = s.do(ctx, request, channel)
+	if err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (s *notificationService) UpdateChannel(ctx context.Context, channelID string, req CreateChannelRequest) (*NotificationChannel, error) {
+	request, err := s.Client.newRequest("put", fmt.Sprintf("/api/v2/notifications/channels/%s", channelID), req)
+	if err != nil {
+		return nil, err
+	}
+	channel := &NotificationChannel{}
+	err = s.do(ctx, request, channel)
+	if err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (s *notificationService) DeleteChannel(ctx context.Context, channelID string) error {
+	request, err := s.Client.newRequest("delete", fmt.Sprintf("/api/v2/notifications/channels/%s", channelID), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
+
+func (s *notificationService) TestChannel(ctx context.Context, channelID string) error {
+	request, err := s.Client.newRequest("post", fmt.Sprintf("/api/v2/notifications/channels/%s/test", channelID), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
+
+func (s *notificationService) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	request, err := s.Client.newRequest("get", "/api/v2/notifications/subscriptions", nil)
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []Subscription
+	err = s.do(ctx, request, &subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (s *notificationService) Subscribe(ctx context.Context, req SubscribeRequest) (*Subscription, error) {
+	request, err := s.Client.newRequest("post", "/api/v2/notifications/subscriptions", req)
+	if err != nil {
+		return nil, err
+	}
+	subscription := &Subscription{}
+	err = s.do(ctx, request, subscription)
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *notificationService) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	request, err := s.Client.newRequest("delete", fmt.Sprintf("/api/v2/notifications/subscriptions/%s", subscriptionID), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
@@ -0,0 +1,97 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type OperationStatus string
+
+const (
+	OperationPending   OperationStatus = "pending"
+	OperationRunning   OperationStatus = "running"
+	OperationSucceeded OperationStatus = "succeeded"
+	OperationFailed    OperationStatus = "failed"
+)
+
+// poll interval bounds of OperationHandle.Wait, variables for tests
+var (
+	operationPollMin = time.Second
+	operationPollMax = 30 * time.Second
+)
+
+// Operation is a long running job on the Frabit server.
+type Operation struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Status   OperationStatus `json:"status"`
+	Progress float64         `json:"progress"`
+	// ResourceID is the object the operation creates or acts on, e.g. the id
+	// of a backup copy.
+	ResourceID string    `json:"resource_id"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (o *Operation) Done() bool {
+	return o.Status == OperationSucceeded || o.Status == OperationFailed
+}
+
+// OperationHandle tracks an Operation until it is done.
+type OperationHandle struct {
+	Operation
+	get func(ctx context.Context, id string) (*Operation, error)
+}
+
+// Refresh reloads the operation from the server.
+func (h *OperationHandle) Refresh(ctx context.Context) error {
+	op, err := h.get(ctx, h.ID)
+	if err != nil {
+		return err
+	}
+	h.Operation = *op
+	return nil
+}
+
+// Wait polls until the operation is done, backing off from one second to
+// thirty. It returns an error if the operation failed.
+func (h *OperationHandle) Wait(ctx context.Context) error {
+	delay := operationPollMin
+	for !h.Done() {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if err := h.Refresh(ctx); err != nil {
+			return err
+		}
+		delay = min(delay*2, operationPollMax)
+	}
+	if h.Status == OperationFailed {
+		if h.Error == "" {
+			return errors.New("frabit: operation " + h.ID + " failed")
+		}
+		return errors.New("frabit: operation " + h.ID + " failed: " + h.Error)
+	}
+	return nil
+}
@@ -39,9 +39,17 @@ type OrgUpdateRequest struct {
 }
 
 func (s *orgService) UpdateOrg(ctx context.Context, req OrgUpdateRequest) error {
-	return nil
+	request, err := s.Client.newRequest("put", "org", req)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
 }
 
 func (s *orgService) CreateOrg(ctx context.Context, req OrgCreateRequest) error {
-	return nil
+	request, err := s.Client.newRequest("post", "org", req)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
 }
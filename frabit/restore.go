@@ -0,0 +1,96 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+type RestoreService interface {
+	// CreateRestore restores a backup, optionally rolled forward to
+	// PointInTime using the archived logs.
+	CreateRestore(ctx context.Context, req CreateRestoreRequest) (*Restore, error)
+	GetRestore(ctx context.Context, restoreID string) (*Restore, error)
+	ListRestores(ctx context.Context, clusterID string) ([]Restore, error)
+}
+
+type restoreService struct {
+	*Client
+}
+
+type Restore struct {
+	ID          string          `json:"id"`
+	BackupID    string          `json:"backup_id"`
+	ClusterID   string          `json:"cluster_id"`
+	PointInTime *time.Time      `json:"point_in_time,omitempty"`
+	Status      OperationStatus `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+type CreateRestoreRequest struct {
+	BackupID string `json:"backup_id"`
+	// ClusterID is the cluster restored into.
+	ClusterID   string     `json:"cluster_id"`
+	PointInTime *time.Time `json:"point_in_time,omitempty"`
+}
+
+func (s *restoreService) CreateRestore(ctx context.Context, req CreateRestoreRequest) (*Restore, error) {
+	request, err := s.Client.newRequest("post", "/api/v2/restores", req)
+	if err != nil {
+		return nil, err
+	}
+	restore := &Restore{}
+	err = s.do(ctx, request, restore)
+	if err != nil {
+		return nil, err
+	}
+	return restore, nil
+}
+
+func (s *restoreService) GetRestore(ctx context.Context, restoreID string) (*Restore, error) {
+	request, err := s.Client.newRequest("get", fmt.Sprintf("/api/v2/restores/%s", restoreID), nil)
+	if err != nil {
+		return nil, err
+	}
+	restore := &Restore{}
+	err = s.do(ctx, request, restore)
+	if err != nil {
+		return nil, err
+	}
+	return restore, nil
+}
+
+func (s *restoreService) ListRestores(ctx context.Context, clusterID string) ([]Restore, error) {
+	path := "/api/v2/restores"
+	if clusterID != "" {
+		path += "?" + url.Values{"cluster_id": {clusterID}}.Encode()
+	}
+	request, err := s.Client.newRequest("get", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var restores []Restore
+	err = s.do(ctx, request, &restores)
+	if err != nil {
+		return nil, err
+	}
+	return restores, nil
+}
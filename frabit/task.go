@@ -0,0 +1,188 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type TaskType string
+
+const (
+	TaskBackup      TaskType = "backup"
+	TaskRestore     TaskType = "restore"
+	TaskVerify      TaskType = "backup_verify"
+	TaskCopy        TaskType = "backup_copy"
+	TaskHealthProbe TaskType = "health_probe"
+	TaskSQLExecute  TaskType = "sql_execute"
+)
+
+type TaskState string
+
+const (
+	TaskPending   TaskState = "pending"
+	TaskClaimed   TaskState = "claimed"
+	TaskRunning   TaskState = "running"
+	TaskSucceeded TaskState = "succeeded"
+	TaskFailed    TaskState = "failed"
+)
+
+// Task is a unit of work the Frabit server assigned to an agent.
+type Task struct {
+	TaskID    string          `json:"task_id"`
+	Type      TaskType        `json:"type"`
+	State     TaskState       `json:"state"`
+	Params    json.RawMessage `json:"params"`
+	CreatedAt string          `json:"created_at"`
+}
+
+type TaskLease struct {
+	TaskID    string    `json:"task_id"`
+	LeaseID   string    `json:"lease_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type PollTasksRequest struct {
+	AgentID string
+	Types   []TaskType
+	// Wait is how long the server may hold the request open when no task is pending.
+	Wait time.Duration
+}
+
+type ClaimTaskRequest struct {
+	AgentID      string `json:"-"`
+	TaskID       string `json:"-"`
+	LeaseSeconds int    `json:"lease_seconds"`
+}
+
+type ExtendLeaseRequest struct {
+	AgentID      string `json:"-"`
+	TaskID       string `json:"-"`
+	LeaseID      string `json:"lease_id"`
+	LeaseSeconds int    `json:"lease_seconds"`
+}
+
+type TaskProgress struct {
+	AgentID string  `json:"-"`
+	TaskID  string  `json:"-"`
+	LeaseID string  `json:"lease_id"`
+	Percent float64 `json:"percent"`
+	Message string  `json:"message"`
+}
+
+type TaskLogs struct {
+	AgentID string   `json:"-"`
+	TaskID  string   `json:"-"`
+	LeaseID string   `json:"lease_id"`
+	Lines   []string `json:"lines"`
+}
+
+type TaskResult struct {
+	AgentID string          `json:"-"`
+	TaskID  string          `json:"-"`
+	LeaseID string          `json:"lease_id"`
+	State   TaskState       `json:"state"`
+	Output  json.RawMessage `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+func taskPath(agentID, taskID, action string) string {
+	return fmt.Sprintf("/api/v2/agents/%s/tasks/%s/%s", agentID, taskID, action)
+}
+
+func (s *agentService) PollTasks(ctx context.Context, req PollTasksRequest) ([]Task, error) {
+	query := url.Values{}
+	if len(req.Types) > 0 {
+		types := make([]string, 0, len(req.Types))
+		for _, t := range req.Types {
+			types = append(types, string(t))
+		}
+		query.Set("types", strings.Join(types, ","))
+	}
+	if req.Wait > 0 {
+		query.Set("wait", req.Wait.String())
+	}
+	path := fmt.Sprintf("/api/v2/agents/%s/tasks", req.AgentID)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	request, err := s.Client.newRequest("get", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	err = s.do(ctx, request, &tasks)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *agentService) ClaimTask(ctx context.Context, req ClaimTaskRequest) (*TaskLease, error) {
+	request, err := s.Client.newRequest("post", taskPath(req.AgentID, req.TaskID, "claim"), req)
+	if err != nil {
+		return nil, err
+	}
+	lease := &TaskLease{}
+	err = s.do(ctx, request, lease)
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (s *agentService) ExtendLease(ctx context.Context, req ExtendLeaseRequest) (*TaskLease, error) {
+	request, err := s.Client.newRequest("post", taskPath(req.AgentID, req.TaskID, "lease"), req)
+	if err != nil {
+		return nil, err
+	}
+	lease := &TaskLease{}
+	err = s.do(ctx, request, lease)
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (s *agentService) ReportProgress(ctx context.Context, req TaskProgress) error {
+	request, err := s.Client.newRequest("post", taskPath(req.AgentID, req.TaskID, "progress"), req)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
+
+func (s *agentService) AppendLogs(ctx context.Context, req TaskLogs) error {
+	request, err := s.Client.newRequest("post", taskPath(req.AgentID, req.TaskID, "logs"), req)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
+
+func (s *agentService) SubmitResult(ctx context.Context, req TaskResult) error {
+	request, err := s.Client.newRequest("post", taskPath(req.AgentID, req.TaskID, "result"), req)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, request, nil)
+}
@@ -0,0 +1,155 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certReloadInterval is how often the certificate files are checked for rotation.
+const certReloadInterval = 30 * time.Second
+
+// WithClientCertificate presents the certificate in certFile to the server.
+// Both files are watched, so a rotated certificate is picked up by the next
+// connection without restarting the process.
+func WithClientCertificate(certFile, keyFile string) ClientOption {
+	return func(c *Client) error {
+		r := &certReloader{certFile: certFile, keyFile: keyFile}
+		if err := r.reload(); err != nil {
+			return err
+		}
+		c.tlsConfig().GetClientCertificate = r.getClientCertificate
+		return nil
+	}
+}
+
+// WithRootCAs trusts the PEM encoded CA certificates in caFile instead of the
+// system roots.
+func WithRootCAs(caFile string) ClientOption {
+	return func(c *Client) error {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		c.overrideConfig("ca_file", func(cfg *Config) { cfg.CAFile = caFile })
+		return WithRootCAsPEM(pem)(c)
+	}
+}
+
+// WithRootCAsPEM is WithRootCAs for a bundle already in memory.
+func WithRootCAsPEM(pem []byte) ClientOption {
+	return func(c *Client) error {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in CA bundle")
+		}
+		c.tlsConfig().RootCAs = pool
+		return nil
+	}
+}
+
+// WithCertificatePins only accepts server chains containing a certificate
+// whose public key matches one of the pins. A pin is the base64 encoded
+// SHA-256 of the DER encoded SubjectPublicKeyInfo, optionally prefixed with
+// "sha256/". Regular chain verification still applies.
+func WithCertificatePins(pins ...string) ClientOption {
+	return func(c *Client) error {
+		allowed := make(map[string]bool, len(pins))
+		for _, pin := range pins {
+			pin = strings.TrimPrefix(pin, "sha256/")
+			raw, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(raw) != sha256.Size {
+				return fmt.Errorf("invalid certificate pin %q", pin)
+			}
+			allowed[pin] = true
+		}
+		c.tlsConfig().VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, cert := range cs.PeerCertificates {
+				if allowed[PublicKeyPin(cert)] {
+					return nil
+				}
+			}
+			return errors.New("server certificate does not match any pinned key")
+		}
+		return nil
+	}
+}
+
+// PublicKeyPin returns the pin of cert as accepted by WithCertificatePins.
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// tlsConfig returns the TLS configuration of the client transport, creating it on first use.
+func (c *Client) tlsConfig() *tls.Config {
+	if c.tls == nil {
+		c.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return c.tls
+}
+
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func (r *certReloader) reload() error {
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return nil
+}
+
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) < certReloadInterval {
+		return r.cert, nil
+	}
+	r.checkedAt = time.Now()
+	info, err := os.Stat(r.certFile)
+	if err != nil || !info.ModTime().After(r.modTime) {
+		return r.cert, nil
+	}
+	// a half written rotation fails to parse; keep the old pair and retry later
+	if err := r.reload(); err != nil {
+		r.checkedAt = time.Time{}
+	}
+	return r.cert, nil
+}
@@ -0,0 +1,158 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func issueCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func newMTLSServer(t *testing.T) (*httptest.Server, *testCert, *testCert) {
+	t.Helper()
+	ca := issueCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "frabit test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server := issueCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "frabit"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := issueCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "agent-1"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	pair, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{pair}, ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, ca, client
+}
+
+func TestMutualTLS(t *testing.T) {
+	srv, ca, client := newMTLSServer(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "agent.crt"), filepath.Join(dir, "agent.key")
+	_ = os.WriteFile(certFile, client.certPEM, 0o600)
+	_ = os.WriteFile(keyFile, client.keyPEM, 0o600)
+
+	heartbeat := func(opts ...ClientOption) error {
+		c, err := NewClient(append([]ClientOption{WithBaseURL(srv.URL), WithRootCAsPEM(ca.certPEM)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Agent.Heartbeat(context.Background(), CreateHeartbeat{AgentID: "agent-1", Status: Active})
+	}
+
+	if err := heartbeat(WithClientCertificate(certFile, keyFile)); err != nil {
+		t.Errorf("with client certificate: %v", err)
+	}
+	if err := heartbeat(); err == nil {
+		t.Error("expected handshake to fail without client certificate")
+	}
+
+	serverPin := PublicKeyPin(srv.Certificate())
+	if err := heartbeat(WithClientCertificate(certFile, keyFile), WithCertificatePins("sha256/"+serverPin)); err != nil {
+		t.Errorf("with matching pin: %v", err)
+	}
+	if err := heartbeat(WithClientCertificate(certFile, keyFile), WithCertificatePins(PublicKeyPin(client.cert))); err == nil {
+		t.Error("expected pin mismatch to fail")
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	_, ca, first := newMTLSServer(t)
+	second := issueCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "agent-1"}}, ca)
+
+	dir := t.TempDir()
+	r := &certReloader{certFile: filepath.Join(dir, "agent.crt"), keyFile: filepath.Join(dir, "agent.key")}
+	_ = os.WriteFile(r.certFile, first.certPEM, 0o600)
+	_ = os.WriteFile(r.keyFile, first.keyPEM, 0o600)
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = os.WriteFile(r.keyFile, second.keyPEM, 0o600)
+	_ = os.WriteFile(r.certFile, second.certPEM, 0o600)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(r.certFile, future, future)
+
+	got, err := r.getClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(got.Certificate[0])
+	if leaf.SerialNumber.Cmp(second.cert.SerialNumber) != 0 {
+		t.Error("rotated certificate was not picked up")
+	}
+}
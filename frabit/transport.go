@@ -0,0 +1,232 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultDialTimeout and defaultKeepAlive match the go-cleanhttp transport.
+const (
+	defaultDialTimeout = 30 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+)
+
+// unixSocketHost is the host of the base URL of a client talking over a
+// unix socket; requests carry it in their Host header.
+const unixSocketHost = "localhost"
+
+// transportSettings collects the transport options, which are applied to a
+// copy of the client's transport once all options have run.
+type transportSettings struct {
+	dialTimeout           time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+
+	proxy    *url.URL
+	proxySet bool
+
+	socket string
+	pool   *ConnectionPool
+}
+
+// ConnectionPool tunes the reuse of connections. The default client opens a
+// connection per request; setting a pool turns keep-alives on.
+type ConnectionPool struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits all connections to a host, 0 means no limit.
+	MaxConnsPerHost int
+	IdleConnTimeout time.Duration
+}
+
+// WithHTTPClient sends the requests with hc. Its transport is copied, never
+// changed, by the transport and TLS options.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) error {
+		if hc == nil {
+			return errors.New("frabit: nil HTTP client")
+		}
+		client := *hc
+		c.client = &client
+		return nil
+	}
+}
+
+// WithTransport sends the requests with rt, such as a service mesh or test
+// transport. The transport and TLS options only work with an *http.Transport.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) error {
+		client := *c.client
+		client.Transport = rt
+		c.client = &client
+		return nil
+	}
+}
+
+// WithTimeout limits the whole of each request, including reading the
+// response body and the time the server holds a long poll such as
+// PollTasks open: the agent's dispatcher asks for a wait of at most half of
+// it.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		client := *c.client
+		client.Timeout = d
+		c.client = &client
+		return nil
+	}
+}
+
+// WithDialTimeout limits establishing the connection.
+func WithDialTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.transportSettings().dialTimeout = d
+		return nil
+	}
+}
+
+func WithTLSHandshakeTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.transportSettings().tlsHandshakeTimeout = d
+		return nil
+	}
+}
+
+// WithResponseHeaderTimeout limits the wait for the response headers once
+// the request has been written.
+func WithResponseHeaderTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.transportSettings().responseHeaderTimeout = d
+		return nil
+	}
+}
+
+// WithProxy sends the requests through an http, https or socks5 proxy, in
+// place of the one configured by $HTTPS_PROXY and $NO_PROXY. An empty
+// proxyURL connects directly.
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *Client) error {
+		s := c.transportSettings()
+		s.proxy, s.proxySet = nil, true
+		if proxyURL == "" {
+			return nil
+		}
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return err
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("frabit: unsupported proxy scheme %q", u.Scheme)
+		}
+		s.proxy = u
+		return nil
+	}
+}
+
+func WithConnectionPool(pool ConnectionPool) ClientOption {
+	return func(c *Client) error {
+		c.transportSettings().pool = &pool
+		return nil
+	}
+}
+
+// withUnixSocket sends every request over the unix socket at path, used
+// for base URLs like unix:///run/frabit/agent.sock.
+func (c *Client) withUnixSocket(path string) {
+	c.transportSettings().socket = path
+	c.BaseURL = &url.URL{Scheme: "http", Host: unixSocketHost, Path: "/"}
+}
+
+// transportSettings returns the pending transport settings, creating them on first use.
+func (c *Client) transportSettings() *transportSettings {
+	if c.transport == nil {
+		c.transport = &transportSettings{}
+	}
+	return c.transport
+}
+
+// applyTransportConfig applies the TLS and transport options to a copy of
+// the client's transport.
+func (c *Client) applyTransportConfig() error {
+	if c.tls == nil && c.transport == nil {
+		return nil
+	}
+	rt := c.client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	base, ok := rt.(*http.Transport)
+	if !ok {
+		return errors.New("frabit: TLS and transport options require the client transport to be an *http.Transport")
+	}
+	t := base.Clone()
+	if c.tls != nil {
+		t.TLSClientConfig = c.tls
+	}
+	if s := c.transport; s != nil {
+		s.apply(t)
+	}
+	client := *c.client
+	client.Transport = t
+	c.client = &client
+	return nil
+}
+
+func (s *transportSettings) apply(t *http.Transport) {
+	if s.dialTimeout != 0 || s.socket != "" {
+		dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultKeepAlive}
+		if s.dialTimeout != 0 {
+			dialer.Timeout = s.dialTimeout
+		}
+		t.DialContext = dialer.DialContext
+		if socket := s.socket; socket != "" {
+			t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			}
+		}
+	}
+	if s.tlsHandshakeTimeout != 0 {
+		t.TLSHandshakeTimeout = s.tlsHandshakeTimeout
+	}
+	if s.responseHeaderTimeout != 0 {
+		t.ResponseHeaderTimeout = s.responseHeaderTimeout
+	}
+	switch {
+	case s.socket != "":
+		t.Proxy = nil
+	case s.proxySet && s.proxy == nil:
+		t.Proxy = nil
+	case s.proxySet:
+		t.Proxy = http.ProxyURL(s.proxy)
+	}
+	if p := s.pool; p != nil {
+		t.DisableKeepAlives = false
+		t.MaxIdleConns = p.MaxIdleConns
+		t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+		t.MaxConnsPerHost = p.MaxConnsPerHost
+		if p.IdleConnTimeout != 0 {
+			t.IdleConnTimeout = p.IdleConnTimeout
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUnixSocketBaseURL(t *testing.T) {
+	dir, err := os.MkdirTemp("", "frabit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name":"via-socket"}`))
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	client, err := NewClient(WithBaseURL("unix://"+sock), WithProxy("http://proxy.invalid:3128"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := client.User.GetUser(context.Background())
+	if err != nil || user.Name != "via-socket" {
+		t.Fatalf("GetUser = %+v, %v", user, err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		_, _ = w.Write([]byte(`{"name":"via-proxy"}`))
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient(WithBaseURL("http://frabit.internal"), WithProxy(proxy.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.User.GetUser(context.Background()); err != nil || proxied != "http://frabit.internal/user" {
+		t.Errorf("proxied %q, %v", proxied, err)
+	}
+
+	if _, err := NewClient(WithProxy("ftp://proxy.internal")); err == nil {
+		t.Error("ftp proxy accepted")
+	}
+	client, err = NewClient(WithBaseURL("http://frabit.internal"), WithProxy("socks5://127.0.0.1:1080"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://frabit.internal/", nil)
+	if u, err := client.client.Transport.(*http.Transport).Proxy(req); err != nil || u.String() != "socks5://127.0.0.1:1080" {
+		t.Errorf("socks5 proxy = %v, %v", u, err)
+	}
+}
+
+func TestTransportOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	shared := &http.Client{Transport: &http.Transport{}}
+	client, err := NewClient(WithBaseURL(srv.URL), WithHTTPClient(shared),
+		WithResponseHeaderTimeout(10*time.Millisecond), WithDialTimeout(time.Second), WithTLSHandshakeTimeout(2*time.Second),
+		WithConnectionPool(ConnectionPool{MaxIdleConns: 20, MaxIdleConnsPerHost: 5, MaxConnsPerHost: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := client.client.Transport.(*http.Transport)
+	if transport == shared.Transport || shared.Transport.(*http.Transport).ResponseHeaderTimeout != 0 {
+		t.Error("the shared transport was modified")
+	}
+	if transport.TLSHandshakeTimeout != 2*time.Second || transport.MaxIdleConnsPerHost != 5 || transport.MaxConnsPerHost != 10 || transport.DisableKeepAlives {
+		t.Errorf("transport = %+v", transport)
+	}
+	if _, err := client.User.GetUser(context.Background()); err == nil {
+		t.Error("response header timeout not applied")
+	}
+
+	client, _ = NewClient(WithBaseURL(srv.URL), WithTimeout(10*time.Millisecond))
+	var netErr net.Error
+	if _, err := client.User.GetUser(context.Background()); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("overall timeout: %v", err)
+	}
+
+	called := false
+	mesh := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client, _ = NewClient(WithBaseURL(srv.URL), WithTransport(mesh))
+	if _, err := client.User.GetUser(context.Background()); err != nil || !called {
+		t.Errorf("custom transport: called %v, %v", called, err)
+	}
+	if _, err := NewClient(WithTransport(mesh), WithDialTimeout(time.Second)); err == nil {
+		t.Error("transport options accepted for a custom RoundTripper")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -15,11 +15,24 @@
 
 package frabit
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type UserService interface {
 	GetUser(ctx context.Context) (*User, error)
 	CreateUser(ctx context.Context, req CreateUserRequest) (*User, error)
+	// GetCurrentUser returns the identity, org memberships and effective
+	// scopes of the credentials the client authenticates with.
+	GetCurrentUser(ctx context.Context) (*CurrentUser, error)
+
+	CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (*APIKeySecret, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string) error
+	// RotateAPIKey issues a new secret for the key. The old secret keeps
+	// working for gracePeriod, so that it can be replaced wherever it is used.
+	RotateAPIKey(ctx context.Context, keyID string, gracePeriod time.Duration) (*APIKeySecret, error)
 }
 
 type userService struct {
@@ -14,3 +14,76 @@
 // limitations under the License.
 
 package frabit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyRotation(t *testing.T) {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rotated rotateAPIKeyRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v2/user/me":
+			json.NewEncoder(w).Encode(CurrentUser{ID: "u1", Login: "rotator", AuthMethod: "api_key", APIKeyID: "k1", Scopes: []string{"api_keys:write"},
+				Orgs: []OrgMembership{{OrgID: "o1", OrgName: "acme", Role: "admin"}}})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v2/user/api-keys":
+			var req CreateAPIKeyRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.ExpiresAt == nil || !req.ExpiresAt.Equal(expires) || len(req.Scopes) != 1 {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(APIKeySecret{APIKey: APIKey{ID: "k2", Name: req.Name, Scopes: req.Scopes, ExpiresAt: *req.ExpiresAt}, Secret: "frb_first"})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v2/user/api-keys/k2/rotate":
+			json.NewDecoder(r.Body).Decode(&rotated)
+			json.NewEncoder(w).Encode(APIKeySecret{APIKey: APIKey{ID: "k2"}, Secret: "frb_second"})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v2/user/api-keys":
+			json.NewEncoder(w).Encode([]APIKey{{ID: "k1"}, {ID: "k2", RevokedAt: time.Now()}})
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/v2/user/api-keys/k1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(WithBaseURL(srv.URL), WithToken("frb_current"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	me, err := client.User.GetCurrentUser(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !me.HasScope("api_keys:write") || me.HasScope("backups:write") || me.Orgs[0].Role != "admin" {
+		t.Errorf("current user = %+v", me)
+	}
+
+	key, err := client.User.CreateAPIKey(ctx, CreateAPIKeyRequest{Name: "rotation", Scopes: []string{"backups:read"}, ExpiresAt: &expires})
+	if err != nil || key.Secret != "frb_first" || !key.Active(time.Now()) || key.Active(expires) {
+		t.Fatalf("created %+v, %v", key, err)
+	}
+	key, err = client.User.RotateAPIKey(ctx, key.ID, 10*time.Minute)
+	if err != nil || key.Secret != "frb_second" || rotated.GracePeriodSeconds != 600 {
+		t.Fatalf("rotated %+v with %+v, %v", key, rotated, err)
+	}
+
+	keys, err := client.User.ListAPIKeys(ctx)
+	if err != nil || len(keys) != 2 || keys[1].Active(time.Now()) {
+		t.Fatalf("keys = %+v, %v", keys, err)
+	}
+	if err := client.User.RevokeAPIKey(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.User.RevokeAPIKey(ctx, "missing"); !IsErrorCode(err, ErrNotFound) {
+		t.Errorf("revoke missing key: %v", err)
+	}
+}
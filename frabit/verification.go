@@ -0,0 +1,189 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type VerifyMode string
+
+const (
+	// VerifyChecksum re-reads the stored artifact and compares its checksum.
+	VerifyChecksum VerifyMode = "checksum"
+	// VerifyStructural opens the artifact and validates the archive and
+	// its manifest without restoring it.
+	VerifyStructural VerifyMode = "structural"
+	// VerifyTestRestore restores the backup into a scratch instance.
+	VerifyTestRestore VerifyMode = "test_restore"
+)
+
+type VerificationStatus string
+
+const (
+	VerificationPending VerificationStatus = "pending"
+	VerificationRunning VerificationStatus = "running"
+	VerificationPassed  VerificationStatus = "passed"
+	VerificationFailed  VerificationStatus = "failed"
+)
+
+// VerificationReport is the outcome of verifying one backup. Only the result
+// section of the report's mode and the modes it implies is filled in: a
+// structural verification also checks the checksum, a test restore does all
+// three.
+type VerificationReport struct {
+	ID         string             `json:"id"`
+	BackupID   string             `json:"backup_id"`
+	Mode       VerifyMode         `json:"mode"`
+	Status     VerificationStatus `json:"status"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at,omitempty"`
+	Error      string             `json:"error,omitempty"`
+
+	Checksum    *ChecksumResult    `json:"checksum,omitempty"`
+	Structure   *StructuralResult  `json:"structure,omitempty"`
+	TestRestore *TestRestoreResult `json:"test_restore,omitempty"`
+}
+
+// Passed reports whether the verification finished without finding a problem.
+func (r *VerificationReport) Passed() bool {
+	return r.Status == VerificationPassed
+}
+
+type ChecksumResult struct {
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Bytes    int64  `json:"bytes"`
+}
+
+func (c *ChecksumResult) Match() bool {
+	return c.Expected != "" && c.Expected == c.Actual
+}
+
+type StructuralResult struct {
+	Method string `json:"method"`
+	// Decrypted and Decompressed tell whether those layers of the artifact
+	// were opened successfully.
+	Decrypted    bool  `json:"decrypted"`
+	Decompressed bool  `json:"decompressed"`
+	Bytes        int64 `json:"bytes"`
+}
+
+type TestRestoreResult struct {
+	// ScratchInstance identifies the instance the backup was restored into.
+	ScratchInstance string        `json:"scratch_instance"`
+	Duration        time.Duration `json:"duration"`
+}
+
+// VerificationSchedule verifies backups taken under a backup policy on a cron
+// schedule. Sample limits each run to the latest N backups, 0 meaning only
+// the newest one.
+type VerificationSchedule struct {
+	ID        string     `json:"id"`
+	PolicyID  string     `json:"policy_id"`
+	Mode      VerifyMode `json:"mode"`
+	Cron      string     `json:"cron"`
+	Sample    int        `json:"sample"`
+	Enabled   bool       `json:"enabled"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type CreateVerificationScheduleRequest struct {
+	PolicyID string     `json:"-"`
+	Mode     VerifyMode `json:"mode"`
+	Cron     string     `json:"cron"`
+	Sample   int        `json:"sample"`
+	Enabled  bool       `json:"enabled"`
+}
+
+type verifyBackupRequest struct {
+	Mode VerifyMode `json:"mode"`
+}
+
+func (u *backupService) Verify(ctx context.Context, backupID string, mode VerifyMode) (*VerificationReport, error) {
+	request, err := u.Client.newRequest("post", fmt.Sprintf("/api/v2/backups/%s/verifications", backupID), verifyBackupRequest{Mode: mode})
+	if err != nil {
+		return nil, err
+	}
+	report := &VerificationReport{}
+	err = u.do(ctx, request, report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (u *backupService) GetVerification(ctx context.Context, backupID, verificationID string) (*VerificationReport, error) {
+	request, err := u.Client.newRequest("get", fmt.Sprintf("/api/v2/backups/%s/verifications/%s", backupID, verificationID), nil)
+	if err != nil {
+		return nil, err
+	}
+	report := &VerificationReport{}
+	err = u.do(ctx, request, report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (u *backupService) ListVerifications(ctx context.Context, backupID string) ([]VerificationReport, error) {
+	request, err := u.Client.newRequest("get", fmt.Sprintf("/api/v2/backups/%s/verifications", backupID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var reports []VerificationReport
+	err = u.do(ctx, request, &reports)
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (u *backupService) ListVerificationSchedules(ctx context.Context, policyID string) ([]VerificationSchedule, error) {
+	request, err := u.Client.newRequest("get", fmt.Sprintf("/api/v2/backup-policies/%s/verification-schedules", policyID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var schedules []VerificationSchedule
+	err = u.do(ctx, request, &schedules)
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (u *backupService) CreateVerificationSchedule(ctx context.Context, req CreateVerificationScheduleRequest) (*VerificationSchedule, error) {
+	request, err := u.Client.newRequest("post", fmt.Sprintf("/api/v2/backup-policies/%s/verification-schedules", req.PolicyID), req)
+	if err != nil {
+		return nil, err
+	}
+	schedule := &VerificationSchedule{}
+	err = u.do(ctx, request, schedule)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (u *backupService) DeleteVerificationSchedule(ctx context.Context, policyID, scheduleID string) error {
+	request, err := u.Client.newRequest("delete", fmt.Sprintf("/api/v2/backup-policies/%s/verification-schedules/%s", policyID, scheduleID), nil)
+	if err != nil {
+		return err
+	}
+	return u.do(ctx, request, nil)
+}
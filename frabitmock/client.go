@@ -0,0 +1,77 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabitmock
+
+import (
+	"testing"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// Services holds the fakes behind a client made by NewClient.
+type Services struct {
+	Database     *DatabaseService
+	Org          *OrgService
+	Team         *TeamService
+	Cluster      *ClusterService
+	Restore      *RestoreService
+	Agent        *AgentService
+	Backup       *BackupService
+	Archive      *ArchiveService
+	Notification *NotificationService
+	User         *UserService
+}
+
+// NewClient returns a client whose services are fakes reporting to t, and
+// the fakes themselves. The client sends no requests; opts configure the
+// rest of it as they would for frabit.NewClient.
+func NewClient(t testing.TB, opts ...frabit.ClientOption) (*frabit.Client, *Services) {
+	t.Helper()
+	client, err := frabit.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("frabitmock: creating client: %v", err)
+	}
+	mocks := &Services{
+		Database:     &DatabaseService{},
+		Org:          &OrgService{},
+		Team:         &TeamService{},
+		Cluster:      &ClusterService{},
+		Restore:      &RestoreService{},
+		Agent:        &AgentService{},
+		Backup:       &BackupService{},
+		Archive:      &ArchiveService{},
+		Notification: &NotificationService{},
+		User:         &UserService{},
+	}
+	for _, m := range []*Mock{
+		&mocks.Database.Mock, &mocks.Org.Mock, &mocks.Team.Mock, &mocks.Cluster.Mock, &mocks.Restore.Mock,
+		&mocks.Agent.Mock, &mocks.Backup.Mock, &mocks.Archive.Mock, &mocks.Notification.Mock, &mocks.User.Mock,
+	} {
+		m.Test(t)
+	}
+
+	client.Database = mocks.Database
+	client.Org = mocks.Org
+	client.Team = mocks.Team
+	client.Cluster = mocks.Cluster
+	client.Restore = mocks.Restore
+	client.Agent = mocks.Agent
+	client.Backup = mocks.Backup
+	client.Archive = mocks.Archive
+	client.Notification = mocks.Notification
+	client.User = mocks.User
+	return client, mocks
+}
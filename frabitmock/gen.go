@@ -0,0 +1,150 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build ignore
+
+// gen writes services.go, a fake for every service interface of the frabit
+// package. Run it with go generate after changing one of them.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+const frabitPath = "github.com/frabits/frabit-go-sdk/frabit"
+
+func main() {
+	pkg, err := importer.ForCompiler(token.NewFileSet(), "source", nil).Import(frabitPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	header, err := os.ReadFile("mock.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	license, _, _ := strings.Cut(string(header), "\n\n")
+
+	var names []string
+	for _, name := range pkg.Scope().Names() {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || !obj.Exported() || !strings.HasSuffix(name, "Service") {
+			continue
+		}
+		if _, ok := obj.Type().Underlying().(*types.Interface); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var fakes bytes.Buffer
+	for _, name := range names {
+		writeFake(&fakes, name, pkg.Scope().Lookup(name).Type().Underlying().(*types.Interface))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n\n// Code generated by gen.go; DO NOT EDIT.\n\npackage frabitmock\n\nimport (\n", license)
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	// standard library first, then the rest
+	sort.Slice(paths, func(i, j int) bool {
+		si, sj := strings.Contains(paths[i], "."), strings.Contains(paths[j], ".")
+		if si != sj {
+			return sj
+		}
+		return paths[i] < paths[j]
+	})
+	for i, path := range paths {
+		if i > 0 && strings.Contains(path, ".") && !strings.Contains(paths[i-1], ".") {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%q\n", path)
+	}
+	b.WriteString(")\n")
+	b.Write(fakes.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("formatting: %v\n%s", err, b.Bytes())
+	}
+	if err := os.WriteFile("services.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// imports collects the packages the fakes refer to.
+var imports = map[string]bool{}
+
+func qualifier(p *types.Package) string {
+	imports[p.Path()] = true
+	if p.Path() == frabitPath {
+		return "frabit"
+	}
+	return p.Name()
+}
+
+func writeFake(b *bytes.Buffer, name string, iface *types.Interface) {
+	fmt.Fprintf(b, "\n// %s is a fake frabit.%s.\ntype %s struct {\nMock\n\n", name, name, name)
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		fmt.Fprintf(b, "%sFunc func%s\n", m.Name(), strings.TrimPrefix(types.TypeString(m.Type(), qualifier), "func"))
+	}
+	b.WriteString("}\n")
+	fmt.Fprintf(b, "\nvar _ frabit.%s = (*%s)(nil)\n", name, name)
+
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		sig := m.Type().(*types.Signature)
+
+		var params, args, recorded []string
+		for j := 0; j < sig.Params().Len(); j++ {
+			p := sig.Params().At(j)
+			pname := p.Name()
+			if pname == "" || pname == "_" {
+				pname = fmt.Sprintf("arg%d", j)
+			}
+			params = append(params, pname+" "+types.TypeString(p.Type(), qualifier))
+			args = append(args, pname)
+			if types.TypeString(p.Type(), nil) != "context.Context" {
+				recorded = append(recorded, pname)
+			}
+		}
+		var results, returns []string
+		for j := 0; j < sig.Results().Len(); j++ {
+			t := types.TypeString(sig.Results().At(j).Type(), qualifier)
+			results = append(results, t)
+			returns = append(returns, fmt.Sprintf("returned[%s](ret, %d)", t, j))
+		}
+		resultList := strings.Join(results, ", ")
+		if len(results) > 1 {
+			resultList = "(" + resultList + ")"
+		}
+
+		fmt.Fprintf(b, "\nfunc (f *%s) %s(%s) %s {\n", name, m.Name(), strings.Join(params, ", "), resultList)
+		fmt.Fprintf(b, "ret, ok := f.Called(%s)\n", strings.Join(append([]string{fmt.Sprintf("%q", m.Name())}, recorded...), ", "))
+		fmt.Fprintf(b, "if !ok && f.%sFunc != nil {\nreturn f.%sFunc(%s)\n}\n", m.Name(), m.Name(), strings.Join(args, ", "))
+		fmt.Fprintf(b, "return %s\n}\n", strings.Join(returns, ", "))
+	}
+}
@@ -0,0 +1,237 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package frabitmock provides in-memory fakes of the frabit service
+// interfaces, so code using the SDK can be unit tested without HTTP.
+//
+// Every fake records its calls and answers them, in order of preference,
+// with the values of a matching expectation, with its XxxFunc field, or with
+// zero values:
+//
+//	client, mocks := frabitmock.NewClient(t)
+//	mocks.Database.Expect("CreateDatabase", frabitmock.Any).Return(&frabit.Database{Name: "orders"}, nil)
+//	mocks.Agent.ListAgentsFunc = func(ctx context.Context) ([]frabit.Agent, error) { ... }
+//
+// Expectations not met by the end of the test fail it.
+package frabitmock
+
+//go:generate go run gen.go
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Any matches any argument of an expected call.
+var Any any = anyArg{}
+
+type anyArg struct{}
+
+func (anyArg) String() string { return "Any" }
+
+// Call is a call made on a fake. Args leave out the context.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+func (c Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = fmt.Sprintf("%+v", a)
+	}
+	return c.Method + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Expectation is a call a fake expects, made with Mock.Expect.
+type Expectation struct {
+	method string
+	args   []any
+	ret    []any
+	// times is the number of calls expected, -1 for any number
+	times int
+	calls int
+}
+
+// Return sets the values the call returns, one per result of the method.
+func (e *Expectation) Return(values ...any) *Expectation {
+	e.ret = values
+	return e
+}
+
+// Times sets how often the call is expected; the default is once.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// AnyTimes lets the call happen any number of times, including never.
+func (e *Expectation) AnyTimes() *Expectation {
+	e.times = -1
+	return e
+}
+
+func (e *Expectation) matches(method string, args []any) bool {
+	if e.method != method || (e.args != nil && len(e.args) != len(args)) {
+		return false
+	}
+	for i, want := range e.args {
+		if want != Any && !reflect.DeepEqual(want, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times >= 0 && e.calls >= e.times
+}
+
+func (e *Expectation) String() string {
+	if e.args == nil {
+		return e.method + "(...)"
+	}
+	return Call{Method: e.method, Args: e.args}.String()
+}
+
+// Mock records the calls made on a fake and checks them against its
+// expectations. It is embedded in every fake of this package.
+type Mock struct {
+	mu       sync.Mutex
+	t        testing.TB
+	calls    []Call
+	expected []*Expectation
+}
+
+// Test reports failures to t and checks, when t ends, that every
+// expectation was met. NewClient calls it for all fakes.
+func (m *Mock) Test(t testing.TB) {
+	m.mu.Lock()
+	m.t = t
+	m.mu.Unlock()
+	t.Cleanup(func() { m.AssertExpectations(t) })
+}
+
+// Expect adds an expected call of method with args, which are compared
+// with reflect.DeepEqual unless they are Any. Without args the call
+// matches whatever it is called with.
+//
+// Once a method has expectations, calling it in a way none of them match
+// fails the test.
+func (m *Mock) Expect(method string, args ...any) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{method: method, args: args, times: 1}
+	if len(args) == 0 {
+		e.args = nil
+	}
+	m.expected = append(m.expected, e)
+	return e
+}
+
+// Called records a call and returns the values of the expectation it
+// matches; ok is false when it matches none or the expectation sets no
+// return values. The generated fakes call it from every method.
+func (m *Mock) Called(method string, args ...any) (ret []any, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	call := Call{Method: method, Args: args}
+	m.calls = append(m.calls, call)
+
+	expected := false
+	for _, e := range m.expected {
+		if e.method != method {
+			continue
+		}
+		expected = true
+		if e.exhausted() || !e.matches(method, args) {
+			continue
+		}
+		e.calls++
+		return e.ret, e.ret != nil
+	}
+	if expected {
+		m.fail("frabitmock: unexpected call %s", call)
+	}
+	return nil, false
+}
+
+// Calls returns the calls made so far, oldest first.
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallCount returns how often method was called.
+func (m *Mock) CallCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, c := range m.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// AssertExpectations fails t for every expectation that was not met.
+func (m *Mock) AssertExpectations(t testing.TB) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ok := true
+	for _, e := range m.expected {
+		if e.times >= 0 && e.calls != e.times {
+			t.Errorf("frabitmock: %s called %d times, expected %d", e, e.calls, e.times)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Reset forgets the recorded calls and the expectations.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+	m.expected = nil
+}
+
+func (m *Mock) fail(format string, args ...any) {
+	if m.t == nil {
+		panic(fmt.Sprintf(format, args...))
+	}
+	m.t.Helper()
+	m.t.Errorf(format, args...)
+}
+
+// returned converts the i-th value passed to Expectation.Return to the
+// type of the method result. A missing or nil value is the zero value.
+func returned[T any](ret []any, i int) T {
+	var zero T
+	if i >= len(ret) || ret[i] == nil {
+		return zero
+	}
+	v, ok := ret[i].(T)
+	if !ok {
+		panic(fmt.Sprintf("frabitmock: return value %d is %T, want %T", i, ret[i], zero))
+	}
+	return v
+}
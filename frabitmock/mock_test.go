@@ -0,0 +1,100 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabitmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// recorder stands in for a test so expectation failures can be checked.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNewClient(t *testing.T) {
+	client, mocks := NewClient(t)
+	ctx := context.Background()
+
+	mocks.Database.Expect("CreateDatabase", frabit.CreateDatabaseRequest{Name: "orders"}).Return(&frabit.Database{Name: "orders"}, nil)
+	if db, err := client.Database.CreateDatabase(ctx, frabit.CreateDatabaseRequest{Name: "orders"}); err != nil || db.Name != "orders" {
+		t.Errorf("CreateDatabase = %+v, %v", db, err)
+	}
+
+	mocks.Agent.ListAgentsFunc = func(context.Context) ([]frabit.Agent, error) {
+		return []frabit.Agent{{AgentID: "agent-1"}}, nil
+	}
+	if agents, err := client.Agent.ListAgents(ctx); err != nil || len(agents) != 1 {
+		t.Errorf("ListAgents = %+v, %v", agents, err)
+	}
+
+	if report, err := client.Backup.Verify(ctx, "b1", frabit.VerifyChecksum); report != nil || err != nil {
+		t.Errorf("Verify without behaviour = %+v, %v", report, err)
+	}
+	calls := mocks.Backup.Calls()
+	if len(calls) != 1 || calls[0].String() != "Verify(b1, checksum)" {
+		t.Errorf("Calls = %v", calls)
+	}
+}
+
+func TestExpectations(t *testing.T) {
+	rec := &recorder{TB: t}
+	var users UserService
+	users.Test(rec)
+	ctx := context.Background()
+
+	denied := errors.New("denied")
+	users.Expect("RevokeAPIKey", "key-1").Return(denied)
+	users.Expect("RevokeAPIKey", Any).Times(2)
+	users.Expect("GetUser").AnyTimes()
+
+	if err := users.RevokeAPIKey(ctx, "key-1"); err != denied {
+		t.Errorf("first RevokeAPIKey = %v", err)
+	}
+	if err := users.RevokeAPIKey(ctx, "key-1"); err != nil {
+		t.Errorf("second RevokeAPIKey = %v", err)
+	}
+	if users.CallCount("RevokeAPIKey") != 2 {
+		t.Errorf("CallCount = %d", users.CallCount("RevokeAPIKey"))
+	}
+	if users.AssertExpectations(rec) || len(rec.errors) != 1 {
+		t.Errorf("unmet expectation: %q", rec.errors)
+	}
+
+	rec.errors = nil
+	_ = users.RevokeAPIKey(ctx, "key-2")
+	_ = users.RevokeAPIKey(ctx, "key-3")
+	if len(rec.errors) != 1 {
+		t.Errorf("call beyond expectations: %q", rec.errors)
+	}
+
+	users.Reset()
+	rec.errors = nil
+	if _, err := users.GetCurrentUser(ctx); err != nil || len(rec.errors) != 0 {
+		t.Errorf("after Reset: %v, %q", err, rec.errors)
+	}
+}
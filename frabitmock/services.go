@@ -0,0 +1,680 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by gen.go; DO NOT EDIT.
+
+package frabitmock
+
+import (
+	"context"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// AgentService is a fake frabit.AgentService.
+type AgentService struct {
+	Mock
+
+	AppendLogsFunc      func(ctx context.Context, req frabit.TaskLogs) error
+	ClaimTaskFunc       func(ctx context.Context, req frabit.ClaimTaskRequest) (*frabit.TaskLease, error)
+	DeregisterFunc      func(ctx context.Context, agentID string) error
+	EnrollFunc          func(ctx context.Context, req frabit.EnrollAgentRequest) (*frabit.EnrollAgentResponse, error)
+	ExtendLeaseFunc     func(ctx context.Context, req frabit.ExtendLeaseRequest) (*frabit.TaskLease, error)
+	HeartbeatFunc       func(ctx context.Context, req frabit.CreateHeartbeat) error
+	ListAgentsFunc      func(ctx context.Context) ([]frabit.Agent, error)
+	PollTasksFunc       func(ctx context.Context, req frabit.PollTasksRequest) ([]frabit.Task, error)
+	PushMetricsFunc     func(ctx context.Context, batch frabit.MetricBatch) error
+	RegisterFunc        func(ctx context.Context, req frabit.CreateAgentRequest) error
+	ReportInventoryFunc func(ctx context.Context, agentID string, inventory frabit.AgentInventory) error
+	ReportProgressFunc  func(ctx context.Context, req frabit.TaskProgress) error
+	SubmitResultFunc    func(ctx context.Context, req frabit.TaskResult) error
+}
+
+var _ frabit.AgentService = (*AgentService)(nil)
+
+func (f *AgentService) AppendLogs(ctx context.Context, req frabit.TaskLogs) error {
+	ret, ok := f.Called("AppendLogs", req)
+	if !ok && f.AppendLogsFunc != nil {
+		return f.AppendLogsFunc(ctx, req)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *AgentService) ClaimTask(ctx context.Context, req frabit.ClaimTaskRequest) (*frabit.TaskLease, error) {
+	ret, ok := f.Called("ClaimTask", req)
+	if !ok && f.ClaimTaskFunc != nil {
+		return f.ClaimTaskFunc(ctx, req)
+	}
+	return returned[*frabit.TaskLease](ret, 0), returned[error](ret, 1)
+}
+
+func (f *AgentService) Deregister(ctx context.Context, agentID string) error {
+	ret, ok := f.Called("Deregister", agentID)
+	if !ok && f.DeregisterFunc != nil {
+		return f.DeregisterFunc(ctx, agentID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *AgentService) Enroll(ctx context.Context, req frabit.EnrollAgentRequest) (*frabit.EnrollAgentResponse, error) {
+	ret, ok := f.Called("Enroll", req)
+	if !ok && f.EnrollFunc != nil {
+		return f.EnrollFunc(ctx, req)
+	}
+	return returned[*frabit.EnrollAgentResponse](ret, 0), returned[error](ret, 1)
+}
+
+func (f *AgentService) ExtendLease(ctx context.Context, req frabit.ExtendLeaseRequest) (*frabit.TaskLease, error) {
+	ret, ok := f.Called("ExtendLease", req)
+	if !ok && f.ExtendLeaseFunc != nil {
+		return f.ExtendLeaseFunc(ctx, req)
+	}
+	return returned[*frabit.TaskLease](ret, 0), returned[error](ret, 1)
+}
+
+func (f *AgentService) Heartbeat(ctx context.Context, req frabit.CreateHeartbeat) error {
+	ret, ok := f.Called("Heartbeat", req)
+	if !ok && f.HeartbeatFunc != nil {
+		return f.HeartbeatFunc(ctx, req)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *AgentService) ListAgents(ctx context.Context) ([]frabit.Agent, error) {
+	ret, ok := f.Called("ListAgents")
+	if !ok && f.ListAgentsFunc != nil {
+		return f.ListAgentsFunc(ctx)
+	}
+	return returned[[]frabit.Agent](ret, 0), returned[error](ret, 1)
+}
+
+func (f *AgentService) PollTasks(ctx context.Context, req frabit.PollTasksRequest) ([]frabit.Task, error) {
+	ret, ok := f.Called("PollTasks", req)
+	if !ok && f.PollTasksFunc != nil {
+		return f.PollTasksFunc(ctx, req)
+	}
+	return returned[[]frabit.Task](ret, 0), returned[error](ret, 1)
+}
+
+func (f *AgentService) PushMetrics(ctx context.Context, batch frabit.MetricBatch) error {
+	ret, ok := f.Called("PushMetrics", batch)
+	if !ok && f.PushMetricsFunc != nil {
+		return f.PushMetricsFunc(ctx, batch)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *AgentService) Register(ctx context.Context, req frabit.CreateAgentRequest) error {
+	ret, ok := f.Called("Register", req)
+	if !ok && f.RegisterFunc != nil {
+		return f.RegisterFunc(ctx, req)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *AgentService) ReportInventory(ctx context.Context, agentID string, inventory frabit.AgentInventory) error {
+	ret, ok := f.Called("ReportInventory", agentID, inventory)
+	if !ok && f.ReportInventoryFunc != nil {
+		return f.ReportInventoryFunc(ctx, agentID, inventory)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *AgentService) ReportProgress(ctx context.Context, req frabit.TaskProgress) error {
+	ret, ok := f.Called("ReportProgress", req)
+	if !ok && f.ReportProgressFunc != nil {
+		return f.ReportProgressFunc(ctx, req)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *AgentService) SubmitResult(ctx context.Context, req frabit.TaskResult) error {
+	ret, ok := f.Called("SubmitResult", req)
+	if !ok && f.SubmitResultFunc != nil {
+		return f.SubmitResultFunc(ctx, req)
+	}
+	return returned[error](ret, 0)
+}
+
+// ArchiveService is a fake frabit.ArchiveService.
+type ArchiveService struct {
+	Mock
+
+	BackupChainFunc        func(ctx context.Context, clusterID string) ([]frabit.BackupChainEntry, error)
+	GetArchiveStreamFunc   func(ctx context.Context, clusterID string, streamID string) (*frabit.ArchiveStream, error)
+	ListArchiveStreamsFunc func(ctx context.Context, clusterID string) ([]frabit.ArchiveStream, error)
+	RecoverableWindowFunc  func(ctx context.Context, clusterID string) (frabit.RecoveryWindow, error)
+}
+
+var _ frabit.ArchiveService = (*ArchiveService)(nil)
+
+func (f *ArchiveService) BackupChain(ctx context.Context, clusterID string) ([]frabit.BackupChainEntry, error) {
+	ret, ok := f.Called("BackupChain", clusterID)
+	if !ok && f.BackupChainFunc != nil {
+		return f.BackupChainFunc(ctx, clusterID)
+	}
+	return returned[[]frabit.BackupChainEntry](ret, 0), returned[error](ret, 1)
+}
+
+func (f *ArchiveService) GetArchiveStream(ctx context.Context, clusterID string, streamID string) (*frabit.ArchiveStream, error) {
+	ret, ok := f.Called("GetArchiveStream", clusterID, streamID)
+	if !ok && f.GetArchiveStreamFunc != nil {
+		return f.GetArchiveStreamFunc(ctx, clusterID, streamID)
+	}
+	return returned[*frabit.ArchiveStream](ret, 0), returned[error](ret, 1)
+}
+
+func (f *ArchiveService) ListArchiveStreams(ctx context.Context, clusterID string) ([]frabit.ArchiveStream, error) {
+	ret, ok := f.Called("ListArchiveStreams", clusterID)
+	if !ok && f.ListArchiveStreamsFunc != nil {
+		return f.ListArchiveStreamsFunc(ctx, clusterID)
+	}
+	return returned[[]frabit.ArchiveStream](ret, 0), returned[error](ret, 1)
+}
+
+func (f *ArchiveService) RecoverableWindow(ctx context.Context, clusterID string) (frabit.RecoveryWindow, error) {
+	ret, ok := f.Called("RecoverableWindow", clusterID)
+	if !ok && f.RecoverableWindowFunc != nil {
+		return f.RecoverableWindowFunc(ctx, clusterID)
+	}
+	return returned[frabit.RecoveryWindow](ret, 0), returned[error](ret, 1)
+}
+
+// BackupService is a fake frabit.BackupService.
+type BackupService struct {
+	Mock
+
+	CopyFunc                       func(ctx context.Context, backupID string, destinationStorage string) (*frabit.OperationHandle, error)
+	CopyWithRetentionFunc          func(ctx context.Context, backupID string, req frabit.CopyBackupRequest) (*frabit.OperationHandle, error)
+	CreateBackupFunc               func(ctx context.Context, req frabit.CreateBackupRequest) (*frabit.Backup, error)
+	CreateCopyRuleFunc             func(ctx context.Context, req frabit.CreateCopyRuleRequest) (*frabit.BackupCopyRule, error)
+	CreateVerificationScheduleFunc func(ctx context.Context, req frabit.CreateVerificationScheduleRequest) (*frabit.VerificationSchedule, error)
+	DeleteCopyFunc                 func(ctx context.Context, backupID string, copyID string) error
+	DeleteCopyRuleFunc             func(ctx context.Context, policyID string, ruleID string) error
+	DeleteVerificationScheduleFunc func(ctx context.Context, policyID string, scheduleID string) error
+	GetBackupFunc                  func(ctx context.Context) (*frabit.Backup, error)
+	GetOperationFunc               func(ctx context.Context, operationID string) (*frabit.Operation, error)
+	GetVerificationFunc            func(ctx context.Context, backupID string, verificationID string) (*frabit.VerificationReport, error)
+	ListCopiesFunc                 func(ctx context.Context, backupID string) ([]frabit.BackupCopy, error)
+	ListCopyRulesFunc              func(ctx context.Context, policyID string) ([]frabit.BackupCopyRule, error)
+	ListVerificationSchedulesFunc  func(ctx context.Context, policyID string) ([]frabit.VerificationSchedule, error)
+	ListVerificationsFunc          func(ctx context.Context, backupID string) ([]frabit.VerificationReport, error)
+	SetCopyRetentionFunc           func(ctx context.Context, backupID string, copyID string, retentionDays int) (*frabit.BackupCopy, error)
+	VerifyFunc                     func(ctx context.Context, backupID string, mode frabit.VerifyMode) (*frabit.VerificationReport, error)
+}
+
+var _ frabit.BackupService = (*BackupService)(nil)
+
+func (f *BackupService) Copy(ctx context.Context, backupID string, destinationStorage string) (*frabit.OperationHandle, error) {
+	ret, ok := f.Called("Copy", backupID, destinationStorage)
+	if !ok && f.CopyFunc != nil {
+		return f.CopyFunc(ctx, backupID, destinationStorage)
+	}
+	return returned[*frabit.OperationHandle](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) CopyWithRetention(ctx context.Context, backupID string, req frabit.CopyBackupRequest) (*frabit.OperationHandle, error) {
+	ret, ok := f.Called("CopyWithRetention", backupID, req)
+	if !ok && f.CopyWithRetentionFunc != nil {
+		return f.CopyWithRetentionFunc(ctx, backupID, req)
+	}
+	return returned[*frabit.OperationHandle](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) CreateBackup(ctx context.Context, req frabit.CreateBackupRequest) (*frabit.Backup, error) {
+	ret, ok := f.Called("CreateBackup", req)
+	if !ok && f.CreateBackupFunc != nil {
+		return f.CreateBackupFunc(ctx, req)
+	}
+	return returned[*frabit.Backup](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) CreateCopyRule(ctx context.Context, req frabit.CreateCopyRuleRequest) (*frabit.BackupCopyRule, error) {
+	ret, ok := f.Called("CreateCopyRule", req)
+	if !ok && f.CreateCopyRuleFunc != nil {
+		return f.CreateCopyRuleFunc(ctx, req)
+	}
+	return returned[*frabit.BackupCopyRule](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) CreateVerificationSchedule(ctx context.Context, req frabit.CreateVerificationScheduleRequest) (*frabit.VerificationSchedule, error) {
+	ret, ok := f.Called("CreateVerificationSchedule", req)
+	if !ok && f.CreateVerificationScheduleFunc != nil {
+		return f.CreateVerificationScheduleFunc(ctx, req)
+	}
+	return returned[*frabit.VerificationSchedule](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) DeleteCopy(ctx context.Context, backupID string, copyID string) error {
+	ret, ok := f.Called("DeleteCopy", backupID, copyID)
+	if !ok && f.DeleteCopyFunc != nil {
+		return f.DeleteCopyFunc(ctx, backupID, copyID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *BackupService) DeleteCopyRule(ctx context.Context, policyID string, ruleID string) error {
+	ret, ok := f.Called("DeleteCopyRule", policyID, ruleID)
+	if !ok && f.DeleteCopyRuleFunc != nil {
+		return f.DeleteCopyRuleFunc(ctx, policyID, ruleID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *BackupService) DeleteVerificationSchedule(ctx context.Context, policyID string, scheduleID string) error {
+	ret, ok := f.Called("DeleteVerificationSchedule", policyID, scheduleID)
+	if !ok && f.DeleteVerificationScheduleFunc != nil {
+		return f.DeleteVerificationScheduleFunc(ctx, policyID, scheduleID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *BackupService) GetBackup(ctx context.Context) (*frabit.Backup, error) {
+	ret, ok := f.Called("GetBackup")
+	if !ok && f.GetBackupFunc != nil {
+		return f.GetBackupFunc(ctx)
+	}
+	return returned[*frabit.Backup](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) GetOperation(ctx context.Context, operationID string) (*frabit.Operation, error) {
+	ret, ok := f.Called("GetOperation", operationID)
+	if !ok && f.GetOperationFunc != nil {
+		return f.GetOperationFunc(ctx, operationID)
+	}
+	return returned[*frabit.Operation](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) GetVerification(ctx context.Context, backupID string, verificationID string) (*frabit.VerificationReport, error) {
+	ret, ok := f.Called("GetVerification", backupID, verificationID)
+	if !ok && f.GetVerificationFunc != nil {
+		return f.GetVerificationFunc(ctx, backupID, verificationID)
+	}
+	return returned[*frabit.VerificationReport](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) ListCopies(ctx context.Context, backupID string) ([]frabit.BackupCopy, error) {
+	ret, ok := f.Called("ListCopies", backupID)
+	if !ok && f.ListCopiesFunc != nil {
+		return f.ListCopiesFunc(ctx, backupID)
+	}
+	return returned[[]frabit.BackupCopy](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) ListCopyRules(ctx context.Context, policyID string) ([]frabit.BackupCopyRule, error) {
+	ret, ok := f.Called("ListCopyRules", policyID)
+	if !ok && f.ListCopyRulesFunc != nil {
+		return f.ListCopyRulesFunc(ctx, policyID)
+	}
+	return returned[[]frabit.BackupCopyRule](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) ListVerificationSchedules(ctx context.Context, policyID string) ([]frabit.VerificationSchedule, error) {
+	ret, ok := f.Called("ListVerificationSchedules", policyID)
+	if !ok && f.ListVerificationSchedulesFunc != nil {
+		return f.ListVerificationSchedulesFunc(ctx, policyID)
+	}
+	return returned[[]frabit.VerificationSchedule](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) ListVerifications(ctx context.Context, backupID string) ([]frabit.VerificationReport, error) {
+	ret, ok := f.Called("ListVerifications", backupID)
+	if !ok && f.ListVerificationsFunc != nil {
+		return f.ListVerificationsFunc(ctx, backupID)
+	}
+	return returned[[]frabit.VerificationReport](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) SetCopyRetention(ctx context.Context, backupID string, copyID string, retentionDays int) (*frabit.BackupCopy, error) {
+	ret, ok := f.Called("SetCopyRetention", backupID, copyID, retentionDays)
+	if !ok && f.SetCopyRetentionFunc != nil {
+		return f.SetCopyRetentionFunc(ctx, backupID, copyID, retentionDays)
+	}
+	return returned[*frabit.BackupCopy](ret, 0), returned[error](ret, 1)
+}
+
+func (f *BackupService) Verify(ctx context.Context, backupID string, mode frabit.VerifyMode) (*frabit.VerificationReport, error) {
+	ret, ok := f.Called("Verify", backupID, mode)
+	if !ok && f.VerifyFunc != nil {
+		return f.VerifyFunc(ctx, backupID, mode)
+	}
+	return returned[*frabit.VerificationReport](ret, 0), returned[error](ret, 1)
+}
+
+// ClusterService is a fake frabit.ClusterService.
+type ClusterService struct {
+	Mock
+
+	CreateClusterFunc func(ctx context.Context, req frabit.CreateClusterRequest) (*frabit.Cluster, error)
+	GetClusterFunc    func(ctx context.Context) (*frabit.Cluster, error)
+}
+
+var _ frabit.ClusterService = (*ClusterService)(nil)
+
+func (f *ClusterService) CreateCluster(ctx context.Context, req frabit.CreateClusterRequest) (*frabit.Cluster, error) {
+	ret, ok := f.Called("CreateCluster", req)
+	if !ok && f.CreateClusterFunc != nil {
+		return f.CreateClusterFunc(ctx, req)
+	}
+	return returned[*frabit.Cluster](ret, 0), returned[error](ret, 1)
+}
+
+func (f *ClusterService) GetCluster(ctx context.Context) (*frabit.Cluster, error) {
+	ret, ok := f.Called("GetCluster")
+	if !ok && f.GetClusterFunc != nil {
+		return f.GetClusterFunc(ctx)
+	}
+	return returned[*frabit.Cluster](ret, 0), returned[error](ret, 1)
+}
+
+// DatabaseService is a fake frabit.DatabaseService.
+type DatabaseService struct {
+	Mock
+
+	CreateDatabaseFunc func(ctx context.Context, req frabit.CreateDatabaseRequest) (*frabit.Database, error)
+	GetDatabaseFunc    func(ctx context.Context) (*frabit.Database, error)
+}
+
+var _ frabit.DatabaseService = (*DatabaseService)(nil)
+
+func (f *DatabaseService) CreateDatabase(ctx context.Context, req frabit.CreateDatabaseRequest) (*frabit.Database, error) {
+	ret, ok := f.Called("CreateDatabase", req)
+	if !ok && f.CreateDatabaseFunc != nil {
+		return f.CreateDatabaseFunc(ctx, req)
+	}
+	return returned[*frabit.Database](ret, 0), returned[error](ret, 1)
+}
+
+func (f *DatabaseService) GetDatabase(ctx context.Context) (*frabit.Database, error) {
+	ret, ok := f.Called("GetDatabase")
+	if !ok && f.GetDatabaseFunc != nil {
+		return f.GetDatabaseFunc(ctx)
+	}
+	return returned[*frabit.Database](ret, 0), returned[error](ret, 1)
+}
+
+// NotificationService is a fake frabit.NotificationService.
+type NotificationService struct {
+	Mock
+
+	CreateChannelFunc     func(ctx context.Context, req frabit.CreateChannelRequest) (*frabit.NotificationChannel, error)
+	DeleteChannelFunc     func(ctx context.Context, channelID string) error
+	GetChannelFunc        func(ctx context.Context, channelID string) (*frabit.NotificationChannel, error)
+	ListChannelsFunc      func(ctx context.Context) ([]frabit.NotificationChannel, error)
+	ListSubscriptionsFunc func(ctx context.Context) ([]frabit.Subscription, error)
+	SubscribeFunc         func(ctx context.Context, req frabit.SubscribeRequest) (*frabit.Subscription, error)
+	TestChannelFunc       func(ctx context.Context, channelID string) error
+	UnsubscribeFunc       func(ctx context.Context, subscriptionID string) error
+	UpdateChannelFunc     func(ctx context.Context, channelID string, req frabit.CreateChannelRequest) (*frabit.NotificationChannel, error)
+}
+
+var _ frabit.NotificationService = (*NotificationService)(nil)
+
+func (f *NotificationService) CreateChannel(ctx context.Context, req frabit.CreateChannelRequest) (*frabit.NotificationChannel, error) {
+	ret, ok := f.Called("CreateChannel", req)
+	if !ok && f.CreateChannelFunc != nil {
+		return f.CreateChannelFunc(ctx, req)
+	}
+	return returned[*frabit.NotificationChannel](ret, 0), returned[error](ret, 1)
+}
+
+func (f *NotificationService) DeleteChannel(ctx context.Context, channelID string) error {
+	ret, ok := f.Called("DeleteChannel", channelID)
+	if !ok && f.DeleteChannelFunc != nil {
+		return f.DeleteChannelFunc(ctx, channelID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *NotificationService) GetChannel(ctx context.Context, channelID string) (*frabit.NotificationChannel, error) {
+	ret, ok := f.Called("GetChannel", channelID)
+	if !ok && f.GetChannelFunc != nil {
+		return f.GetChannelFunc(ctx, channelID)
+	}
+	return returned[*frabit.NotificationChannel](ret, 0), returned[error](ret, 1)
+}
+
+func (f *NotificationService) ListChannels(ctx context.Context) ([]frabit.NotificationChannel, error) {
+	ret, ok := f.Called("ListChannels")
+	if !ok && f.ListChannelsFunc != nil {
+		return f.ListChannelsFunc(ctx)
+	}
+	return returned[[]frabit.NotificationChannel](ret, 0), returned[error](ret, 1)
+}
+
+func (f *NotificationService) ListSubscriptions(ctx context.Context) ([]frabit.Subscription, error) {
+	ret, ok := f.Called("ListSubscriptions")
+	if !ok && f.ListSubscriptionsFunc != nil {
+		return f.ListSubscriptionsFunc(ctx)
+	}
+	return returned[[]frabit.Subscription](ret, 0), returned[error](ret, 1)
+}
+
+func (f *NotificationService) Subscribe(ctx context.Context, req frabit.SubscribeRequest) (*frabit.Subscription, error) {
+	ret, ok := f.Called("Subscribe", req)
+	if !ok && f.SubscribeFunc != nil {
+		return f.SubscribeFunc(ctx, req)
+	}
+	return returned[*frabit.Subscription](ret, 0), returned[error](ret, 1)
+}
+
+func (f *NotificationService) TestChannel(ctx context.Context, channelID string) error {
+	ret, ok := f.Called("TestChannel", channelID)
+	if !ok && f.TestChannelFunc != nil {
+		return f.TestChannelFunc(ctx, channelID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *NotificationService) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	ret, ok := f.Called("Unsubscribe", subscriptionID)
+	if !ok && f.UnsubscribeFunc != nil {
+		return f.UnsubscribeFunc(ctx, subscriptionID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *NotificationService) UpdateChannel(ctx context.Context, channelID string, req frabit.CreateChannelRequest) (*frabit.NotificationChannel, error) {
+	ret, ok := f.Called("UpdateChannel", channelID, req)
+	if !ok && f.UpdateChannelFunc != nil {
+		return f.UpdateChannelFunc(ctx, channelID, req)
+	}
+	return returned[*frabit.NotificationChannel](ret, 0), returned[error](ret, 1)
+}
+
+// OrgService is a fake frabit.OrgService.
+type OrgService struct {
+	Mock
+
+	CreateOrgFunc func(ctx context.Context, req frabit.OrgCreateRequest) error
+	UpdateOrgFunc func(ctx context.Context, req frabit.OrgUpdateRequest) error
+}
+
+var _ frabit.OrgService = (*OrgService)(nil)
+
+func (f *OrgService) CreateOrg(ctx context.Context, req frabit.OrgCreateRequest) error {
+	ret, ok := f.Called("CreateOrg", req)
+	if !ok && f.CreateOrgFunc != nil {
+		return f.CreateOrgFunc(ctx, req)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *OrgService) UpdateOrg(ctx context.Context, req frabit.OrgUpdateRequest) error {
+	ret, ok := f.Called("UpdateOrg", req)
+	if !ok && f.UpdateOrgFunc != nil {
+		return f.UpdateOrgFunc(ctx, req)
+	}
+	return returned[error](ret, 0)
+}
+
+// ProjectService is a fake frabit.ProjectService.
+type ProjectService struct {
+	Mock
+
+	CreateProjectFunc func(ctx context.Context, req frabit.CreateProjectRequest) (*frabit.Project, error)
+	GetProjectFunc    func(ctx context.Context) (*frabit.Project, error)
+}
+
+var _ frabit.ProjectService = (*ProjectService)(nil)
+
+func (f *ProjectService) CreateProject(ctx context.Context, req frabit.CreateProjectRequest) (*frabit.Project, error) {
+	ret, ok := f.Called("CreateProject", req)
+	if !ok && f.CreateProjectFunc != nil {
+		return f.CreateProjectFunc(ctx, req)
+	}
+	return returned[*frabit.Project](ret, 0), returned[error](ret, 1)
+}
+
+func (f *ProjectService) GetProject(ctx context.Context) (*frabit.Project, error) {
+	ret, ok := f.Called("GetProject")
+	if !ok && f.GetProjectFunc != nil {
+		return f.GetProjectFunc(ctx)
+	}
+	return returned[*frabit.Project](ret, 0), returned[error](ret, 1)
+}
+
+// RestoreService is a fake frabit.RestoreService.
+type RestoreService struct {
+	Mock
+
+	CreateRestoreFunc func(ctx context.Context, req frabit.CreateRestoreRequest) (*frabit.Restore, error)
+	GetRestoreFunc    func(ctx context.Context, restoreID string) (*frabit.Restore, error)
+	ListRestoresFunc  func(ctx context.Context, clusterID string) ([]frabit.Restore, error)
+}
+
+var _ frabit.RestoreService = (*RestoreService)(nil)
+
+func (f *RestoreService) CreateRestore(ctx context.Context, req frabit.CreateRestoreRequest) (*frabit.Restore, error) {
+	ret, ok := f.Called("CreateRestore", req)
+	if !ok && f.CreateRestoreFunc != nil {
+		return f.CreateRestoreFunc(ctx, req)
+	}
+	return returned[*frabit.Restore](ret, 0), returned[error](ret, 1)
+}
+
+func (f *RestoreService) GetRestore(ctx context.Context, restoreID string) (*frabit.Restore, error) {
+	ret, ok := f.Called("GetRestore", restoreID)
+	if !ok && f.GetRestoreFunc != nil {
+		return f.GetRestoreFunc(ctx, restoreID)
+	}
+	return returned[*frabit.Restore](ret, 0), returned[error](ret, 1)
+}
+
+func (f *RestoreService) ListRestores(ctx context.Context, clusterID string) ([]frabit.Restore, error) {
+	ret, ok := f.Called("ListRestores", clusterID)
+	if !ok && f.ListRestoresFunc != nil {
+		return f.ListRestoresFunc(ctx, clusterID)
+	}
+	return returned[[]frabit.Restore](ret, 0), returned[error](ret, 1)
+}
+
+// TeamService is a fake frabit.TeamService.
+type TeamService struct {
+	Mock
+
+	CreateTeamFunc func(ctx context.Context, req frabit.CreateTeamRequest) (*frabit.Team, error)
+	GetTeamFunc    func(ctx context.Context) (*frabit.Team, error)
+}
+
+var _ frabit.TeamService = (*TeamService)(nil)
+
+func (f *TeamService) CreateTeam(ctx context.Context, req frabit.CreateTeamRequest) (*frabit.Team, error) {
+	ret, ok := f.Called("CreateTeam", req)
+	if !ok && f.CreateTeamFunc != nil {
+		return f.CreateTeamFunc(ctx, req)
+	}
+	return returned[*frabit.Team](ret, 0), returned[error](ret, 1)
+}
+
+func (f *TeamService) GetTeam(ctx context.Context) (*frabit.Team, error) {
+	ret, ok := f.Called("GetTeam")
+	if !ok && f.GetTeamFunc != nil {
+		return f.GetTeamFunc(ctx)
+	}
+	return returned[*frabit.Team](ret, 0), returned[error](ret, 1)
+}
+
+// UserService is a fake frabit.UserService.
+type UserService struct {
+	Mock
+
+	CreateAPIKeyFunc   func(ctx context.Context, req frabit.CreateAPIKeyRequest) (*frabit.APIKeySecret, error)
+	CreateUserFunc     func(ctx context.Context, req frabit.CreateUserRequest) (*frabit.User, error)
+	GetCurrentUserFunc func(ctx context.Context) (*frabit.CurrentUser, error)
+	GetUserFunc        func(ctx context.Context) (*frabit.User, error)
+	ListAPIKeysFunc    func(ctx context.Context) ([]frabit.APIKey, error)
+	RevokeAPIKeyFunc   func(ctx context.Context, keyID string) error
+	RotateAPIKeyFunc   func(ctx context.Context, keyID string, gracePeriod time.Duration) (*frabit.APIKeySecret, error)
+}
+
+var _ frabit.UserService = (*UserService)(nil)
+
+func (f *UserService) CreateAPIKey(ctx context.Context, req frabit.CreateAPIKeyRequest) (*frabit.APIKeySecret, error) {
+	ret, ok := f.Called("CreateAPIKey", req)
+	if !ok && f.CreateAPIKeyFunc != nil {
+		return f.CreateAPIKeyFunc(ctx, req)
+	}
+	return returned[*frabit.APIKeySecret](ret, 0), returned[error](ret, 1)
+}
+
+func (f *UserService) CreateUser(ctx context.Context, req frabit.CreateUserRequest) (*frabit.User, error) {
+	ret, ok := f.Called("CreateUser", req)
+	if !ok && f.CreateUserFunc != nil {
+		return f.CreateUserFunc(ctx, req)
+	}
+	return returned[*frabit.User](ret, 0), returned[error](ret, 1)
+}
+
+func (f *UserService) GetCurrentUser(ctx context.Context) (*frabit.CurrentUser, error) {
+	ret, ok := f.Called("GetCurrentUser")
+	if !ok && f.GetCurrentUserFunc != nil {
+		return f.GetCurrentUserFunc(ctx)
+	}
+	return returned[*frabit.CurrentUser](ret, 0), returned[error](ret, 1)
+}
+
+func (f *UserService) GetUser(ctx context.Context) (*frabit.User, error) {
+	ret, ok := f.Called("GetUser")
+	if !ok && f.GetUserFunc != nil {
+		return f.GetUserFunc(ctx)
+	}
+	return returned[*frabit.User](ret, 0), returned[error](ret, 1)
+}
+
+func (f *UserService) ListAPIKeys(ctx context.Context) ([]frabit.APIKey, error) {
+	ret, ok := f.Called("ListAPIKeys")
+	if !ok && f.ListAPIKeysFunc != nil {
+		return f.ListAPIKeysFunc(ctx)
+	}
+	return returned[[]frabit.APIKey](ret, 0), returned[error](ret, 1)
+}
+
+func (f *UserService) RevokeAPIKey(ctx context.Context, keyID string) error {
+	ret, ok := f.Called("RevokeAPIKey", keyID)
+	if !ok && f.RevokeAPIKeyFunc != nil {
+		return f.RevokeAPIKeyFunc(ctx, keyID)
+	}
+	return returned[error](ret, 0)
+}
+
+func (f *UserService) RotateAPIKey(ctx context.Context, keyID string, gracePeriod time.Duration) (*frabit.APIKeySecret, error) {
+	ret, ok := f.Called("RotateAPIKey", keyID, gracePeriod)
+	if !ok && f.RotateAPIKeyFunc != nil {
+		return f.RotateAPIKeyFunc(ctx, keyID, gracePeriod)
+	}
+	return returned[*frabit.APIKeySecret](ret, 0), returned[error](ret, 1)
+}
@@ -0,0 +1,234 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package frabitotel instruments a Frabit client with OpenTelemetry: a client
+// span for every SDK call, W3C trace context propagated to the Frabit API,
+// and request metrics.
+//
+//	client, err := frabit.NewClient(frabit.WithBaseURL(addr), frabitotel.WithInstrumentation())
+package frabitotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// ScopeName is the instrumentation scope of the tracer and meter.
+const ScopeName = "github.com/frabits/frabit-go-sdk/frabitotel"
+
+// Attribute keys of the spans and metrics, besides the HTTP semantic
+// convention ones.
+const (
+	ServiceKey   = attribute.Key("frabit.service")
+	OperationKey = attribute.Key("frabit.operation")
+	ErrorCodeKey = attribute.Key("frabit.error.code")
+)
+
+// errorCodeTransport is the error code of requests that got no response.
+const errorCodeTransport = "transport"
+
+type Option func(c *config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// WithTracerProvider replaces the global tracer provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider replaces the global meter provider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator replaces the W3C trace context propagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// WithInstrumentation is a client option instrumenting the client's calls
+// and requests. A span covers the whole of a call, including the resend
+// after a 401; the requests it took are recorded on it.
+func WithInstrumentation(opts ...Option) frabit.ClientOption {
+	return func(c *frabit.Client) error {
+		i, err := newInstruments(opts...)
+		if err != nil {
+			return err
+		}
+		if err := frabit.WithCallMiddleware(i.callMiddleware)(c); err != nil {
+			return err
+		}
+		return frabit.WithMiddleware(i.requestMiddleware)(c)
+	}
+}
+
+type instruments struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+	retries  metric.Int64Counter
+	errors   metric.Int64Counter
+}
+
+func newInstruments(opts ...Option) (*instruments, error) {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	meter := c.meterProvider.Meter(ScopeName, metric.WithInstrumentationVersion(frabit.Version))
+	i := &instruments{
+		tracer:     c.tracerProvider.Tracer(ScopeName, trace.WithInstrumentationVersion(frabit.Version)),
+		propagator: c.propagator,
+	}
+	var err, e error
+	i.requests, e = meter.Int64Counter("frabit.client.requests",
+		metric.WithDescription("Requests sent to the Frabit API"), metric.WithUnit("{request}"))
+	err = errors.Join(err, e)
+	i.duration, e = meter.Float64Histogram("frabit.client.request.duration",
+		metric.WithDescription("Duration of requests to the Frabit API"), metric.WithUnit("s"))
+	err = errors.Join(err, e)
+	i.retries, e = meter.Int64Counter("frabit.client.retries",
+		metric.WithDescription("Requests resent after the token was rejected"), metric.WithUnit("{request}"))
+	err = errors.Join(err, e)
+	i.errors, e = meter.Int64Counter("frabit.client.errors",
+		metric.WithDescription("Failed calls by Frabit error code"), metric.WithUnit("{call}"))
+	err = errors.Join(err, e)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func callAttributes(call frabit.Call) []attribute.KeyValue {
+	return []attribute.KeyValue{ServiceKey.String(call.Service), OperationKey.String(call.Operation)}
+}
+
+// callMiddleware opens the span of a call and counts the call as failed
+// when its outcome, not one of its requests, is an error.
+func (i *instruments) callMiddleware(next frabit.CallFunc) frabit.CallFunc {
+	return func(ctx context.Context) error {
+		call, _ := frabit.CallFromContext(ctx)
+		name := "frabit"
+		if call.Service != "" {
+			name += "." + call.Service
+		}
+		if call.Operation != "" {
+			name += "." + call.Operation
+		}
+		attrs := callAttributes(call)
+		ctx, span := i.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		err := next(ctx)
+		if err == nil {
+			return nil
+		}
+		code := errorCodeTransport
+		var apiErr *frabit.Error
+		if errors.As(err, &apiErr) {
+			code = string(apiErr.Code)
+		} else {
+			span.RecordError(err)
+		}
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(ErrorCodeKey.String(code), attribute.String("error.type", code))
+		i.errors.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(append(attrs, ErrorCodeKey.String(code))...)))
+		return err
+	}
+}
+
+// requestMiddleware records a request on the span of its call, propagates
+// the span to the Frabit API and measures the request.
+func (i *instruments) requestMiddleware(next frabit.RoundTripFunc) frabit.RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		ctx := req.Context()
+		call, _ := frabit.CallFromContext(ctx)
+		span := trace.SpanFromContext(ctx)
+		method := attribute.String("http.request.method", req.Method)
+		span.SetAttributes(method,
+			attribute.String("url.full", req.URL.Redacted()),
+			attribute.String("server.address", req.URL.Hostname()),
+		)
+		if port := serverPort(req); port != 0 {
+			span.SetAttributes(attribute.Int("server.port", port))
+		}
+		if call.Attempt > 1 {
+			span.SetAttributes(attribute.Int("http.request.resend_count", call.Attempt-1))
+		}
+		i.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		start := time.Now()
+		resp, err := next(req)
+		elapsed := time.Since(start).Seconds()
+
+		callAttrs := append(callAttributes(call), method)
+		metricAttrs := callAttrs
+		if err == nil {
+			// the last request of the call decides the status
+			status := attribute.Int("http.response.status_code", resp.StatusCode)
+			span.SetAttributes(status)
+			metricAttrs = append(metricAttrs, status)
+		}
+		set := metric.WithAttributeSet(attribute.NewSet(metricAttrs...))
+		i.requests.Add(ctx, 1, set)
+		i.duration.Record(ctx, elapsed, set)
+		if call.Attempt > 1 {
+			i.retries.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(callAttrs...)))
+		}
+		return resp, err
+	}
+}
+
+func serverPort(req *http.Request) int {
+	switch port := req.URL.Port(); {
+	case port != "":
+		n, _ := strconv.Atoi(port)
+		return n
+	case req.URL.Scheme == "https":
+		return 443
+	case req.URL.Scheme == "http":
+		return 80
+	}
+	return 0
+}
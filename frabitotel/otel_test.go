@@ -0,0 +1,138 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabitotel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func TestInstrumentation(t *testing.T) {
+	var traceparents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("Traceparent"))
+		switch {
+		case r.URL.Path == "/api/v2/user/me" && r.Header.Get("Authorization") == "Bearer fresh":
+			_, _ = w.Write([]byte(`{"id":"u1"}`))
+		case r.URL.Path == "/api/v2/user/me":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	creds := &rotatingToken{token: "stale"}
+	client, err := frabit.NewClient(frabit.WithBaseURL(srv.URL), frabit.WithCredentials(creds),
+		WithInstrumentation(WithTracerProvider(tp), WithMeterProvider(mp)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	if _, err := client.User.GetCurrentUser(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Backup.GetVerification(ctx, "b1", "v1"); !frabit.IsErrorCode(err, frabit.ErrNotFound) {
+		t.Fatalf("GetVerification: %v", err)
+	}
+	parent.End()
+
+	got := spans.GetSpans()
+	if len(got) != 3 {
+		t.Fatalf("%d spans", len(got))
+	}
+	retried, missing := got[0], got[1]
+	if retried.Name != "frabit.User.GetCurrentUser" || retried.SpanKind != trace.SpanKindClient || retried.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span = %s kind %v parent %v", retried.Name, retried.SpanKind, retried.Parent.SpanID())
+	}
+	if attr(retried.Attributes, "http.request.resend_count") != attribute.IntValue(1) || attr(retried.Attributes, "http.response.status_code") != attribute.IntValue(200) ||
+		retried.Status.Code == codes.Error || attr(retried.Attributes, "error.type").Type() != attribute.INVALID {
+		t.Errorf("retried call: %v %v", retried.Status, retried.Attributes)
+	}
+	if missing.Name != "frabit.Backup.GetVerification" || missing.Status.Code != codes.Error || attr(missing.Attributes, "http.response.status_code") != attribute.IntValue(404) ||
+		attr(missing.Attributes, "error.type") != attribute.StringValue("not_found") {
+		t.Errorf("span = %s %v %v", missing.Name, missing.Status, missing.Attributes)
+	}
+	// the W3C header carries the call span, a child of the caller's span
+	want := func(span tracetest.SpanStub) string {
+		return "00-" + parent.SpanContext().TraceID().String() + "-" + span.SpanContext.SpanID().String() + "-01"
+	}
+	if len(traceparents) != 3 || traceparents[0] != want(retried) || traceparents[1] != want(retried) || traceparents[2] != want(missing) {
+		t.Errorf("traceparent = %q", traceparents)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	sums := map[string]int64{}
+	var histogramCount uint64
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range data.DataPoints {
+				sums[m.Name] += dp.Value
+				if code, ok := dp.Attributes.Value(ErrorCodeKey); ok {
+					sums[m.Name+":"+code.AsString()] += dp.Value
+				}
+			}
+		case metricdata.Histogram[float64]:
+			for _, dp := range data.DataPoints {
+				histogramCount += dp.Count
+			}
+		}
+	}
+	// the rejected first request of a call that succeeded is no error
+	if sums["frabit.client.requests"] != 3 || sums["frabit.client.retries"] != 1 || sums["frabit.client.errors"] != 1 ||
+		sums["frabit.client.errors:not_found"] != 1 || histogramCount != 3 {
+		t.Errorf("metrics = %v, %d durations", sums, histogramCount)
+	}
+}
+
+func attr(attrs []attribute.KeyValue, key attribute.Key) attribute.Value {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// rotatingToken hands out a stale token until the client invalidates it.
+type rotatingToken struct {
+	token string
+}
+
+func (r *rotatingToken) Token(context.Context) (string, error) { return r.token, nil }
+func (r *rotatingToken) Invalidate(string)                     { r.token = "fresh" }
@@ -0,0 +1,367 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabittest
+
+import (
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// certificateLifetime is the validity of certificates issued on enrollment.
+const certificateLifetime = 24 * time.Hour
+
+func (s *Server) agentRoutes() {
+	s.mux.HandleFunc("GET /api/v2/agents", s.listAgents)
+	s.mux.HandleFunc("POST /api/v2/agents", s.registerAgent)
+	s.mux.HandleFunc("POST /api/v2/agents/enroll", s.enrollAgent)
+	s.mux.HandleFunc("POST /api/v2/agents/heartbeat", s.heartbeat)
+	s.mux.HandleFunc("DELETE /api/v2/agents/{agent}", s.deregisterAgent)
+	s.mux.HandleFunc("PUT /api/v2/agents/{agent}/inventory", s.reportInventory)
+	s.mux.HandleFunc("POST /api/v2/agents/{agent}/metrics", s.pushMetrics)
+	s.mux.HandleFunc("GET /api/v2/agents/{agent}/tasks", s.pollTasks)
+	s.mux.HandleFunc("POST /api/v2/agents/{agent}/tasks/{task}/claim", s.claimTask)
+	s.mux.HandleFunc("POST /api/v2/agents/{agent}/tasks/{task}/lease", s.extendLease)
+	s.mux.HandleFunc("POST /api/v2/agents/{agent}/tasks/{task}/progress", s.reportProgress)
+	s.mux.HandleFunc("POST /api/v2/agents/{agent}/tasks/{task}/logs", s.appendLogs)
+	s.mux.HandleFunc("POST /api/v2/agents/{agent}/tasks/{task}/result", s.submitResult)
+}
+
+func (s *Server) findAgent(id string) *frabit.Agent {
+	for _, a := range s.agents {
+		if a.AgentID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Server) listAgents(w http.ResponseWriter, r *http.Request) {
+	agents := make([]frabit.Agent, 0, len(s.agents))
+	for _, a := range s.agents {
+		agents = append(agents, *a)
+	}
+	writeJSON(w, http.StatusOK, agents)
+}
+
+func (s *Server) registerAgent(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateAgentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.AgentID == "" {
+		writeError(w, http.StatusBadRequest, "agent_id is required")
+		return
+	}
+	agent := s.findAgent(req.AgentID)
+	if agent == nil {
+		agent = &frabit.Agent{AgentID: req.AgentID}
+		s.agents = append(s.agents, agent)
+	}
+	agent.Name = req.Name
+	agent.Status = frabit.AgentStatus(req.Status)
+	agent.ClientIP = req.ClientIP
+	agent.LastHeartbeat = time.Now().UTC()
+	if req.Inventory != nil {
+		agent.Inventory = req.Inventory
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) heartbeat(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateHeartbeat
+	if !decode(w, r, &req) {
+		return
+	}
+	agent := s.findAgent(req.AgentID)
+	if agent == nil {
+		notFound(w, "agent", req.AgentID)
+		return
+	}
+	agent.Status = req.Status
+	agent.LastHeartbeat = time.Now().UTC()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deregisterAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("agent")
+	i := slices.IndexFunc(s.agents, func(a *frabit.Agent) bool { return a.AgentID == id })
+	if i < 0 {
+		notFound(w, "agent", id)
+		return
+	}
+	s.agents = slices.Delete(s.agents, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) reportInventory(w http.ResponseWriter, r *http.Request) {
+	var inventory frabit.AgentInventory
+	if !decode(w, r, &inventory) {
+		return
+	}
+	agent := s.findAgent(r.PathValue("agent"))
+	if agent == nil {
+		notFound(w, "agent", r.PathValue("agent"))
+		return
+	}
+	agent.Inventory = &inventory
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) pushMetrics(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "malformed gzip body: "+err.Error())
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+	var batch frabit.MetricBatch
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed request body: "+err.Error())
+		return
+	}
+	s.metrics = append(s.metrics, batch)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// enrollAgent exchanges a join token for a client certificate issued by a
+// CA the server creates on first use.
+func (s *Server) enrollAgent(w http.ResponseWriter, r *http.Request) {
+	var req frabit.EnrollAgentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if !s.joinTokens[req.JoinToken] {
+		writeError(w, http.StatusForbidden, "invalid join token")
+		return
+	}
+	block, _ := pem.Decode([]byte(req.CSR))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		writeError(w, http.StatusBadRequest, "csr is not a PEM encoded certificate request")
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err == nil {
+		err = csr.CheckSignature()
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid csr: "+err.Error())
+		return
+	}
+	if err := s.initCA(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// the CA certificate has serial 1
+	s.seq++
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(s.seq) + 1),
+		Subject:      pkix.Name{CommonName: req.AgentID},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(certificateLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, s.caCert, csr.PublicKey, s.caKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, frabit.EnrollAgentResponse{
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		CABundle:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.caCert.Raw})),
+		ExpiresAt:   tmpl.NotAfter.UTC(),
+	})
+}
+
+func (s *Server) initCA() error {
+	if s.caCert != nil {
+		return nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "frabittest CA"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	s.caCert, s.caKey = cert, key
+	return nil
+}
+
+// pollTasks returns the agent's pending tasks right away; the wait
+// parameter is ignored.
+func (s *Server) pollTasks(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agent")
+	var types []string
+	if v := r.URL.Query().Get("types"); v != "" {
+		types = strings.Split(v, ",")
+	}
+	tasks := []frabit.Task{}
+	for _, t := range s.tasks {
+		if t.agentID != agentID || t.State != frabit.TaskPending {
+			continue
+		}
+		if len(types) > 0 && !slices.Contains(types, string(t.Type)) {
+			continue
+		}
+		tasks = append(tasks, t.Task)
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+// agentTask looks up the task named in the path, answering 404 when it does
+// not exist or is queued for another agent.
+func (s *Server) agentTask(w http.ResponseWriter, r *http.Request) *task {
+	t := s.findTask(r.PathValue("task"))
+	if t == nil || t.agentID != r.PathValue("agent") {
+		notFound(w, "task", r.PathValue("task"))
+		return nil
+	}
+	return t
+}
+
+// leasedTask is agentTask for requests made under a lease, answering 409
+// when the lease is not the task's current one.
+func (s *Server) leasedTask(w http.ResponseWriter, r *http.Request, leaseID string) *task {
+	t := s.agentTask(w, r)
+	if t == nil {
+		return nil
+	}
+	if t.leaseID == "" || t.leaseID != leaseID {
+		writeError(w, http.StatusConflict, "lease "+leaseID+" does not hold task "+t.TaskID)
+		return nil
+	}
+	return t
+}
+
+func (s *Server) claimTask(w http.ResponseWriter, r *http.Request) {
+	var req frabit.ClaimTaskRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	t := s.agentTask(w, r)
+	if t == nil {
+		return
+	}
+	if t.State != frabit.TaskPending {
+		writeError(w, http.StatusConflict, "task "+t.TaskID+" is already "+string(t.State))
+		return
+	}
+	t.State = frabit.TaskClaimed
+	t.leaseID = s.nextID("lease")
+	writeJSON(w, http.StatusOK, frabit.TaskLease{TaskID: t.TaskID, LeaseID: t.leaseID, ExpiresAt: leaseExpiry(req.LeaseSeconds)})
+}
+
+func (s *Server) extendLease(w http.ResponseWriter, r *http.Request) {
+	var req frabit.ExtendLeaseRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	t := s.leasedTask(w, r, req.LeaseID)
+	if t == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, frabit.TaskLease{TaskID: t.TaskID, LeaseID: t.leaseID, ExpiresAt: leaseExpiry(req.LeaseSeconds)})
+}
+
+func (s *Server) reportProgress(w http.ResponseWriter, r *http.Request) {
+	var req frabit.TaskProgress
+	if !decode(w, r, &req) {
+		return
+	}
+	t := s.leasedTask(w, r, req.LeaseID)
+	if t == nil {
+		return
+	}
+	t.State = frabit.TaskRunning
+	t.progress = req.Percent
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) appendLogs(w http.ResponseWriter, r *http.Request) {
+	var req frabit.TaskLogs
+	if !decode(w, r, &req) {
+		return
+	}
+	t := s.leasedTask(w, r, req.LeaseID)
+	if t == nil {
+		return
+	}
+	t.logs = append(t.logs, req.Lines...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) submitResult(w http.ResponseWriter, r *http.Request) {
+	var req frabit.TaskResult
+	if !decode(w, r, &req) {
+		return
+	}
+	t := s.leasedTask(w, r, req.LeaseID)
+	if t == nil {
+		return
+	}
+	if req.State != frabit.TaskSucceeded && req.State != frabit.TaskFailed {
+		writeError(w, http.StatusBadRequest, "result state must be succeeded or failed")
+		return
+	}
+	req.AgentID, req.TaskID = t.agentID, t.TaskID
+	t.State = req.State
+	t.result = &req
+	t.leaseID = ""
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func leaseExpiry(seconds int) time.Time {
+	if seconds <= 0 {
+		seconds = 60
+	}
+	return time.Now().UTC().Add(time.Duration(seconds) * time.Second)
+}
@@ -0,0 +1,324 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabittest
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func (s *Server) backupRoutes() {
+	s.mux.HandleFunc("GET /backup", s.getBackup)
+	s.mux.HandleFunc("POST /backup", s.createBackup)
+	s.mux.HandleFunc("GET /api/v2/backups/{backup}/verifications", s.listVerifications)
+	s.mux.HandleFunc("POST /api/v2/backups/{backup}/verifications", s.verifyBackup)
+	s.mux.HandleFunc("GET /api/v2/backups/{backup}/verifications/{id}", s.getVerification)
+	s.mux.HandleFunc("GET /api/v2/backups/{backup}/copies", s.listCopies)
+	s.mux.HandleFunc("POST /api/v2/backups/{backup}/copies", s.copyBackup)
+	s.mux.HandleFunc("PATCH /api/v2/backups/{backup}/copies/{id}", s.updateCopy)
+	s.mux.HandleFunc("DELETE /api/v2/backups/{backup}/copies/{id}", s.deleteCopy)
+	s.mux.HandleFunc("GET /api/v2/operations/{id}", s.getOperation)
+	s.mux.HandleFunc("GET /api/v2/backup-policies/{policy}/verification-schedules", s.listSchedules)
+	s.mux.HandleFunc("POST /api/v2/backup-policies/{policy}/verification-schedules", s.createSchedule)
+	s.mux.HandleFunc("DELETE /api/v2/backup-policies/{policy}/verification-schedules/{id}", s.deleteSchedule)
+	s.mux.HandleFunc("GET /api/v2/backup-policies/{policy}/copy-rules", s.listCopyRules)
+	s.mux.HandleFunc("POST /api/v2/backup-policies/{policy}/copy-rules", s.createCopyRule)
+	s.mux.HandleFunc("DELETE /api/v2/backup-policies/{policy}/copy-rules/{id}", s.deleteCopyRule)
+}
+
+func (s *Server) getBackup(w http.ResponseWriter, r *http.Request) {
+	if len(s.backups) == 0 {
+		notFound(w, "backup", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.backups[len(s.backups)-1])
+}
+
+func (s *Server) createBackup(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateBackupRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	backup := frabit.Backup{
+		ID:          s.nextID("backup"),
+		Workspace:   req.Workspace,
+		Name:        req.Name,
+		Owner:       req.Owner,
+		Compression: req.Compression,
+		KeyID:       req.KeyID,
+		Checksum:    req.Checksum,
+	}
+	s.backups = append(s.backups, backup)
+	writeJSON(w, http.StatusCreated, backup)
+}
+
+func (s *Server) findBackup(w http.ResponseWriter, r *http.Request) (frabit.Backup, bool) {
+	id := r.PathValue("backup")
+	for _, b := range s.backups {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	notFound(w, "backup", id)
+	return frabit.Backup{}, false
+}
+
+// verifyBackup finishes every verification right away; a backup passes its
+// checksum verification when it was created with a checksum.
+func (s *Server) verifyBackup(w http.ResponseWriter, r *http.Request) {
+	backup, ok := s.findBackup(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Mode frabit.VerifyMode `json:"mode"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	now := time.Now().UTC()
+	report := frabit.VerificationReport{
+		ID:         s.nextID("verification"),
+		BackupID:   backup.ID,
+		Mode:       req.Mode,
+		Status:     frabit.VerificationPassed,
+		StartedAt:  now,
+		FinishedAt: now,
+	}
+	switch req.Mode {
+	case frabit.VerifyChecksum:
+		report.Checksum = &frabit.ChecksumResult{Expected: backup.Checksum, Actual: backup.Checksum}
+		if backup.Checksum == "" {
+			report.Status = frabit.VerificationFailed
+			report.Error = "backup has no checksum"
+		}
+	case frabit.VerifyStructural:
+		report.Structure = &frabit.StructuralResult{Method: "archive", Decrypted: backup.KeyID != "", Decompressed: backup.Compression != ""}
+	case frabit.VerifyTestRestore:
+		report.TestRestore = &frabit.TestRestoreResult{ScratchInstance: s.nextID("scratch")}
+	default:
+		writeError(w, http.StatusBadRequest, "unknown verification mode "+string(req.Mode))
+		return
+	}
+	s.verifications[backup.ID] = append(s.verifications[backup.ID], report)
+	writeJSON(w, http.StatusCreated, report)
+}
+
+func (s *Server) listVerifications(w http.ResponseWriter, r *http.Request) {
+	backup, ok := s.findBackup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(s.verifications[backup.ID]))
+}
+
+func (s *Server) getVerification(w http.ResponseWriter, r *http.Request) {
+	backup, ok := s.findBackup(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	for _, report := range s.verifications[backup.ID] {
+		if report.ID == id {
+			writeJSON(w, http.StatusOK, report)
+			return
+		}
+	}
+	notFound(w, "verification", id)
+}
+
+// copyBackup completes copies immediately; the returned operation has
+// already succeeded.
+func (s *Server) copyBackup(w http.ResponseWriter, r *http.Request) {
+	backup, ok := s.findBackup(w, r)
+	if !ok {
+		return
+	}
+	var req frabit.CopyBackupRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.DestinationStorage == "" {
+		writeError(w, http.StatusBadRequest, "destination_storage is required")
+		return
+	}
+	now := time.Now().UTC()
+	c := frabit.BackupCopy{
+		ID:            s.nextID("copy"),
+		BackupID:      backup.ID,
+		Storage:       req.DestinationStorage,
+		Key:           backup.Name,
+		Status:        frabit.OperationSucceeded,
+		Checksum:      backup.Checksum,
+		RetentionDays: req.RetentionDays,
+		CreatedAt:     now,
+	}
+	c.ExpiresAt = expiry(now, c.RetentionDays)
+	s.copies[backup.ID] = append(s.copies[backup.ID], c)
+	op := frabit.Operation{
+		ID:         s.nextID("op"),
+		Type:       "backup_copy",
+		Status:     frabit.OperationSucceeded,
+		Progress:   1,
+		ResourceID: c.ID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	s.operations[op.ID] = op
+	writeJSON(w, http.StatusAccepted, op)
+}
+
+func (s *Server) listCopies(w http.ResponseWriter, r *http.Request) {
+	backup, ok := s.findBackup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, nonNil(s.copies[backup.ID]))
+}
+
+func (s *Server) updateCopy(w http.ResponseWriter, r *http.Request) {
+	backup, ok := s.findBackup(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		RetentionDays int `json:"retention_days"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	copies := s.copies[backup.ID]
+	i := slices.IndexFunc(copies, func(c frabit.BackupCopy) bool { return c.ID == r.PathValue("id") })
+	if i < 0 {
+		notFound(w, "copy", r.PathValue("id"))
+		return
+	}
+	copies[i].RetentionDays = req.RetentionDays
+	copies[i].ExpiresAt = expiry(copies[i].CreatedAt, req.RetentionDays)
+	writeJSON(w, http.StatusOK, copies[i])
+}
+
+func (s *Server) deleteCopy(w http.ResponseWriter, r *http.Request) {
+	backup, ok := s.findBackup(w, r)
+	if !ok {
+		return
+	}
+	copies := s.copies[backup.ID]
+	i := slices.IndexFunc(copies, func(c frabit.BackupCopy) bool { return c.ID == r.PathValue("id") })
+	if i < 0 {
+		notFound(w, "copy", r.PathValue("id"))
+		return
+	}
+	s.copies[backup.ID] = slices.Delete(copies, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getOperation(w http.ResponseWriter, r *http.Request) {
+	op, ok := s.operations[r.PathValue("id")]
+	if !ok {
+		notFound(w, "operation", r.PathValue("id"))
+		return
+	}
+	writeJSON(w, http.StatusOK, op)
+}
+
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, nonNil(s.schedules[r.PathValue("policy")]))
+}
+
+func (s *Server) createSchedule(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateVerificationScheduleRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	policy := r.PathValue("policy")
+	schedule := frabit.VerificationSchedule{
+		ID:        s.nextID("schedule"),
+		PolicyID:  policy,
+		Mode:      req.Mode,
+		Cron:      req.Cron,
+		Sample:    req.Sample,
+		Enabled:   req.Enabled,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	s.schedules[policy] = append(s.schedules[policy], schedule)
+	writeJSON(w, http.StatusCreated, schedule)
+}
+
+func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	policy := r.PathValue("policy")
+	schedules := s.schedules[policy]
+	i := slices.IndexFunc(schedules, func(v frabit.VerificationSchedule) bool { return v.ID == r.PathValue("id") })
+	if i < 0 {
+		notFound(w, "verification schedule", r.PathValue("id"))
+		return
+	}
+	s.schedules[policy] = slices.Delete(schedules, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listCopyRules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, nonNil(s.copyRules[r.PathValue("policy")]))
+}
+
+func (s *Server) createCopyRule(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateCopyRuleRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	policy := r.PathValue("policy")
+	rule := frabit.BackupCopyRule{
+		ID:                 s.nextID("rule"),
+		PolicyID:           policy,
+		DestinationStorage: req.DestinationStorage,
+		BackupTypes:        req.BackupTypes,
+		RetentionDays:      req.RetentionDays,
+		Enabled:            req.Enabled,
+		CreatedAt:          time.Now().UTC().Format(time.RFC3339),
+	}
+	s.copyRules[policy] = append(s.copyRules[policy], rule)
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) deleteCopyRule(w http.ResponseWriter, r *http.Request) {
+	policy := r.PathValue("policy")
+	rules := s.copyRules[policy]
+	i := slices.IndexFunc(rules, func(v frabit.BackupCopyRule) bool { return v.ID == r.PathValue("id") })
+	if i < 0 {
+		notFound(w, "copy rule", r.PathValue("id"))
+		return
+	}
+	s.copyRules[policy] = slices.Delete(rules, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func expiry(created time.Time, retentionDays int) time.Time {
+	if retentionDays == 0 {
+		return time.Time{}
+	}
+	return created.AddDate(0, 0, retentionDays)
+}
+
+// nonNil makes empty lists encode as [] rather than null.
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
@@ -0,0 +1,108 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabittest
+
+import (
+	"net/http"
+	"path"
+	"time"
+)
+
+// Fault replaces the responses to matching requests.
+type Fault struct {
+	// Method matches any method when empty.
+	Method string
+	// Path is a path.Match pattern, such as "/api/v2/backups/*/copies";
+	// empty matches any path.
+	Path string
+
+	// Status and Body are the response sent instead of the real one.
+	Status int
+	Body   string
+	// Latency delays the response, on top of the server's latency. A fault
+	// with only a latency slows the real response down.
+	Latency time.Duration
+	// Drop closes the connection without responding.
+	Drop bool
+
+	// Times is how many requests the fault applies to; 0 means all.
+	Times int
+}
+
+// InjectFault adds f. Faults are matched in the order they were added.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// FailNext answers the next n requests matching method and pattern with status.
+func (s *Server) FailNext(method, pattern string, status, n int) {
+	s.InjectFault(Fault{Method: method, Path: pattern, Status: status, Times: n})
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// matchFault returns the first fault matching r and uses it up.
+func (s *Server) matchFault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if f.Path != "" {
+			if ok, _ := path.Match(f.Path, r.URL.Path); !ok {
+				continue
+			}
+		}
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// serve answers the request if the fault replaces the response.
+func (f *Fault) serve(w http.ResponseWriter) bool {
+	switch {
+	case f.Drop:
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		writeError(w, http.StatusBadGateway, "connection dropped")
+		return true
+	case f.Status != 0:
+		body := f.Body
+		if body == "" {
+			body = `{"message":"injected fault"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.Status)
+		_, _ = w.Write([]byte(body))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabittest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /database", s.getDatabase)
+	s.mux.HandleFunc("POST /database", s.createDatabase)
+	s.mux.HandleFunc("GET /cluster", s.getCluster)
+	s.mux.HandleFunc("POST /cluster", s.createCluster)
+	s.mux.HandleFunc("GET /team", s.getTeam)
+	s.mux.HandleFunc("POST /team", s.createTeam)
+	s.mux.HandleFunc("POST /org", s.createOrg)
+	s.mux.HandleFunc("PUT /org", s.updateOrg)
+	s.mux.HandleFunc("GET /user", s.getUser)
+	s.mux.HandleFunc("POST /user", s.createUser)
+	s.mux.HandleFunc("GET /api/v2/user/me", s.getCurrentUser)
+	s.mux.HandleFunc("GET /api/v2/user/api-keys", s.listAPIKeys)
+	s.mux.HandleFunc("POST /api/v2/user/api-keys", s.createAPIKey)
+	s.mux.HandleFunc("DELETE /api/v2/user/api-keys/{id}", s.revokeAPIKey)
+	s.mux.HandleFunc("POST /api/v2/user/api-keys/{id}/rotate", s.rotateAPIKey)
+	s.backupRoutes()
+	s.agentRoutes()
+}
+
+// authorized checks the bearer token when the server was given one.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	if token == s.token {
+		return true
+	}
+	now := time.Now()
+	for _, k := range s.apiKeys {
+		if !k.Active(now) {
+			continue
+		}
+		if token == k.secret || (token == k.previous && now.Before(k.previousUntil)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The singular endpoints return the most recently created object.
+
+func (s *Server) getDatabase(w http.ResponseWriter, r *http.Request) {
+	if len(s.databases) == 0 {
+		notFound(w, "database", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.databases[len(s.databases)-1])
+}
+
+func (s *Server) createDatabase(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateDatabaseRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	db := frabit.Database{Workspace: req.Workspace, Name: req.Name, Admin: req.Owner}
+	s.databases = append(s.databases, db)
+	writeJSON(w, http.StatusCreated, db)
+}
+
+func (s *Server) getCluster(w http.ResponseWriter, r *http.Request) {
+	if len(s.clusters) == 0 {
+		notFound(w, "cluster", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.clusters[len(s.clusters)-1])
+}
+
+func (s *Server) createCluster(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateClusterRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	cluster := frabit.Cluster{Workspace: req.Workspace, Name: req.Name, Owner: req.Owner}
+	s.clusters = append(s.clusters, cluster)
+	writeJSON(w, http.StatusCreated, cluster)
+}
+
+func (s *Server) getTeam(w http.ResponseWriter, r *http.Request) {
+	if len(s.teams) == 0 {
+		notFound(w, "team", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.teams[len(s.teams)-1])
+}
+
+func (s *Server) createTeam(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateTeamRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	team := frabit.Team{Id: uint32(len(s.teams) + 1), Name: req.Name, Description: req.Description, Owner: req.Owner, CreatedAt: now, UpdatedAt: now}
+	s.teams = append(s.teams, team)
+	writeJSON(w, http.StatusCreated, team)
+}
+
+func (s *Server) createOrg(w http.ResponseWriter, r *http.Request) {
+	var req frabit.OrgCreateRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	for _, org := range s.orgs {
+		if org.Name == req.Name {
+			writeError(w, http.StatusConflict, "org "+req.Name+" exists")
+			return
+		}
+	}
+	s.orgs = append(s.orgs, Org(req))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) updateOrg(w http.ResponseWriter, r *http.Request) {
+	var req frabit.OrgUpdateRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	for i, org := range s.orgs {
+		if org.Name == req.Name {
+			s.orgs[i] = Org(req)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	notFound(w, "org", req.Name)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	if len(s.users) == 0 {
+		notFound(w, "user", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.users[len(s.users)-1])
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateUserRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Login == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "login and password are required")
+		return
+	}
+	user := frabit.User{Name: req.Name, Owner: req.Login}
+	s.users = append(s.users, user)
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (s *Server) getCurrentUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.currentUser)
+}
+
+func (s *Server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys := make([]frabit.APIKey, 0, len(s.apiKeys))
+	for _, k := range s.apiKeys {
+		keys = append(keys, k.APIKey)
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req frabit.CreateAPIKeyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	key := &apiKey{APIKey: frabit.APIKey{ID: s.nextID("key"), Name: req.Name, Scopes: req.Scopes, CreatedAt: time.Now()}}
+	if req.ExpiresAt != nil {
+		key.ExpiresAt = *req.ExpiresAt
+	}
+	key.setSecret()
+	s.apiKeys = append(s.apiKeys, key)
+	writeJSON(w, http.StatusCreated, frabit.APIKeySecret{APIKey: key.APIKey, Secret: key.secret})
+}
+
+func (s *Server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := s.findAPIKey(r.PathValue("id"))
+	if key == nil {
+		notFound(w, "api key", r.PathValue("id"))
+		return
+	}
+	if key.RevokedAt.IsZero() {
+		key.RevokedAt = time.Now()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) rotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := s.findAPIKey(r.PathValue("id"))
+	if key == nil || !key.RevokedAt.IsZero() {
+		notFound(w, "api key", r.PathValue("id"))
+		return
+	}
+	var req struct {
+		GracePeriodSeconds int64 `json:"grace_period_seconds"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	key.previous = key.secret
+	key.previousUntil = time.Now().Add(time.Duration(req.GracePeriodSeconds) * time.Second)
+	key.setSecret()
+	writeJSON(w, http.StatusOK, frabit.APIKeySecret{APIKey: key.APIKey, Secret: key.secret})
+}
+
+func (s *Server) findAPIKey(id string) *apiKey {
+	for _, k := range s.apiKeys {
+		if k.ID == id {
+			return k
+		}
+	}
+	return nil
+}
+
+func (k *apiKey) setSecret() {
+	b := make([]byte, 20)
+	_, _ = rand.Read(b)
+	k.secret = "frb_" + hex.EncodeToString(b)
+	k.Prefix = k.secret[:8]
+}
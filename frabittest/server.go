@@ -0,0 +1,210 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package frabittest runs a fake Frabit API for tests. It keeps its state in
+// memory, can add latency and inject faults, and records every request, so
+// code using the SDK can be tested end to end without a Frabit server.
+//
+//	srv := frabittest.NewServer(t)
+//	client := srv.Client()
+//	db, err := client.Database.CreateDatabase(ctx, frabit.CreateDatabaseRequest{Name: "orders"})
+package frabittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// Server is a fake Frabit API listening on a local address.
+type Server struct {
+	*httptest.Server
+
+	t   testing.TB
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	token    string
+	latency  time.Duration
+	faults   []*Fault
+	requests []Request
+	state
+}
+
+type Option func(s *Server)
+
+// WithToken makes the server reject requests that do not carry token, or
+// the secret of an active API key, as a bearer token.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithLatency delays every response by d.
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// WithJoinToken accepts token for agent enrollment.
+func WithJoinToken(token string) Option {
+	return func(s *Server) {
+		s.joinTokens[token] = true
+	}
+}
+
+// NewServer starts a fake Frabit API, which is closed when the test ends.
+func NewServer(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	s := &Server{t: t, mux: http.NewServeMux(), state: newState()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.routes()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Client returns a client for the server, authenticated with the server's
+// token, configured further by opts.
+func (s *Server) Client(opts ...frabit.ClientOption) *frabit.Client {
+	s.t.Helper()
+	base := []frabit.ClientOption{frabit.WithBaseURL(s.URL)}
+	if s.token != "" {
+		base = append(base, frabit.WithToken(s.token))
+	}
+	client, err := frabit.NewClient(append(base, opts...)...)
+	if err != nil {
+		s.t.Fatalf("frabittest: creating client: %v", err)
+	}
+	return client
+}
+
+// SetLatency changes the delay of every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Request is a request the server received.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+	Time   time.Time
+}
+
+// JSON decodes the request body into v.
+func (r Request) JSON(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ResetRequests forgets the recorded requests.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+		Time:   time.Now(),
+	})
+	latency := s.latency
+	fault := s.matchFault(r)
+	s.mu.Unlock()
+
+	if fault != nil {
+		latency += fault.Latency
+	}
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault != nil && fault.serve(w) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"message": msg})
+}
+
+func notFound(w http.ResponseWriter, kind, id string) {
+	writeError(w, http.StatusNotFound, fmt.Sprintf("%s %q not found", kind, id))
+}
+
+// decode reads the JSON request body into v, answering 400 if it is malformed.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed request body: "+err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabittest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+func TestResources(t *testing.T) {
+	srv := NewServer(t)
+	client := srv.Client()
+	ctx := context.Background()
+
+	if _, err := client.Database.GetDatabase(ctx); !frabit.IsErrorCode(err, frabit.ErrNotFound) {
+		t.Errorf("GetDatabase before create: %v", err)
+	}
+	if _, err := client.Database.CreateDatabase(ctx, frabit.CreateDatabaseRequest{Name: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	if db, err := client.Database.GetDatabase(ctx); err != nil || db.Name != "orders" {
+		t.Errorf("GetDatabase = %+v, %v", db, err)
+	}
+	if got := srv.Databases(); len(got) != 1 {
+		t.Errorf("Databases = %+v", got)
+	}
+
+	if err := client.Org.CreateOrg(ctx, frabit.OrgCreateRequest{Name: "acme"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Org.CreateOrg(ctx, frabit.OrgCreateRequest{Name: "acme"}); !frabit.IsErrorCode(err, frabit.ErrConflict) {
+		t.Errorf("duplicate org: %v", err)
+	}
+
+	backup, err := client.Backup.CreateBackup(ctx, frabit.CreateBackupRequest{Name: "nightly", Checksum: "sha256:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := client.Backup.Verify(ctx, backup.ID, frabit.VerifyChecksum)
+	if err != nil || !report.Passed() || !report.Checksum.Match() {
+		t.Errorf("Verify = %+v, %v", report, err)
+	}
+	handle, err := client.Backup.CopyWithRetention(ctx, backup.ID, frabit.CopyBackupRequest{DestinationStorage: "s3", RetentionDays: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handle.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if copies := srv.Copies(backup.ID); len(copies) != 1 || copies[0].ID != handle.ResourceID || copies[0].ExpiresAt.IsZero() {
+		t.Errorf("Copies = %+v", copies)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	srv := NewServer(t, WithToken("root"))
+	ctx := context.Background()
+
+	if _, err := srv.Client(frabit.WithToken("wrong")).User.GetCurrentUser(ctx); !frabit.IsErrorCode(err, frabit.ErrUnauthorized) {
+		t.Errorf("wrong token: %v", err)
+	}
+	key, err := srv.Client().User.CreateAPIKey(ctx, frabit.CreateAPIKeyRequest{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyClient := srv.Client(frabit.WithToken(key.Secret))
+	if _, err := keyClient.User.GetCurrentUser(ctx); err != nil {
+		t.Errorf("api key: %v", err)
+	}
+	rotated, err := keyClient.User.RotateAPIKey(ctx, key.ID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyClient.User.ListAPIKeys(ctx); err != nil {
+		t.Errorf("previous secret within grace period: %v", err)
+	}
+	if err := srv.Client().User.RevokeAPIKey(ctx, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Client(frabit.WithToken(rotated.Secret)).User.ListAPIKeys(ctx); !frabit.IsErrorCode(err, frabit.ErrUnauthorized) {
+		t.Errorf("revoked key: %v", err)
+	}
+}
+
+func TestAgentTasks(t *testing.T) {
+	srv := NewServer(t, WithJoinToken("join"))
+	client := srv.Client()
+	ctx := context.Background()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "agent-1"}}, key)
+	csr := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	if _, err := client.Agent.Enroll(ctx, frabit.EnrollAgentRequest{JoinToken: "bad", AgentID: "agent-1", CSR: csr}); !frabit.IsErrorCode(err, frabit.ErrUnauthorized) {
+		t.Errorf("bad join token: %v", err)
+	}
+	enrolled, err := client.Agent.Enroll(ctx, frabit.EnrollAgentRequest{JoinToken: "join", AgentID: "agent-1", CSR: csr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(enrolled.Certificate))
+	if cert, err := x509.ParseCertificate(block.Bytes); err != nil || cert.Subject.CommonName != "agent-1" {
+		t.Errorf("certificate: %v", err)
+	}
+
+	if err := client.Agent.Register(ctx, frabit.CreateAgentRequest{AgentID: "agent-1", Status: string(frabit.Active)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Agent.PushMetrics(ctx, frabit.MetricBatch{AgentID: "agent-1", Samples: []frabit.MetricSample{{Name: "up", Value: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Metrics(); len(got) != 1 || got[0].Samples[0].Name != "up" {
+		t.Errorf("Metrics = %+v", got)
+	}
+
+	id := srv.EnqueueTask("agent-1", frabit.Task{Type: frabit.TaskHealthProbe})
+	srv.EnqueueTask("agent-1", frabit.Task{Type: frabit.TaskBackup})
+	tasks, err := client.Agent.PollTasks(ctx, frabit.PollTasksRequest{AgentID: "agent-1", Types: []frabit.TaskType{frabit.TaskHealthProbe}})
+	if err != nil || len(tasks) != 1 || tasks[0].TaskID != id {
+		t.Fatalf("PollTasks = %+v, %v", tasks, err)
+	}
+	lease, err := client.Agent.ClaimTask(ctx, frabit.ClaimTaskRequest{AgentID: "agent-1", TaskID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Agent.ClaimTask(ctx, frabit.ClaimTaskRequest{AgentID: "agent-1", TaskID: id}); !frabit.IsErrorCode(err, frabit.ErrConflict) {
+		t.Errorf("double claim: %v", err)
+	}
+	if err := client.Agent.AppendLogs(ctx, frabit.TaskLogs{AgentID: "agent-1", TaskID: id, LeaseID: lease.LeaseID, Lines: []string{"ok"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Agent.SubmitResult(ctx, frabit.TaskResult{AgentID: "agent-1", TaskID: id, LeaseID: lease.LeaseID, State: frabit.TaskSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := srv.TaskStatus(id); status.State != frabit.TaskSucceeded || len(status.Logs) != 1 {
+		t.Errorf("TaskStatus = %+v", status)
+	}
+}
+
+func TestFaultsAndRecording(t *testing.T) {
+	srv := NewServer(t)
+	client := srv.Client()
+	ctx := context.Background()
+
+	srv.FailNext(http.MethodPost, "/cluster", http.StatusServiceUnavailable, 1)
+	if _, err := client.Cluster.CreateCluster(ctx, frabit.CreateClusterRequest{Name: "main"}); !frabit.IsErrorCode(err, frabit.ErrInternal) {
+		t.Errorf("injected fault: %v", err)
+	}
+	if _, err := client.Cluster.CreateCluster(ctx, frabit.CreateClusterRequest{Name: "main"}); err != nil {
+		t.Errorf("after fault: %v", err)
+	}
+
+	srv.InjectFault(Fault{Path: "/api/v2/backups/*/copies", Drop: true})
+	if _, err := client.Backup.ListCopies(ctx, "b1"); err == nil {
+		t.Error("dropped connection: want error")
+	}
+	srv.ClearFaults()
+
+	srv.SetLatency(time.Second)
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Cluster.GetCluster(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("latency: %v", err)
+	}
+	srv.SetLatency(0)
+
+	reqs := srv.Requests()
+	var body frabit.CreateClusterRequest
+	if len(reqs) != 4 || reqs[1].Method != http.MethodPost || reqs[1].JSON(&body) != nil || body.Name != "main" {
+		t.Errorf("Requests = %+v", reqs)
+	}
+	srv.ResetRequests()
+	if got := srv.Requests(); len(got) != 0 {
+		t.Errorf("Requests after reset = %d", len(got))
+	}
+}
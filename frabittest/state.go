@@ -0,0 +1,246 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frabittest
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// Org is an organisation as created through the API.
+type Org struct {
+	Name        string
+	Description string
+	Country     string
+}
+
+// state is the in-memory data of the fake API. Every access holds Server.mu.
+type state struct {
+	seq int
+
+	databases []frabit.Database
+	clusters  []frabit.Cluster
+	teams     []frabit.Team
+	orgs      []Org
+	users     []frabit.User
+
+	currentUser frabit.CurrentUser
+	apiKeys     []*apiKey
+
+	backups       []frabit.Backup
+	verifications map[string][]frabit.VerificationReport
+	schedules     map[string][]frabit.VerificationSchedule
+	copies        map[string][]frabit.BackupCopy
+	copyRules     map[string][]frabit.BackupCopyRule
+	operations    map[string]frabit.Operation
+
+	agents     []*frabit.Agent
+	joinTokens map[string]bool
+	metrics    []frabit.MetricBatch
+	tasks      []*task
+	caCert     *x509.Certificate
+	caKey      crypto.Signer
+}
+
+type apiKey struct {
+	frabit.APIKey
+	secret string
+	// previous keeps working until previousUntil after a rotation
+	previous      string
+	previousUntil time.Time
+}
+
+// task is a task queued for an agent along with what the agent reported.
+type task struct {
+	frabit.Task
+	agentID  string
+	leaseID  string
+	progress float64
+	logs     []string
+	result   *frabit.TaskResult
+}
+
+func newState() state {
+	return state{
+		currentUser:   frabit.CurrentUser{ID: "user-1", Login: "frabittest", Name: "Frabit Test", AuthMethod: "api_key", Scopes: []string{"*"}},
+		verifications: make(map[string][]frabit.VerificationReport),
+		schedules:     make(map[string][]frabit.VerificationSchedule),
+		copies:        make(map[string][]frabit.BackupCopy),
+		copyRules:     make(map[string][]frabit.BackupCopyRule),
+		operations:    make(map[string]frabit.Operation),
+		joinTokens:    make(map[string]bool),
+	}
+}
+
+// nextID returns a new id with the given prefix, unique within the server.
+func (s *state) nextID(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s-%d", prefix, s.seq)
+}
+
+func (s *Server) Databases() []frabit.Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]frabit.Database(nil), s.databases...)
+}
+
+func (s *Server) AddDatabase(db frabit.Database) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.databases = append(s.databases, db)
+}
+
+func (s *Server) Clusters() []frabit.Cluster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]frabit.Cluster(nil), s.clusters...)
+}
+
+func (s *Server) AddCluster(cluster frabit.Cluster) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusters = append(s.clusters, cluster)
+}
+
+func (s *Server) Teams() []frabit.Team {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]frabit.Team(nil), s.teams...)
+}
+
+func (s *Server) Orgs() []Org {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Org(nil), s.orgs...)
+}
+
+func (s *Server) Users() []frabit.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]frabit.User(nil), s.users...)
+}
+
+// SetCurrentUser changes the identity returned by GetCurrentUser.
+func (s *Server) SetCurrentUser(user frabit.CurrentUser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentUser = user
+}
+
+// APIKeys returns the API keys, including revoked ones.
+func (s *Server) APIKeys() []frabit.APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]frabit.APIKey, 0, len(s.apiKeys))
+	for _, k := range s.apiKeys {
+		keys = append(keys, k.APIKey)
+	}
+	return keys
+}
+
+func (s *Server) Backups() []frabit.Backup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]frabit.Backup(nil), s.backups...)
+}
+
+// AddBackup stores backup, assigning it an id if it has none, and returns it.
+func (s *Server) AddBackup(backup frabit.Backup) frabit.Backup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if backup.ID == "" {
+		backup.ID = s.nextID("backup")
+	}
+	s.backups = append(s.backups, backup)
+	return backup
+}
+
+func (s *Server) Copies(backupID string) []frabit.BackupCopy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]frabit.BackupCopy(nil), s.copies[backupID]...)
+}
+
+func (s *Server) Agents() []frabit.Agent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	agents := make([]frabit.Agent, 0, len(s.agents))
+	for _, a := range s.agents {
+		agents = append(agents, *a)
+	}
+	return agents
+}
+
+func (s *Server) AddAgent(agent frabit.Agent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agents = append(s.agents, &agent)
+}
+
+// Metrics returns the metric batches pushed by agents.
+func (s *Server) Metrics() []frabit.MetricBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]frabit.MetricBatch(nil), s.metrics...)
+}
+
+// EnqueueTask queues a task for the agent, assigning it an id if it has
+// none, and returns the id.
+func (s *Server) EnqueueTask(agentID string, t frabit.Task) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.TaskID == "" {
+		t.TaskID = s.nextID("task")
+	}
+	t.State = frabit.TaskPending
+	if t.CreatedAt == "" {
+		t.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	s.tasks = append(s.tasks, &task{Task: t, agentID: agentID})
+	return t.TaskID
+}
+
+// TaskStatus is what an agent reported about a task.
+type TaskStatus struct {
+	State    frabit.TaskState
+	Progress float64
+	Logs     []string
+	Result   *frabit.TaskResult
+}
+
+// TaskStatus returns the state, progress, logs and result of a task.
+func (s *Server) TaskStatus(taskID string) (TaskStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.findTask(taskID)
+	if t == nil {
+		return TaskStatus{}, false
+	}
+	return TaskStatus{State: t.State, Progress: t.progress, Logs: append([]string(nil), t.logs...), Result: t.result}, true
+}
+
+func (s *state) findTask(taskID string) *task {
+	for _, t := range s.tasks {
+		if t.TaskID == taskID {
+			return t
+		}
+	}
+	return nil
+}
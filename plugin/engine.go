@@ -0,0 +1,88 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type BackupMethod string
+
+const (
+	// BackupLogical dumps SQL or an engine specific logical archive.
+	BackupLogical BackupMethod = "logical"
+	// BackupPhysical copies the data files of a running instance.
+	BackupPhysical BackupMethod = "physical"
+)
+
+// EngineCapabilities tells the agent which backups a driver is able to take.
+type EngineCapabilities struct {
+	Engine      string         `json:"engine"`
+	Methods     []BackupMethod `json:"methods"`
+	Incremental bool           `json:"incremental"`
+	PointInTime bool           `json:"point_in_time"`
+}
+
+// BackupTarget is the database instance a backup is taken from or restored to.
+type BackupTarget struct {
+	Host      string   `json:"host"`
+	Port      int      `json:"port"`
+	Socket    string   `json:"socket,omitempty"`
+	User      string   `json:"user"`
+	Password  string   `json:"password,omitempty"`
+	Databases []string `json:"databases,omitempty"`
+	// DataDir is where physical restores unpack to; it must be empty.
+	DataDir string       `json:"data_dir,omitempty"`
+	Method  BackupMethod `json:"method"`
+	// Options are passed to the driver verbatim, e.g. extra tool flags.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// LogPosition is a point in the binary log or WAL of an instance.
+type LogPosition struct {
+	File     string    `json:"file"`
+	Position uint64    `json:"position"`
+	GTIDSet  string    `json:"gtid_set,omitempty"`
+	LSN      string    `json:"lsn,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Time     time.Time `json:"time,omitempty"`
+}
+
+// BackupResult describes a finished backup.
+type BackupResult struct {
+	Method     BackupMethod `json:"method"`
+	Bytes      int64        `json:"bytes"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+}
+
+// EngineDriver takes and restores backups of one database engine. Backups are
+// streamed, so a driver never needs local disk for the artifact.
+type EngineDriver interface {
+	Plugin
+
+	Capabilities() EngineCapabilities
+	// Backup writes a backup of target to sink.
+	Backup(ctx context.Context, target BackupTarget, sink io.Writer) (*BackupResult, error)
+	// Restore reads a backup produced by Backup from source into target.
+	Restore(ctx context.Context, target BackupTarget, source io.Reader) error
+	// Verify checks that source is a complete, readable backup without restoring it.
+	Verify(ctx context.Context, method BackupMethod, source io.Reader) error
+	// ListLogPositions returns the binary log or WAL segments target still has.
+	ListLogPositions(ctx context.Context, target BackupTarget) ([]LogPosition, error)
+}
@@ -0,0 +1,126 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package engine ships the built-in plugin.EngineDriver implementations. They
+// drive the vendor tools (mysqldump, xtrabackup, pg_dump, pg_basebackup, ...)
+// which have to be installed on the agent host.
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// stderrTail is how much of a failing tool's stderr ends up in the error.
+const stderrTail = 4 << 10
+
+func metadata(name string) plugin.Metadata {
+	return plugin.Metadata{Name: name, Version: frabit.Version, Kind: plugin.KindEngine, APIVersion: plugin.APIVersion}
+}
+
+// command runs one external tool, streaming stdin and stdout.
+type command struct {
+	path   string
+	args   []string
+	env    []string
+	dir    string
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (c command) run(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, c.path, c.args...)
+	cmd.Env = append(os.Environ(), c.env...)
+	cmd.Dir = c.dir
+	cmd.Stdin = c.stdin
+	cmd.Stdout = c.stdout
+	stderr := &tailBuffer{max: stderrTail}
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("engine: %s failed: %w: %s", c.path, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (c command) output(ctx context.Context) (string, error) {
+	buf := new(bytes.Buffer)
+	c.stdout = buf
+	err := c.run(ctx)
+	return buf.String(), err
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = b.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string { return string(b.buf) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// backup runs cmd with its stdout counted into sink.
+func backup(ctx context.Context, method plugin.BackupMethod, cmd command, sink io.Writer) (*plugin.BackupResult, error) {
+	out := &countingWriter{w: sink}
+	cmd.stdout = out
+	result := &plugin.BackupResult{Method: method, StartedAt: time.Now().UTC()}
+	if err := cmd.run(ctx); err != nil {
+		return nil, err
+	}
+	result.Bytes = out.n
+	result.FinishedAt = time.Now().UTC()
+	return result, nil
+}
+
+func optionArgs(target plugin.BackupTarget, key string) []string {
+	return strings.Fields(target.Options[key])
+}
+
+func pathOr(path, fallback string) string {
+	if path == "" {
+		return fallback
+	}
+	return path
+}
@@ -0,0 +1,197 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// fakeTool writes a shell script that records its argv and MYSQL_PWD /
+// PGPASSWORD to <name>.args and then runs body.
+func fakeTool(t *testing.T, name, body string) (path, argsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	dir := t.TempDir()
+	path = filepath.Join(dir, name)
+	argsFile = path + ".args"
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\necho \"pwd=$MYSQL_PWD$PGPASSWORD\" >> " + argsFile + "\n" + body + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, argsFile
+}
+
+func readArgs(t *testing.T, file string) string {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMySQLLogicalBackup(t *testing.T) {
+	dump, argsFile := fakeTool(t, "mysqldump", "echo 'CREATE TABLE t (id int);'\necho '-- Dump completed on 2024-01-01'")
+	m := NewMySQL(MySQLConfig{MysqldumpPath: dump})
+
+	var out bytes.Buffer
+	target := plugin.BackupTarget{Host: "db1", Port: 3306, User: "backup", Password: "s3cret", Databases: []string{"shop"}}
+	result, err := m.Backup(context.Background(), target, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Bytes != int64(out.Len()) || result.Method != plugin.BackupLogical {
+		t.Fatalf("result = %+v, wrote %d bytes", result, out.Len())
+	}
+	args := readArgs(t, argsFile)
+	for _, want := range []string{"--host=db1", "--port=3306", "--user=backup", "--single-transaction", "--databases shop", "pwd=s3cret"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q missing %q", args, want)
+		}
+	}
+	if strings.Contains(strings.SplitN(args, "\n", 2)[0], "s3cret") {
+		t.Error("password leaked into argv")
+	}
+
+	if err := m.Verify(context.Background(), plugin.BackupLogical, &out); err != nil {
+		t.Fatalf("verify complete dump: %v", err)
+	}
+	if err := m.Verify(context.Background(), plugin.BackupLogical, strings.NewReader("CREATE TABLE t (id int);\n")); err == nil {
+		t.Fatal("truncated dump verified")
+	}
+}
+
+func TestMySQLSourceDataOption(t *testing.T) {
+	for version, want := range map[string]string{
+		"mysqldump  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)": "source-data",
+		"mysqldump  Ver 8.0.25 for Linux on x86_64 (MySQL Community Server - GPL)": "master-data",
+		"mysqldump  Ver 8.4.0 for Linux on x86_64 (MySQL Community Server - GPL)":  "source-data",
+		"mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)":                  "master-data",
+		"mysqldump  Ver 10.19 Distrib 10.6.12-MariaDB, for debian-linux-gnu":       "master-data",
+		"garbage": "source-data",
+	} {
+		if got := sourceDataOption(version); got != want {
+			t.Errorf("sourceDataOption(%q) = %s, want %s", version, got, want)
+		}
+	}
+
+	dump, argsFile := fakeTool(t, "mysqldump", `if [ "$1" = --version ]; then echo 'mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)'; exit; fi
+echo '-- Dump completed on 2024-01-01'`)
+	if _, err := NewMySQL(MySQLConfig{MysqldumpPath: dump}).Backup(context.Background(), plugin.BackupTarget{}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if args := readArgs(t, argsFile); !strings.Contains(args, "--master-data=2") {
+		t.Errorf("args %q missing --master-data=2", args)
+	}
+
+	// neither a cancelled backup nor a failed --version run decides the flag
+	// for later backups
+	failedOnce := filepath.Join(t.TempDir(), "failed")
+	dump, argsFile = fakeTool(t, "mysqldump", `if [ "$1" = --version ]; then
+  if [ ! -e `+failedOnce+` ]; then touch `+failedOnce+`; exit 1; fi
+  echo 'mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)'; exit
+fi
+echo '-- Dump completed on 2024-01-01'`)
+	m := NewMySQL(MySQLConfig{MysqldumpPath: dump})
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = m.Backup(cancelled, plugin.BackupTarget{}, &bytes.Buffer{})
+	_, _ = m.Backup(context.Background(), plugin.BackupTarget{}, &bytes.Buffer{})
+	if _, err := m.Backup(context.Background(), plugin.BackupTarget{}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if args := readArgs(t, argsFile); !strings.Contains(args, "--master-data=2") {
+		t.Errorf("args %q missing --master-data=2", args)
+	}
+}
+
+func TestMySQLToolFailure(t *testing.T) {
+	dump, _ := fakeTool(t, "mysqldump", "echo 'Access denied' >&2\nexit 2")
+	_, err := NewMySQL(MySQLConfig{MysqldumpPath: dump}).Backup(context.Background(), plugin.BackupTarget{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "Access denied") {
+		t.Fatalf("err = %v, want stderr in error", err)
+	}
+}
+
+func TestMySQLListLogPositions(t *testing.T) {
+	mysql, argsFile := fakeTool(t, "mysql", "printf 'binlog.000001\\t1024\\tNo\\nbinlog.000002\\t157\\tNo\\n'")
+	positions, err := NewMySQL(MySQLConfig{MysqlPath: mysql}).ListLogPositions(context.Background(), plugin.BackupTarget{Socket: "/run/mysqld.sock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 2 || positions[0].File != "binlog.000001" || positions[1].Position != 157 {
+		t.Fatalf("positions = %+v", positions)
+	}
+	if !strings.Contains(readArgs(t, argsFile), "--socket=/run/mysqld.sock") {
+		t.Error("socket not passed")
+	}
+}
+
+func TestPostgresBackupOneDatabase(t *testing.T) {
+	dump, argsFile := fakeTool(t, "pg_dump", "printf 'PGDMP'")
+	p := NewPostgres(PostgresConfig{PgDumpPath: dump})
+
+	target := plugin.BackupTarget{Host: "pg1", User: "backup", Password: "pw", Databases: []string{"a", "b"}}
+	if _, err := p.Backup(context.Background(), target, &bytes.Buffer{}); err == nil {
+		t.Fatal("backed up two databases into one archive")
+	}
+	target.Databases = []string{"a"}
+	var out bytes.Buffer
+	if _, err := p.Backup(context.Background(), target, &out); err != nil {
+		t.Fatal(err)
+	}
+	args := readArgs(t, argsFile)
+	for _, want := range []string{"--format=custom", "--dbname=a", "--no-password", "pwd=pw"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q missing %q", args, want)
+		}
+	}
+	if out.String() != "PGDMP" {
+		t.Fatalf("out = %q", out.String())
+	}
+}
+
+func TestPostgresListLogPositions(t *testing.T) {
+	psql, _ := fakeTool(t, "psql", "printf '000000010000000A00000003\\t16777216\\t1700000000\\n'")
+	positions, err := NewPostgres(PostgresConfig{PsqlPath: psql}).ListLogPositions(context.Background(), plugin.BackupTarget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].LSN != "A/3000000" || positions[0].Size != 16777216 {
+		t.Fatalf("positions = %+v", positions)
+	}
+}
+
+func TestPhysicalRestoreNeedsEmptyDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "PG_VERSION"), []byte("16"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	target := plugin.BackupTarget{Method: plugin.BackupPhysical, DataDir: dir}
+	if err := NewPostgres(PostgresConfig{}).Restore(context.Background(), target, strings.NewReader("")); err == nil {
+		t.Fatal("restored into a non-empty data dir")
+	}
+}
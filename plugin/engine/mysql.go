@@ -0,0 +1,292 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// mysqldumpTrailer is the last comment mysqldump writes to a complete dump.
+const mysqldumpTrailer = "-- Dump completed"
+
+// xbstreamMagic starts every chunk of an xbstream archive.
+var xbstreamMagic = []byte("XBSTCK01")
+
+// mysqldumpVersion finds the server version in mysqldump --version output:
+// "Ver 8.0.36 for Linux" or, before 8.0, "Ver 10.13 Distrib 5.7.44, for Linux".
+var mysqldumpVersion = regexp.MustCompile(`(?:Ver|Distrib) (\d+)\.(\d+)\.(\d+)`)
+
+// versionTimeout bounds mysqldump --version.
+const versionTimeout = 10 * time.Second
+
+// MySQLConfig points the driver at the client tools; empty paths are looked
+// up in $PATH.
+type MySQLConfig struct {
+	MysqldumpPath  string
+	MysqlPath      string
+	XtrabackupPath string
+	XbstreamPath   string
+	// TempDir holds xtrabackup's scratch files and verify extractions.
+	TempDir string
+	// SourceDataOption is the mysqldump flag recording the binlog position:
+	// "source-data", or "master-data" for mysqldump before 8.0.26 and for
+	// MariaDB. When empty it is chosen from mysqldump --version.
+	SourceDataOption string
+}
+
+// MySQL backs up MySQL with mysqldump (logical) or Percona XtraBackup
+// (physical).
+type MySQL struct {
+	cfg MySQLConfig
+
+	mu         sync.Mutex
+	sourceData string // detected from mysqldump --version
+}
+
+var _ plugin.EngineDriver = (*MySQL)(nil)
+
+func NewMySQL(cfg MySQLConfig) *MySQL {
+	cfg.MysqldumpPath = pathOr(cfg.MysqldumpPath, "mysqldump")
+	cfg.MysqlPath = pathOr(cfg.MysqlPath, "mysql")
+	cfg.XtrabackupPath = pathOr(cfg.XtrabackupPath, "xtrabackup")
+	cfg.XbstreamPath = pathOr(cfg.XbstreamPath, "xbstream")
+	return &MySQL{cfg: cfg}
+}
+
+func (m *MySQL) Metadata() plugin.Metadata { return metadata("mysql") }
+
+func (m *MySQL) Close() error { return nil }
+
+func (m *MySQL) Capabilities() plugin.EngineCapabilities {
+	return plugin.EngineCapabilities{
+		Engine:  "mysql",
+		Methods: []plugin.BackupMethod{plugin.BackupLogical, plugin.BackupPhysical},
+		// xtrabackup can take incrementals, but Backup always takes a full one
+		Incremental: false,
+		PointInTime: true,
+	}
+}
+
+// connArgs are the connection flags shared by all tools. The password goes
+// through MYSQL_PWD so it never shows up in the process list.
+func (m *MySQL) connArgs(target plugin.BackupTarget) ([]string, []string) {
+	var args []string
+	if target.Socket != "" {
+		args = append(args, "--socket="+target.Socket)
+	} else {
+		if target.Host != "" {
+			args = append(args, "--host="+target.Host)
+		}
+		if target.Port != 0 {
+			args = append(args, "--port="+strconv.Itoa(target.Port))
+		}
+	}
+	if target.User != "" {
+		args = append(args, "--user="+target.User)
+	}
+	var env []string
+	if target.Password != "" {
+		env = append(env, "MYSQL_PWD="+target.Password)
+	}
+	return args, env
+}
+
+func (m *MySQL) Backup(ctx context.Context, target plugin.BackupTarget, sink io.Writer) (*plugin.BackupResult, error) {
+	conn, env := m.connArgs(target)
+	switch target.Method {
+	case plugin.BackupLogical, "":
+		args := append(conn, "--single-transaction", "--quick", "--routines", "--triggers", "--events", "--hex-blob", "--"+m.sourceDataOption()+"=2")
+		args = append(args, optionArgs(target, "extra_args")...)
+		if len(target.Databases) > 0 {
+			args = append(append(args, "--databases"), target.Databases...)
+		} else {
+			args = append(args, "--all-databases")
+		}
+		return backup(ctx, plugin.BackupLogical, command{path: m.cfg.MysqldumpPath, args: args, env: env}, sink)
+	case plugin.BackupPhysical:
+		// xtrabackup wants a target dir even when streaming
+		scratch, err := os.MkdirTemp(m.cfg.TempDir, "xtrabackup-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(scratch)
+		args := append(conn, "--backup", "--stream=xbstream", "--target-dir="+scratch)
+		args = append(args, optionArgs(target, "extra_args")...)
+		return backup(ctx, plugin.BackupPhysical, command{path: m.cfg.XtrabackupPath, args: args, env: env}, sink)
+	}
+	return nil, fmt.Errorf("engine: mysql does not support %q backups", target.Method)
+}
+
+// sourceDataOption returns the configured SourceDataOption, detecting it
+// on first use. Detection runs apart from the backup's ctx, so a cancelled
+// backup does not decide the flag, and is retried until it succeeds.
+func (m *MySQL) sourceDataOption() string {
+	if m.cfg.SourceDataOption != "" {
+		return m.cfg.SourceDataOption
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sourceData != "" {
+		return m.sourceData
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	var out bytes.Buffer
+	if err := (command{path: m.cfg.MysqldumpPath, args: []string{"--version"}, stdout: &out}).run(ctx); err != nil {
+		// an unknown version gets the current flag
+		return "source-data"
+	}
+	m.sourceData = sourceDataOption(out.String())
+	return m.sourceData
+}
+
+// sourceDataOption picks the binlog position flag mysqldump understands
+// from its --version output; --source-data was added in 8.0.26.
+func sourceDataOption(version string) string {
+	if strings.Contains(version, "MariaDB") {
+		return "master-data"
+	}
+	match := mysqldumpVersion.FindStringSubmatch(version)
+	if match == nil {
+		return "source-data"
+	}
+	v := [3]int{}
+	for i := range v {
+		v[i], _ = strconv.Atoi(match[i+1])
+	}
+	if v[0] < 8 || (v[0] == 8 && v[1] == 0 && v[2] < 26) {
+		return "master-data"
+	}
+	return "source-data"
+}
+
+func (m *MySQL) Restore(ctx context.Context, target plugin.BackupTarget, source io.Reader) error {
+	switch target.Method {
+	case plugin.BackupLogical, "":
+		conn, env := m.connArgs(target)
+		return command{path: m.cfg.MysqlPath, args: conn, env: env, stdin: source}.run(ctx)
+	case plugin.BackupPhysical:
+		if target.DataDir == "" {
+			return errors.New("engine: physical restore needs a data dir")
+		}
+		if err := emptyDir(target.DataDir); err != nil {
+			return err
+		}
+		if err := (command{path: m.cfg.XbstreamPath, args: []string{"-x", "-C", target.DataDir}, stdin: source}).run(ctx); err != nil {
+			return err
+		}
+		// the server has to be stopped while the data dir is prepared
+		return command{path: m.cfg.XtrabackupPath, args: []string{"--prepare", "--target-dir=" + target.DataDir}}.run(ctx)
+	}
+	return fmt.Errorf("engine: mysql does not support %q restores", target.Method)
+}
+
+func (m *MySQL) Verify(ctx context.Context, method plugin.BackupMethod, source io.Reader) error {
+	switch method {
+	case plugin.BackupLogical, "":
+		tail := &tailBuffer{max: 256}
+		if _, err := io.Copy(tail, ctxReader{ctx: ctx, r: source}); err != nil {
+			return err
+		}
+		if !strings.Contains(tail.String(), mysqldumpTrailer) {
+			return errors.New("engine: mysqldump output is truncated")
+		}
+		return nil
+	case plugin.BackupPhysical:
+		head := make([]byte, len(xbstreamMagic))
+		if _, err := io.ReadFull(source, head); err != nil || !bytes.Equal(head, xbstreamMagic) {
+			return errors.New("engine: not an xbstream archive")
+		}
+		dir, err := os.MkdirTemp(m.cfg.TempDir, "xbstream-verify-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		stream := io.MultiReader(bytes.NewReader(head), source)
+		if err := (command{path: m.cfg.XbstreamPath, args: []string{"-x", "-C", dir}, stdin: stream}).run(ctx); err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dir, "xtrabackup_checkpoints")); err != nil {
+			return errors.New("engine: xbstream archive has no xtrabackup_checkpoints")
+		}
+		return nil
+	}
+	return fmt.Errorf("engine: mysql does not support %q backups", method)
+}
+
+// ListLogPositions returns the binary logs from SHOW BINARY LOGS; Position is
+// the end of each file.
+func (m *MySQL) ListLogPositions(ctx context.Context, target plugin.BackupTarget) ([]plugin.LogPosition, error) {
+	conn, env := m.connArgs(target)
+	args := append(conn, "--batch", "--skip-column-names", "--execute=SHOW BINARY LOGS")
+	out, err := command{path: m.cfg.MysqlPath, args: args, env: env}.output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var positions []plugin.LogPosition
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("engine: unexpected SHOW BINARY LOGS row %q", line)
+		}
+		positions = append(positions, plugin.LogPosition{File: fields[0], Position: uint64(size), Size: size})
+	}
+	return positions, nil
+}
+
+func emptyDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("engine: data dir %s is not empty", dir)
+	}
+	return nil
+}
+
+// ctxReader stops a copy once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
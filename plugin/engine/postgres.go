@@ -0,0 +1,211 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// PostgresConfig points the driver at the client tools; empty paths are looked
+// up in $PATH.
+type PostgresConfig struct {
+	PgDumpPath       string
+	PgRestorePath    string
+	PgBasebackupPath string
+	PsqlPath         string
+	TarPath          string
+}
+
+// Postgres backs up PostgreSQL with pg_dump (logical) or pg_basebackup
+// (physical).
+type Postgres struct {
+	cfg PostgresConfig
+}
+
+var _ plugin.EngineDriver = (*Postgres)(nil)
+
+func NewPostgres(cfg PostgresConfig) *Postgres {
+	cfg.PgDumpPath = pathOr(cfg.PgDumpPath, "pg_dump")
+	cfg.PgRestorePath = pathOr(cfg.PgRestorePath, "pg_restore")
+	cfg.PgBasebackupPath = pathOr(cfg.PgBasebackupPath, "pg_basebackup")
+	cfg.PsqlPath = pathOr(cfg.PsqlPath, "psql")
+	cfg.TarPath = pathOr(cfg.TarPath, "tar")
+	return &Postgres{cfg: cfg}
+}
+
+func (p *Postgres) Metadata() plugin.Metadata { return metadata("postgres") }
+
+func (p *Postgres) Close() error { return nil }
+
+func (p *Postgres) Capabilities() plugin.EngineCapabilities {
+	return plugin.EngineCapabilities{
+		Engine:      "postgres",
+		Methods:     []plugin.BackupMethod{plugin.BackupLogical, plugin.BackupPhysical},
+		Incremental: false,
+		PointInTime: true,
+	}
+}
+
+// connArgs are the connection flags shared by all tools. The password goes
+// through PGPASSWORD so it never shows up in the process list.
+func (p *Postgres) connArgs(target plugin.BackupTarget) ([]string, []string) {
+	var args []string
+	switch {
+	case target.Socket != "":
+		args = append(args, "--host="+target.Socket)
+	case target.Host != "":
+		args = append(args, "--host="+target.Host)
+	}
+	if target.Port != 0 {
+		args = append(args, "--port="+strconv.Itoa(target.Port))
+	}
+	if target.User != "" {
+		args = append(args, "--username="+target.User)
+	}
+	env := []string{"PGAPPNAME=frabit-agent"}
+	if target.Password != "" {
+		env = append(env, "PGPASSWORD="+target.Password)
+	}
+	return append(args, "--no-password"), env
+}
+
+// database returns the single database a logical backup covers; pg_dump
+// cannot dump more than one per archive.
+func database(target plugin.BackupTarget) (string, error) {
+	switch len(target.Databases) {
+	case 0:
+		return "postgres", nil
+	case 1:
+		return target.Databases[0], nil
+	}
+	return "", errors.New("engine: pg_dump backs up one database per archive")
+}
+
+func (p *Postgres) Backup(ctx context.Context, target plugin.BackupTarget, sink io.Writer) (*plugin.BackupResult, error) {
+	conn, env := p.connArgs(target)
+	switch target.Method {
+	case plugin.BackupLogical, "":
+		db, err := database(target)
+		if err != nil {
+			return nil, err
+		}
+		args := append(conn, "--format=custom")
+		args = append(args, optionArgs(target, "extra_args")...)
+		args = append(args, "--dbname="+db)
+		return backup(ctx, plugin.BackupLogical, command{path: p.cfg.PgDumpPath, args: args, env: env}, sink)
+	case plugin.BackupPhysical:
+		// streaming a single tar to stdout only works with fetched WAL
+		args := append(conn, "--pgdata=-", "--format=tar", "--wal-method=fetch", "--checkpoint=fast")
+		args = append(args, optionArgs(target, "extra_args")...)
+		return backup(ctx, plugin.BackupPhysical, command{path: p.cfg.PgBasebackupPath, args: args, env: env}, sink)
+	}
+	return nil, fmt.Errorf("engine: postgres does not support %q backups", target.Method)
+}
+
+func (p *Postgres) Restore(ctx context.Context, target plugin.BackupTarget, source io.Reader) error {
+	switch target.Method {
+	case plugin.BackupLogical, "":
+		db, err := database(target)
+		if err != nil {
+			return err
+		}
+		conn, env := p.connArgs(target)
+		args := append(conn, "--clean", "--if-exists", "--no-owner", "--dbname="+db)
+		return command{path: p.cfg.PgRestorePath, args: args, env: env, stdin: source}.run(ctx)
+	case plugin.BackupPhysical:
+		if target.DataDir == "" {
+			return errors.New("engine: physical restore needs a data dir")
+		}
+		if err := emptyDir(target.DataDir); err != nil {
+			return err
+		}
+		return command{path: p.cfg.TarPath, args: []string{"-x", "-f", "-", "-C", target.DataDir}, stdin: source}.run(ctx)
+	}
+	return fmt.Errorf("engine: postgres does not support %q restores", target.Method)
+}
+
+func (p *Postgres) Verify(ctx context.Context, method plugin.BackupMethod, source io.Reader) error {
+	switch method {
+	case plugin.BackupLogical, "":
+		// pg_restore reads the whole table of contents and fails on a damaged archive
+		return command{path: p.cfg.PgRestorePath, args: []string{"--list"}, stdin: source, stdout: io.Discard}.run(ctx)
+	case plugin.BackupPhysical:
+		out, err := command{path: p.cfg.TarPath, args: []string{"-t", "-f", "-"}, stdin: source}.output(ctx)
+		if err != nil {
+			return err
+		}
+		for _, name := range strings.Split(out, "\n") {
+			if strings.TrimPrefix(strings.TrimSpace(name), "./") == "backup_label" {
+				return nil
+			}
+		}
+		return errors.New("engine: base backup has no backup_label")
+	}
+	return fmt.Errorf("engine: postgres does not support %q backups", method)
+}
+
+// ListLogPositions returns the WAL segments in pg_wal; LSN is the start of
+// each segment.
+func (p *Postgres) ListLogPositions(ctx context.Context, target plugin.BackupTarget) ([]plugin.LogPosition, error) {
+	conn, env := p.connArgs(target)
+	const query = "SELECT name, size, extract(epoch FROM modification)::bigint FROM pg_ls_waldir() " +
+		"WHERE name ~ '^[0-9A-F]{24}$' ORDER BY name"
+	args := append(conn, "--no-align", "--tuples-only", "--field-separator=\t", "--dbname=postgres", "--command="+query)
+	out, err := command{path: p.cfg.PsqlPath, args: args, env: env}.output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var positions []plugin.LogPosition
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		size, err1 := strconv.ParseInt(fields[1], 10, 64)
+		epoch, err2 := strconv.ParseInt(fields[2], 10, 64)
+		lsn, err3 := segmentLSN(fields[0], size)
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return nil, fmt.Errorf("engine: unexpected pg_ls_waldir row %q: %w", line, err)
+		}
+		positions = append(positions, plugin.LogPosition{File: fields[0], LSN: lsn, Size: size, Time: time.Unix(epoch, 0).UTC()})
+	}
+	return positions, nil
+}
+
+// segmentLSN turns a WAL file name (timeline, log, segment as 8 hex digits
+// each) into the LSN its first record lives at.
+func segmentLSN(name string, segSize int64) (string, error) {
+	if len(name) != 24 || segSize <= 0 {
+		return "", fmt.Errorf("invalid segment %q", name)
+	}
+	log, err := strconv.ParseUint(name[8:16], 16, 32)
+	if err != nil {
+		return "", err
+	}
+	seg, err := strconv.ParseUint(name[16:24], 16, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%X/%X", log, seg*uint64(segSize)), nil
+}
@@ -0,0 +1,303 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultStartTimeout    = 10 * time.Second
+	defaultShutdownTimeout = 10 * time.Second
+	defaultHealthInterval  = 30 * time.Second
+	maxHealthFailures      = 3
+)
+
+// LaunchOptions describes how to start an out-of-process plugin.
+type LaunchOptions struct {
+	Path string
+	Args []string
+	// Env is appended to the agent's environment.
+	Env       []string
+	Transport Transport
+	// Stderr receives the plugin's log output; it is discarded when nil.
+	Stderr io.Writer
+
+	StartTimeout    time.Duration
+	ShutdownTimeout time.Duration
+	// HealthInterval is the period of background health checks; negative disables them.
+	HealthInterval time.Duration
+}
+
+// External is a plugin running in its own process.
+type External struct {
+	meta    Metadata
+	cmd     *exec.Cmd
+	conn    io.Closer
+	rpc     *rpcClient
+	sockDir string
+
+	shutdownTimeout time.Duration
+	healthy         atomic.Bool
+	exited          chan struct{}
+	stopHealth      context.CancelFunc
+	closeOnce       sync.Once
+	closeErr        error
+}
+
+// Launch starts the plugin binary, waits for its handshake and verifies it
+// speaks the protocol and plugin API of this agent.
+func Launch(ctx context.Context, opts LaunchOptions) (*External, error) {
+	if opts.Transport == "" {
+		opts.Transport = TransportStdio
+	}
+	if opts.StartTimeout == 0 {
+		opts.StartTimeout = defaultStartTimeout
+	}
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if opts.HealthInterval == 0 {
+		opts.HealthInterval = defaultHealthInterval
+	}
+
+	// the plugin outlives the launch context, it is stopped with Close
+	cmd := exec.Command(opts.Path, opts.Args...)
+	cmd.Env = append(os.Environ(), opts.Env...)
+	cmd.Env = append(cmd.Env, envMagicCookie+"="+magicCookie, envTransport+"="+string(opts.Transport))
+	cmd.Stderr = opts.Stderr
+
+	e := &External{cmd: cmd, shutdownTimeout: opts.ShutdownTimeout, exited: make(chan struct{})}
+	conn, err := e.start(opts)
+	if err != nil {
+		e.cleanup()
+		return nil, err
+	}
+	e.conn = conn
+
+	go func() {
+		_ = cmd.Wait()
+		e.healthy.Store(false)
+		close(e.exited)
+	}()
+
+	c := newCodec(conn)
+	if err := e.handshake(ctx, c, opts.StartTimeout); err != nil {
+		e.kill()
+		return nil, err
+	}
+	e.rpc = newRPCClient(c)
+	go e.rpc.readLoop()
+	e.healthy.Store(true)
+
+	healthCtx, stop := context.WithCancel(context.Background())
+	e.stopHealth = stop
+	if opts.HealthInterval > 0 {
+		go e.healthLoop(healthCtx, opts.HealthInterval)
+	}
+	return e, nil
+}
+
+func (e *External) start(opts LaunchOptions) (io.ReadWriteCloser, error) {
+	switch opts.Transport {
+	case TransportStdio:
+		stdin, err := e.cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := e.cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := e.cmd.Start(); err != nil {
+			return nil, err
+		}
+		return pipeConn{Reader: stdout, WriteCloser: stdin}, nil
+
+	case TransportUnix:
+		dir, err := os.MkdirTemp("", "frabit-plugin-")
+		if err != nil {
+			return nil, err
+		}
+		e.sockDir = dir
+		sock := filepath.Join(dir, "plugin.sock")
+		ln, err := net.Listen("unix", sock)
+		if err != nil {
+			return nil, err
+		}
+		defer ln.Close()
+		e.cmd.Env = append(e.cmd.Env, envSocket+"="+sock)
+		if err := e.cmd.Start(); err != nil {
+			return nil, err
+		}
+		_ = ln.(*net.UnixListener).SetDeadline(time.Now().Add(opts.StartTimeout))
+		conn, err := ln.Accept()
+		if err != nil {
+			_ = e.cmd.Process.Kill()
+			_ = e.cmd.Wait()
+			return nil, fmt.Errorf("%w: %v", ErrHandshakeTimedOut, err)
+		}
+		return conn, nil
+
+	default:
+		return nil, fmt.Errorf("plugin: unsupported transport %q", opts.Transport)
+	}
+}
+
+func (e *External) handshake(ctx context.Context, c *codec, timeout time.Duration) error {
+	type result struct {
+		f   *frame
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		f, err := c.read()
+		ch <- result{f, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var r result
+	select {
+	case r = <-ch:
+	case <-timer.C:
+		return ErrHandshakeTimedOut
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-e.exited:
+		return ErrPluginExited
+	}
+	if r.err != nil {
+		return fmt.Errorf("plugin: reading handshake: %w", r.err)
+	}
+
+	var hs handshake
+	if err := json.Unmarshal(r.f.Result, &hs); err != nil {
+		return fmt.Errorf("plugin: malformed handshake: %w", err)
+	}
+	if hs.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("%w: plugin speaks %d, agent speaks %d", ErrIncompatibleWire, hs.ProtocolVersion, ProtocolVersion)
+	}
+	if hs.Metadata.APIVersion != APIVersion {
+		return fmt.Errorf("%w: %s speaks %d, agent speaks %d", ErrIncompatibleAPI, hs.Metadata.Name, hs.Metadata.APIVersion, APIVersion)
+	}
+	e.meta = hs.Metadata
+	return nil
+}
+
+func (e *External) Metadata() Metadata { return e.meta }
+
+// Call invokes method on the plugin. Cancelling ctx cancels the call on the
+// plugin side as well.
+func (e *External) Call(ctx context.Context, method string, params, result any) error {
+	return e.rpc.call(ctx, method, params, result)
+}
+
+// Health asks the plugin whether it is able to serve calls.
+func (e *External) Health(ctx context.Context) error {
+	return e.rpc.call(ctx, methodHealth, nil, nil)
+}
+
+// Healthy reports the outcome of the background health checks.
+func (e *External) Healthy() bool { return e.healthy.Load() }
+
+// Exited is closed once the plugin process is gone.
+func (e *External) Exited() <-chan struct{} { return e.exited }
+
+func (e *External) healthLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.exited:
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			err := e.Health(checkCtx)
+			cancel()
+			if err == nil {
+				failures = 0
+				e.healthy.Store(true)
+				continue
+			}
+			failures++
+			if failures >= maxHealthFailures {
+				e.healthy.Store(false)
+			}
+		}
+	}
+}
+
+// Close asks the plugin to shut down and kills it if it does not exit within
+// the shutdown timeout.
+func (e *External) Close() error {
+	e.closeOnce.Do(func() {
+		e.stopHealth()
+		ctx, cancel := context.WithTimeout(context.Background(), e.shutdownTimeout)
+		defer cancel()
+
+		err := e.rpc.call(ctx, methodShutdown, nil, nil)
+		if errors.Is(err, ErrPluginExited) {
+			err = nil
+		}
+		_ = e.conn.Close()
+		select {
+		case <-e.exited:
+		case <-ctx.Done():
+			e.kill()
+			err = errors.Join(err, fmt.Errorf("plugin %s did not exit in time and was killed", e.meta.Name))
+		}
+		e.cleanup()
+		e.closeErr = err
+	})
+	return e.closeErr
+}
+
+func (e *External) kill() {
+	if e.cmd.Process != nil {
+		_ = e.cmd.Process.Kill()
+	}
+	if e.conn != nil {
+		_ = e.conn.Close()
+	}
+	<-e.exited
+	e.cleanup()
+}
+
+func (e *External) cleanup() {
+	if e.sockDir != "" {
+		_ = os.RemoveAll(e.sockDir)
+	}
+}
+
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
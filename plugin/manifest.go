@@ -0,0 +1,347 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// ManifestFile is the name of the manifest shipped next to a plugin binary.
+const ManifestFile = "frabit-plugin.json"
+
+var (
+	ErrUnsigned          = errors.New("plugin: manifest is not signed")
+	ErrBadSignature      = errors.New("plugin: manifest signature does not match a trusted key")
+	ErrChecksumMismatch  = errors.New("plugin: binary does not match manifest checksum")
+	ErrIncompatibleSDK   = errors.New("plugin: incompatible sdk version")
+	ErrPermissionDenied  = errors.New("plugin: permission not granted")
+	ErrManifestMismatch  = errors.New("plugin: plugin metadata does not match its manifest")
+	errChecksumAlgorithm = errors.New("plugin: checksum must be sha256:<hex>")
+)
+
+// Permission is a capability a plugin needs from the agent host.
+type Permission string
+
+const (
+	PermissionNetwork    Permission = "network"
+	PermissionFilesystem Permission = "filesystem"
+	PermissionExec       Permission = "exec"
+	PermissionDatabase   Permission = "database"
+	PermissionSecrets    Permission = "secrets"
+)
+
+var knownPermissions = map[Permission]bool{
+	PermissionNetwork:    true,
+	PermissionFilesystem: true,
+	PermissionExec:       true,
+	PermissionDatabase:   true,
+	PermissionSecrets:    true,
+}
+
+// Manifest describes a plugin binary. The signature covers every other field,
+// so neither the binary nor the requested permissions can be swapped without
+// invalidating it.
+type Manifest struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Kind        Kind   `json:"kind"`
+	Description string `json:"description,omitempty"`
+	// SDK is the range of frabit-go-sdk versions the plugin works with, e.g.
+	// ">=2.0.0 <3.0.0" or "^2.0.0".
+	SDK         string       `json:"sdk"`
+	APIVersion  int          `json:"api_version"`
+	Permissions []Permission `json:"permissions,omitempty"`
+	// Binary is the plugin executable, relative to the manifest.
+	Binary string `json:"binary"`
+	// Checksum is "sha256:" followed by the hex digest of Binary.
+	Checksum string `json:"checksum"`
+	// Signature is the base64 ed25519 signature of SigningPayload.
+	Signature string `json:"signature,omitempty"`
+}
+
+// ParseManifest decodes and validates a manifest. Unknown fields are an
+// error so a typo cannot silently drop a constraint.
+func ParseManifest(data []byte) (*Manifest, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	m := &Manifest{}
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("plugin: invalid manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// Validate checks that all required fields are present and well formed.
+func (m *Manifest) Validate() error {
+	var errs []error
+	if m.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if _, err := parseVersion(m.Version); err != nil {
+		errs = append(errs, err)
+	}
+	switch m.Kind {
+	case KindBackup, KindStorage, KindNotification, KindEngine:
+	default:
+		errs = append(errs, fmt.Errorf("unknown kind %q", m.Kind))
+	}
+	if m.SDK == "" {
+		errs = append(errs, errors.New("sdk version range is required"))
+	} else if _, err := satisfies(m.SDK, version{}); err != nil {
+		errs = append(errs, err)
+	}
+	if m.APIVersion == 0 {
+		errs = append(errs, errors.New("api_version is required"))
+	}
+	for _, p := range m.Permissions {
+		if !knownPermissions[p] {
+			errs = append(errs, fmt.Errorf("unknown permission %q", p))
+		}
+	}
+	if m.Binary == "" || filepath.IsAbs(m.Binary) || strings.HasPrefix(filepath.Clean(m.Binary), "..") {
+		errs = append(errs, fmt.Errorf("binary %q must be a path inside the plugin directory", m.Binary))
+	}
+	if _, err := checksumDigest(m.Checksum); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("plugin: invalid manifest %q: %w", m.Name, err)
+	}
+	return nil
+}
+
+// SigningPayload is the canonical encoding of m the signature is made over:
+// its JSON form with Signature left empty.
+func (m *Manifest) SigningPayload() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// Sign sets the manifest signature; it is used by plugin publishers.
+func (m *Manifest) Sign(key ed25519.PrivateKey) error {
+	payload, err := m.SigningPayload()
+	if err != nil {
+		return err
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
+
+// Checksum returns the manifest checksum of the data read from r.
+func Checksum(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func checksumDigest(checksum string) ([]byte, error) {
+	algo, digest, ok := strings.Cut(checksum, ":")
+	if !ok || algo != "sha256" {
+		return nil, errChecksumAlgorithm
+	}
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, errChecksumAlgorithm
+	}
+	return sum, nil
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key as found in a trusted
+// key list.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("plugin: invalid ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Verifier decides whether a plugin may be loaded.
+type Verifier struct {
+	// TrustedKeys are the publisher keys a manifest signature is checked against.
+	TrustedKeys []ed25519.PublicKey
+	// AllowUnsigned loads plugins without a signature. Signed plugins are
+	// still rejected when their signature is invalid.
+	AllowUnsigned bool
+	// Permissions lists what plugins may request; a manifest asking for
+	// anything else is refused.
+	Permissions []Permission
+	// SDKVersion is checked against the manifest's sdk range. It defaults to
+	// frabit.Version.
+	SDKVersion string
+}
+
+// Verify checks m against the verifier policy and binary against the
+// manifest checksum.
+func (v *Verifier) Verify(m *Manifest, binary io.Reader) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if err := v.verifySignature(m); err != nil {
+		return err
+	}
+
+	sdk := v.SDKVersion
+	if sdk == "" {
+		sdk = frabit.Version
+	}
+	current, err := parseVersion(sdk)
+	if err != nil {
+		return err
+	}
+	if ok, _ := satisfies(m.SDK, current); !ok {
+		return fmt.Errorf("%w: %s requires sdk %s, have %s", ErrIncompatibleSDK, m.Name, m.SDK, sdk)
+	}
+	if m.APIVersion != APIVersion {
+		return fmt.Errorf("%w: %s uses %d, agent supports %d", ErrIncompatibleAPI, m.Name, m.APIVersion, APIVersion)
+	}
+
+	granted := make(map[Permission]bool, len(v.Permissions))
+	for _, p := range v.Permissions {
+		granted[p] = true
+	}
+	for _, p := range m.Permissions {
+		if !granted[p] {
+			return fmt.Errorf("%w: %s requests %q", ErrPermissionDenied, m.Name, p)
+		}
+	}
+
+	sum, err := Checksum(binary)
+	if err != nil {
+		return err
+	}
+	if sum != m.Checksum {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, m.Name)
+	}
+	return nil
+}
+
+func (v *Verifier) verifySignature(m *Manifest) error {
+	if m.Signature == "" {
+		if v.AllowUnsigned {
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrUnsigned, m.Name)
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBadSignature, m.Name)
+	}
+	payload, err := m.SigningPayload()
+	if err != nil {
+		return err
+	}
+	for _, key := range v.TrustedKeys {
+		if ed25519.Verify(key, payload, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrBadSignature, m.Name)
+}
+
+// LaunchVerified loads the manifest at manifestPath, verifies it and its
+// binary, and launches the plugin. opts.Path is taken from the manifest. The
+// metadata the plugin reports in its handshake has to match the manifest.
+func LaunchVerified(ctx context.Context, manifestPath string, verifier *Verifier, opts LaunchOptions) (*External, error) {
+	m, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	binary := filepath.Join(filepath.Dir(manifestPath), m.Binary)
+	// verify and run a private copy, so the binary cannot be swapped
+	// between the check and the exec
+	dir, err := os.MkdirTemp("", "frabit-plugin-bin-")
+	if err != nil {
+		return nil, err
+	}
+	// the process keeps running from the unlinked copy
+	defer os.RemoveAll(dir)
+	private, err := copyBinary(binary, filepath.Join(dir, filepath.Base(binary)))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(private)
+	if err != nil {
+		return nil, err
+	}
+	err = verifier.Verify(m, f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Path = private
+	e, err := Launch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	meta := e.Metadata()
+	if meta.Name != m.Name || meta.Kind != m.Kind || meta.Version != m.Version {
+		e.Close()
+		return nil, fmt.Errorf("%w: manifest says %s %s (%s), plugin reports %s %s (%s)",
+			ErrManifestMismatch, m.Name, m.Version, m.Kind, meta.Name, meta.Version, meta.Kind)
+	}
+	return e, nil
+}
+
+// copyBinary copies the executable at src to dst, which must not exist.
+func copyBinary(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o700)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return dst, nil
+}
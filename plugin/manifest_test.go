@@ -0,0 +1,193 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		constraint, version string
+		want                bool
+	}{
+		{">=2.0.0 <3.0.0", "2.0.19", true},
+		{">=2.0.0 <3.0.0", "3.0.0", false},
+		{"^2.0.0", "2.9.1", true},
+		{"^2.0.0", "2.0.0-rc.1", false},
+		{"^0.3.0", "0.4.0", false},
+		{"~2.0.5", "2.0.19", true},
+		{"~2.0.5", "2.1.0", false},
+		{"<1.0.0 || >=2.0.19", "2.0.19", true},
+		{"=2.0.19", "v2.0.19+build.7", true},
+		{"!=2.0.19", "2.0.19", false},
+		{">1.0.0-alpha.1", "1.0.0-alpha.beta", true},
+		{">1.0.0-alpha", "1.0.0-alpha.1", true},
+		{"<1.0.0-rc.2", "1.0.0-rc.10", false},
+	}
+	for _, tt := range tests {
+		v, err := parseVersion(tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := satisfies(tt.constraint, v)
+		if err != nil || got != tt.want {
+			t.Errorf("satisfies(%q, %q) = %v, %v; want %v", tt.constraint, tt.version, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", ">=2", "=>2.0.0", "1.0.0 || ", "^01.0.0"} {
+		if _, err := satisfies(bad, version{}); err == nil {
+			t.Errorf("satisfies(%q) accepted a malformed constraint", bad)
+		}
+	}
+}
+
+func testManifest(t *testing.T, binary []byte) *Manifest {
+	t.Helper()
+	sum, err := Checksum(strings.NewReader(string(binary)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Manifest{
+		Name:        "echo",
+		Version:     "1.0.0",
+		Kind:        KindNotification,
+		SDK:         "^2.0.0",
+		APIVersion:  APIVersion,
+		Permissions: []Permission{PermissionNetwork},
+		Binary:      "echo",
+		Checksum:    sum,
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	binary := []byte("#!/bin/true\n")
+
+	signed := func(edit func(m *Manifest)) *Manifest {
+		m := testManifest(t, binary)
+		if err := m.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		if edit != nil {
+			edit(m)
+		}
+		return m
+	}
+	strict := &Verifier{TrustedKeys: []ed25519.PublicKey{pub}, Permissions: []Permission{PermissionNetwork}, SDKVersion: "2.0.19"}
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		manifest *Manifest
+		binary   string
+		want     error
+	}{
+		{"signed", strict, signed(nil), string(binary), nil},
+		{"unsigned refused", strict, testManifest(t, binary), string(binary), ErrUnsigned},
+		{"unsigned allowed", &Verifier{AllowUnsigned: true, Permissions: strict.Permissions, SDKVersion: "2.0.19"}, testManifest(t, binary), string(binary), nil},
+		{"untrusted key", &Verifier{TrustedKeys: []ed25519.PublicKey{otherPub}}, signed(nil), string(binary), ErrBadSignature},
+		{"permissions tampered", strict, signed(func(m *Manifest) { m.Permissions = append(m.Permissions, PermissionExec) }), string(binary), ErrBadSignature},
+		{"binary swapped", strict, signed(nil), "#!/bin/false\n", ErrChecksumMismatch},
+		{"sdk too old", &Verifier{TrustedKeys: strict.TrustedKeys, Permissions: strict.Permissions, SDKVersion: "1.9.0"}, signed(nil), string(binary), ErrIncompatibleSDK},
+		{"permission not granted", &Verifier{TrustedKeys: strict.TrustedKeys, SDKVersion: "2.0.19"}, signed(nil), string(binary), ErrPermissionDenied},
+		{"api version", strict, signed(func(m *Manifest) { m.APIVersion = APIVersion + 1; m.Sign(priv) }), string(binary), ErrIncompatibleAPI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verifier.Verify(tt.manifest, strings.NewReader(tt.binary))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	m := testManifest(t, nil)
+	data, _ := json.Marshal(m)
+	if _, err := ParseManifest(data); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, edit := range map[string]func(m map[string]any){
+		"unknown field":      func(m map[string]any) { m["permisions"] = []string{"exec"} },
+		"unknown permission": func(m map[string]any) { m["permissions"] = []string{"root"} },
+		"binary escapes":     func(m map[string]any) { m["binary"] = "../../bin/sh" },
+		"md5 checksum":       func(m map[string]any) { m["checksum"] = "md5:d41d8cd98f00b204e9800998ecf8427e" },
+		"bad sdk range":      func(m map[string]any) { m["sdk"] = ">= 2" },
+	} {
+		var raw map[string]any
+		json.Unmarshal(data, &raw)
+		edit(raw)
+		bad, _ := json.Marshal(raw)
+		if _, err := ParseManifest(bad); err == nil {
+			t.Errorf("%s: manifest accepted", name)
+		}
+	}
+}
+
+func TestLaunchVerified(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(dir, "echo")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	pub, priv, _ := ed25519.GenerateKey(nil)
+
+	write := func(m *Manifest) string {
+		m.Sign(priv)
+		data, _ := json.Marshal(m)
+		path := filepath.Join(dir, ManifestFile)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	verifier := &Verifier{TrustedKeys: []ed25519.PublicKey{pub}, Permissions: []Permission{PermissionNetwork}}
+	opts := LaunchOptions{Env: []string{helperEnv + "=1"}, HealthInterval: -1}
+
+	p, err := LaunchVerified(context.Background(), write(testManifest(t, binary)), verifier, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	m := testManifest(t, binary)
+	m.Name = "impostor"
+	if _, err := LaunchVerified(context.Background(), write(m), verifier, opts); !errors.Is(err, ErrManifestMismatch) {
+		t.Fatalf("LaunchVerified = %v, want %v", err, ErrManifestMismatch)
+	}
+}
@@ -0,0 +1,120 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// DingTalk posts markdown to a DingTalk group robot, signing the request when
+// the robot has a secret.
+type DingTalk struct{ httpChannel }
+
+var _ plugin.Notifier = (*DingTalk)(nil)
+
+func (d *DingTalk) Notify(ctx context.Context, n frabit.Notification) error {
+	endpoint := d.cfg.URL
+	if d.cfg.Secret != "" {
+		ts := strconv.FormatInt(d.opts.now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(d.cfg.Secret))
+		mac.Write([]byte(ts + "\n" + d.cfg.Secret))
+		sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		query := u.Query()
+		query.Set("timestamp", ts)
+		query.Set("sign", sign)
+		u.RawQuery = query.Encode()
+		endpoint = u.String()
+	}
+
+	var md strings.Builder
+	md.WriteString("### " + subject(n))
+	if n.Message != "" {
+		md.WriteString("\n\n" + n.Message)
+	}
+	for _, k := range sortedKeys(n.Labels) {
+		fmt.Fprintf(&md, "\n- %s: %s", k, n.Labels[k])
+	}
+	payload := map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": subject(n), "text": md.String()},
+	}
+	body, err := d.postJSON(ctx, endpoint, payload, nil)
+	if err != nil {
+		return err
+	}
+	// the robot API answers 200 with an error code in the body
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("notify: %s: malformed response: %w", d.name, err)
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("notify: %s: errcode %d: %s", d.name, resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
+
+// Feishu posts text to a Feishu/Lark custom bot, signing the request when the
+// bot has a secret.
+type Feishu struct{ httpChannel }
+
+var _ plugin.Notifier = (*Feishu)(nil)
+
+func (f *Feishu) Notify(ctx context.Context, n frabit.Notification) error {
+	payload := map[string]any{
+		"msg_type": "text",
+		"content":  map[string]string{"text": text(n)},
+	}
+	if f.cfg.Secret != "" {
+		// Feishu keys the HMAC with the string to sign and signs nothing
+		ts := strconv.FormatInt(f.opts.now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(ts+"\n"+f.cfg.Secret))
+		payload["timestamp"] = ts
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	body, err := f.postJSON(ctx, f.cfg.URL, payload, nil)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("notify: %s: malformed response: %w", f.name, err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("notify: %s: code %d: %s", f.name, resp.Code, resp.Msg)
+	}
+	return nil
+}
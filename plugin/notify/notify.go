@@ -0,0 +1,247 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify implements plugin.Notifier for the channel types managed by
+// frabit.NotificationService, and a Router that fans notifications out to the
+// channels subscribed to them.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+type options struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+type Option func(o *options)
+
+// WithHTTPClient sets the client used by the HTTP based channels.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{client: cleanhttp.DefaultClient(), now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// New returns the Notifier for channel's type.
+func New(channel frabit.NotificationChannel, opts ...Option) (plugin.Notifier, error) {
+	o := newOptions(opts)
+	name := channel.Name
+	if name == "" {
+		name = string(channel.Type)
+	}
+	base := httpChannel{name: name, cfg: channel.Config, opts: o}
+
+	switch channel.Type {
+	case frabit.ChannelEmail:
+		return newEmail(name, channel.Config)
+	case frabit.ChannelPagerDuty:
+		if channel.Config.RoutingKey == "" {
+			return nil, fmt.Errorf("notify: channel %q needs a routing key", name)
+		}
+		return &PagerDuty{base}, nil
+	}
+
+	if channel.Config.URL == "" {
+		return nil, fmt.Errorf("notify: channel %q needs a url", name)
+	}
+	switch channel.Type {
+	case frabit.ChannelWebhook:
+		return &Webhook{base}, nil
+	case frabit.ChannelSlack:
+		return &Slack{base}, nil
+	case frabit.ChannelDingTalk:
+		return &DingTalk{base}, nil
+	case frabit.ChannelFeishu:
+		return &Feishu{base}, nil
+	}
+	return nil, fmt.Errorf("notify: unknown channel type %q", channel.Type)
+}
+
+func metadata(name string) plugin.Metadata {
+	return plugin.Metadata{Name: name, Version: frabit.Version, Kind: plugin.KindNotification, APIVersion: plugin.APIVersion}
+}
+
+// httpChannel is shared by the channels that POST JSON to an endpoint.
+type httpChannel struct {
+	name string
+	cfg  frabit.ChannelConfig
+	opts options
+}
+
+func (h *httpChannel) Metadata() plugin.Metadata { return metadata(h.name) }
+
+func (h *httpChannel) Close() error { return nil }
+
+// post sends payload and returns the response body of a 2xx response.
+func (h *httpChannel) post(ctx context.Context, url string, payload []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", frabit.UserAgent)
+	for k, v := range h.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := h.opts.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("notify: %s returned %s: %s", h.name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func (h *httpChannel) postJSON(ctx context.Context, url string, v any, headers map[string]string) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return h.post(ctx, url, payload, headers)
+}
+
+// subject is the one line summary used as title by every channel.
+func subject(n frabit.Notification) string {
+	severity := n.Severity
+	if severity == "" {
+		severity = frabit.SeverityInfo
+	}
+	return fmt.Sprintf("[%s] %s", strings.ToUpper(string(severity)), n.Title)
+}
+
+// text renders n as plain text: subject, message and the labels sorted by key.
+func text(n frabit.Notification) string {
+	var b strings.Builder
+	b.WriteString(subject(n))
+	if n.Message != "" {
+		b.WriteString("\n" + n.Message)
+	}
+	for _, k := range sortedKeys(n.Labels) {
+		fmt.Fprintf(&b, "\n%s: %s", k, n.Labels[k])
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Router sends each notification to every enabled channel with a matching
+// subscription, once per channel.
+type Router struct {
+	channels      []string
+	notifiers     map[string]plugin.Notifier
+	subscriptions []frabit.Subscription
+}
+
+func NewRouter(channels []frabit.NotificationChannel, subscriptions []frabit.Subscription, opts ...Option) (*Router, error) {
+	r := &Router{notifiers: make(map[string]plugin.Notifier), subscriptions: subscriptions}
+	for _, channel := range channels {
+		if !channel.Enabled {
+			continue
+		}
+		notifier, err := New(channel, opts...)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.channels = append(r.channels, channel.ID)
+		r.notifiers[channel.ID] = notifier
+	}
+	return r, nil
+}
+
+// Load builds a Router from the channels and subscriptions stored in Frabit.
+func Load(ctx context.Context, client *frabit.Client, opts ...Option) (*Router, error) {
+	channels, err := client.Notification.ListChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := client.Notification.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewRouter(channels, subscriptions, opts...)
+}
+
+// Notify delivers n and returns the errors of all channels that failed.
+func (r *Router) Notify(ctx context.Context, n frabit.Notification) error {
+	if n.Time.IsZero() {
+		n.Time = time.Now().UTC()
+	}
+	matched := make(map[string]bool)
+	for _, s := range r.subscriptions {
+		if s.Matches(n) {
+			matched[s.ChannelID] = true
+		}
+	}
+	var errs []error
+	for _, id := range r.channels {
+		if !matched[id] {
+			continue
+		}
+		if err := r.notifiers[id].Notify(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Router) Close() error {
+	var errs []error
+	for _, notifier := range r.notifiers {
+		errs = append(errs, notifier.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,303 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+)
+
+// standIn records every request it receives and answers with reply.
+type standIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newStandIn(t *testing.T, status int, reply string) *standIn {
+	s := &standIn{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		s.mu.Unlock()
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *standIn) last(t *testing.T) (*http.Request, map[string]any) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("no request received")
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(s.bodies[len(s.bodies)-1], &payload); err != nil {
+		t.Fatal(err)
+	}
+	return s.requests[len(s.requests)-1], payload
+}
+
+var testNotification = frabit.Notification{
+	Event:    frabit.EventBackupFailed,
+	Severity: frabit.SeverityCritical,
+	Title:    "backup of db1 failed",
+	Message:  "mysqldump exited with status 2",
+	Labels:   map[string]string{"cluster": "prod", "dedup_key": "db1-backup"},
+	Time:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func notifier(t *testing.T, channelType frabit.ChannelType, cfg frabit.ChannelConfig) *httpChannel {
+	t.Helper()
+	n, err := New(frabit.NotificationChannel{Name: "test", Type: channelType, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	switch n := n.(type) {
+	case *Webhook:
+		return &n.httpChannel
+	case *DingTalk:
+		return &n.httpChannel
+	case *Feishu:
+		return &n.httpChannel
+	}
+	return nil
+}
+
+func TestWebhookSignature(t *testing.T) {
+	srv := newStandIn(t, http.StatusNoContent, "")
+	notifier(t, frabit.ChannelWebhook, frabit.ChannelConfig{URL: srv.URL, Secret: "k", Headers: map[string]string{"X-Team": "dba"}})
+
+	req, payload := srv.last(t)
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write(srv.bodies[0])
+	if got, want := req.Header.Get(SignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.Header.Get("X-Team") != "dba" || req.Header.Get("X-Frabit-Event") != "backup.failed" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	if payload["title"] != testNotification.Title {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	srv := newStandIn(t, http.StatusOK, "ok")
+	notifier(t, frabit.ChannelSlack, frabit.ChannelConfig{URL: srv.URL})
+
+	_, payload := srv.last(t)
+	want := "[CRITICAL] backup of db1 failed\nmysqldump exited with status 2\ncluster: prod\ndedup_key: db1-backup"
+	if payload["text"] != want {
+		t.Errorf("text = %q, want %q", payload["text"], want)
+	}
+}
+
+func TestDingTalkSignedRequest(t *testing.T) {
+	srv := newStandIn(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	notifier(t, frabit.ChannelDingTalk, frabit.ChannelConfig{URL: srv.URL + "/robot/send?access_token=abc", Secret: "SEC1"})
+
+	req, payload := srv.last(t)
+	query := req.URL.Query()
+	mac := hmac.New(sha256.New, []byte("SEC1"))
+	mac.Write([]byte(query.Get("timestamp") + "\nSEC1"))
+	if query.Get("sign") != base64.StdEncoding.EncodeToString(mac.Sum(nil)) || query.Get("access_token") != "abc" {
+		t.Errorf("query = %v", query)
+	}
+	if payload["msgtype"] != "markdown" {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+func TestDingTalkErrorCode(t *testing.T) {
+	srv := newStandIn(t, http.StatusOK, `{"errcode":310000,"errmsg":"sign not match"}`)
+	n, _ := New(frabit.NotificationChannel{Type: frabit.ChannelDingTalk, Config: frabit.ChannelConfig{URL: srv.URL}})
+	if err := n.Notify(context.Background(), testNotification); err == nil || !strings.Contains(err.Error(), "sign not match") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestFeishuSignedRequest(t *testing.T) {
+	srv := newStandIn(t, http.StatusOK, `{"code":0,"msg":"success"}`)
+	notifier(t, frabit.ChannelFeishu, frabit.ChannelConfig{URL: srv.URL, Secret: "s"})
+
+	_, payload := srv.last(t)
+	ts, _ := payload["timestamp"].(string)
+	if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+		t.Fatalf("timestamp = %v", payload["timestamp"])
+	}
+	mac := hmac.New(sha256.New, []byte(ts+"\ns"))
+	if payload["sign"] != base64.StdEncoding.EncodeToString(mac.Sum(nil)) || payload["msg_type"] != "text" {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+func TestPagerDuty(t *testing.T) {
+	srv := newStandIn(t, http.StatusAccepted, `{"status":"success"}`)
+	n, err := New(frabit.NotificationChannel{Type: frabit.ChannelPagerDuty, Config: frabit.ChannelConfig{URL: srv.URL, RoutingKey: "R0UT1NG"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	_, payload := srv.last(t)
+	details := payload["payload"].(map[string]any)
+	if payload["routing_key"] != "R0UT1NG" || payload["dedup_key"] != "db1-backup" || details["severity"] != "critical" {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	srv := newStandIn(t, http.StatusForbidden, "invalid token")
+	n, _ := New(frabit.NotificationChannel{Type: frabit.ChannelSlack, Config: frabit.ChannelConfig{URL: srv.URL}})
+	if err := n.Notify(context.Background(), testNotification); err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	ops := newStandIn(t, http.StatusOK, "ok")
+	audit := newStandIn(t, http.StatusOK, "ok")
+	channels := []frabit.NotificationChannel{
+		{ID: "ops", Type: frabit.ChannelSlack, Enabled: true, Config: frabit.ChannelConfig{URL: ops.URL}},
+		{ID: "audit", Type: frabit.ChannelWebhook, Enabled: true, Config: frabit.ChannelConfig{URL: audit.URL}},
+		{ID: "off", Type: frabit.ChannelWebhook, Enabled: false},
+	}
+	subscriptions := []frabit.Subscription{
+		{ChannelID: "ops", Events: []frabit.EventType{frabit.EventBackupFailed}},
+		{ChannelID: "ops", MinSeverity: frabit.SeverityWarning},
+		{ChannelID: "audit"},
+		{ChannelID: "off"},
+	}
+	r, err := NewRouter(channels, subscriptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx := context.Background()
+	if err := r.Notify(ctx, testNotification); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Notify(ctx, frabit.Notification{Event: frabit.EventBackupSucceeded, Severity: frabit.SeverityInfo}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops.requests) != 1 {
+		t.Errorf("ops got %d notifications, want 1", len(ops.requests))
+	}
+	if len(audit.requests) != 2 {
+		t.Errorf("audit got %d notifications, want 2", len(audit.requests))
+	}
+}
+
+// fakeSMTP accepts one unauthenticated message and returns it on the channel.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				messages <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestEmail(t *testing.T) {
+	addr, messages := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	n, err := New(frabit.NotificationChannel{Type: frabit.ChannelEmail, Config: frabit.ChannelConfig{
+		SMTPHost: host, SMTPPort: p, From: "frabit@example.com", To: []string{"dba@example.com"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification := testNotification
+	notification.Title = "evil\r\nBcc: victim@example.com"
+	if err := n.Notify(context.Background(), notification); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-messages
+	header, body, _ := strings.Cut(msg, "\r\n\r\n")
+	if !strings.Contains(header, "Subject: [CRITICAL] evil  Bcc: victim@example.com\r\n") || strings.Contains(header, "\r\nBcc:") {
+		t.Errorf("header injection not neutralised:\n%s", msg)
+	}
+	if !strings.Contains(body, "mysqldump exited with status 2\r\n") {
+		t.Errorf("body missing:\n%s", msg)
+	}
+}
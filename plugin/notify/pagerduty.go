@@ -0,0 +1,93 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"time"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty triggers incidents through the Events API v2. The "dedup_key" and
+// "source" labels, when set, map to the fields of the same name.
+type PagerDuty struct{ httpChannel }
+
+var _ plugin.Notifier = (*PagerDuty)(nil)
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key,omitempty"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func (p *PagerDuty) Notify(ctx context.Context, n frabit.Notification) error {
+	endpoint := p.cfg.URL
+	if endpoint == "" {
+		endpoint = pagerDutyEventsURL
+	}
+	source := n.Labels["source"]
+	if source == "" {
+		source = "frabit"
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  p.cfg.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    n.Labels["dedup_key"],
+		Payload: pagerDutyPayload{
+			Summary:       subject(n),
+			Source:        source,
+			Severity:      pagerDutySeverity(n.Severity),
+			Class:         string(n.Event),
+			CustomDetails: n.Labels,
+		},
+	}
+	if !n.Time.IsZero() {
+		event.Payload.Timestamp = n.Time.UTC().Format(time.RFC3339)
+	}
+	if n.Message != "" {
+		details := map[string]string{"message": n.Message}
+		for k, v := range n.Labels {
+			details[k] = v
+		}
+		event.Payload.CustomDetails = details
+	}
+	_, err := p.postJSON(ctx, endpoint, event, nil)
+	return err
+}
+
+func pagerDutySeverity(s frabit.Severity) string {
+	switch s {
+	case frabit.SeverityCritical:
+		return "critical"
+	case frabit.SeverityWarning:
+		return "warning"
+	}
+	return "info"
+}
@@ -0,0 +1,62 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed with
+// the channel secret, as "sha256=<hex>".
+const SignatureHeader = "X-Frabit-Signature"
+
+// Webhook POSTs the notification as JSON.
+type Webhook struct{ httpChannel }
+
+var _ plugin.Notifier = (*Webhook)(nil)
+
+func (w *Webhook) Notify(ctx context.Context, n frabit.Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"X-Frabit-Event": string(n.Event)}
+	if w.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.cfg.Secret))
+		mac.Write(payload)
+		headers[SignatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	_, err = w.post(ctx, w.cfg.URL, payload, headers)
+	return err
+}
+
+// Slack posts to a Slack incoming webhook, or anything accepting the same
+// payload such as Mattermost and Rocket.Chat.
+type Slack struct{ httpChannel }
+
+var _ plugin.Notifier = (*Slack)(nil)
+
+func (s *Slack) Notify(ctx context.Context, n frabit.Notification) error {
+	_, err := s.postJSON(ctx, s.cfg.URL, map[string]string{"text": text(n)}, nil)
+	return err
+}
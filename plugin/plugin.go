@@ -13,4 +13,130 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package plugin is the extension mechanism of Frabit agents. Plugins are
+// either compiled in and added to a Registry directly, or shipped as separate
+// binaries launched with Launch and spoken to over a small RPC protocol.
 package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// APIVersion is the version of the plugin interfaces defined in this package.
+// It is bumped whenever one of them changes incompatibly.
+const APIVersion = 1
+
+type Kind string
+
+const (
+	KindBackup       Kind = "backup"
+	KindStorage      Kind = "storage"
+	KindNotification Kind = "notification"
+	KindEngine       Kind = "engine"
+)
+
+type Metadata struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Kind       Kind   `json:"kind"`
+	APIVersion int    `json:"api_version"`
+}
+
+// Plugin is implemented by every extension, in process or not.
+type Plugin interface {
+	Metadata() Metadata
+	Close() error
+}
+
+var (
+	ErrNotFound           = errors.New("plugin: not found")
+	ErrAlreadyRegistered  = errors.New("plugin: already registered")
+	ErrIncompatibleAPI    = errors.New("plugin: incompatible api version")
+	ErrIncompatibleWire   = errors.New("plugin: incompatible protocol version")
+	ErrPluginExited       = errors.New("plugin: process exited")
+	ErrHandshakeTimedOut  = errors.New("plugin: handshake timed out")
+	ErrNotLaunchedByAgent = errors.New("plugin: this binary is a Frabit plugin and must be launched by a Frabit agent")
+)
+
+// Registry holds the plugins an agent has loaded, keyed by name.
+type Registry struct {
+	mu      sync.RWMutex
+	plugins map[string]Plugin
+}
+
+func NewRegistry() *Registry {
+	return &Registry{plugins: make(map[string]Plugin)}
+}
+
+// Register adds p to the registry. It fails for a name that is taken and for
+// plugins built against a different APIVersion.
+func (r *Registry) Register(p Plugin) error {
+	meta := p.Metadata()
+	if meta.APIVersion != APIVersion {
+		return fmt.Errorf("%w: %s speaks %d, agent speaks %d", ErrIncompatibleAPI, meta.Name, meta.APIVersion, APIVersion)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.plugins[meta.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyRegistered, meta.Name)
+	}
+	r.plugins[meta.Name] = p
+	return nil
+}
+
+func (r *Registry) Get(name string) (Plugin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.plugins[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return p, nil
+}
+
+// List returns the metadata of the registered plugins of the given kind, or
+// of all plugins when kind is empty, sorted by name.
+func (r *Registry) List(kind Kind) []Metadata {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var list []Metadata
+	for _, p := range r.plugins {
+		if meta := p.Metadata(); kind == "" || meta.Kind == kind {
+			list = append(list, meta)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Unregister removes the named plugin and closes it.
+func (r *Registry) Unregister(name string) error {
+	r.mu.Lock()
+	p, ok := r.plugins[name]
+	delete(r.plugins, name)
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return p.Close()
+}
+
+// Close closes every registered plugin and empties the registry.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	plugins := r.plugins
+	r.plugins = make(map[string]Plugin)
+	r.mu.Unlock()
+
+	var errs []error
+	for _, p := range plugins {
+		if err := p.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,139 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMain doubles as the plugin binary: the tests launch their own
+// executable with helperEnv set to get a real out-of-process plugin.
+const helperEnv = "FRABIT_PLUGIN_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "" {
+		os.Exit(m.Run())
+	}
+
+	srv := &Server{
+		Metadata: Metadata{Name: "echo", Version: "1.0.0", Kind: KindNotification, APIVersion: APIVersion},
+		Handlers: map[string]Handler{
+			"echo": func(ctx context.Context, params json.RawMessage) (any, error) {
+				var s string
+				err := json.Unmarshal(params, &s)
+				return s, err
+			},
+			"block": func(ctx context.Context, params json.RawMessage) (any, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		},
+	}
+	if err := srv.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+type staticPlugin struct {
+	meta   Metadata
+	closed bool
+}
+
+func (p *staticPlugin) Metadata() Metadata { return p.meta }
+func (p *staticPlugin) Close() error       { p.closed = true; return nil }
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	s3 := &staticPlugin{meta: Metadata{Name: "s3", Kind: KindStorage, APIVersion: APIVersion}}
+	if err := r.Register(s3); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(s3); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Errorf("duplicate register: %v", err)
+	}
+	if err := r.Register(&staticPlugin{meta: Metadata{Name: "old", APIVersion: APIVersion + 1}}); !errors.Is(err, ErrIncompatibleAPI) {
+		t.Errorf("incompatible register: %v", err)
+	}
+	if got := r.List(KindStorage); len(got) != 1 || got[0].Name != "s3" {
+		t.Errorf("List = %+v", got)
+	}
+	if _, err := r.Get("sftp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing: %v", err)
+	}
+	if err := r.Close(); err != nil || !s3.closed {
+		t.Errorf("Close: %v, closed = %v", err, s3.closed)
+	}
+}
+
+func TestExternalPlugin(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transport := range []Transport{TransportStdio, TransportUnix} {
+		t.Run(string(transport), func(t *testing.T) {
+			ctx := context.Background()
+			p, err := Launch(ctx, LaunchOptions{
+				Path:      exe,
+				Env:       []string{helperEnv + "=1"},
+				Transport: transport,
+				Stderr:    os.Stderr,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if meta := p.Metadata(); meta.Name != "echo" || meta.Kind != KindNotification {
+				t.Errorf("Metadata = %+v", meta)
+			}
+			var out string
+			if err := p.Call(ctx, "echo", "hello", &out); err != nil || out != "hello" {
+				t.Errorf("echo = %q, %v", out, err)
+			}
+			if err := p.Health(ctx); err != nil {
+				t.Errorf("Health: %v", err)
+			}
+			var rpcErr *RPCError
+			if err := p.Call(ctx, "missing", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != "unknown_method" {
+				t.Errorf("unknown method: %v", err)
+			}
+
+			blockCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			if err := p.Call(blockCtx, "block", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("block: %v", err)
+			}
+
+			if err := p.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			select {
+			case <-p.Exited():
+			default:
+				t.Error("plugin process still running after Close")
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the version of the wire protocol between an agent and an
+// out-of-process plugin. Both ends must agree on it exactly.
+const ProtocolVersion = 1
+
+// Methods every out-of-process plugin answers besides its own.
+const (
+	methodHealth   = "plugin.health"
+	methodShutdown = "plugin.shutdown"
+	methodCancel   = "plugin.cancel"
+)
+
+// The protocol is newline delimited JSON. The plugin opens with a handshake
+// frame carrying ID 0, after which the agent sends requests and the plugin
+// answers each with a frame of the same ID, in any order.
+type frame struct {
+	ID     uint64          `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RPCError       `json:"error,omitempty"`
+}
+
+type handshake struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Metadata        Metadata `json:"metadata"`
+}
+
+type cancelParams struct {
+	ID uint64 `json:"id"`
+}
+
+// RPCError is an error returned by a plugin method.
+type RPCError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string { return e.Message }
+
+type codec struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+func newCodec(rw io.ReadWriter) *codec {
+	return &codec{enc: json.NewEncoder(rw), dec: json.NewDecoder(rw)}
+}
+
+func (c *codec) write(f *frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(f)
+}
+
+func (c *codec) read() (*frame, error) {
+	f := &frame{}
+	if err := c.dec.Decode(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// rpcClient is the agent end of a plugin connection.
+type rpcClient struct {
+	codec *codec
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *frame
+	err     error
+	closed  chan struct{}
+}
+
+func newRPCClient(c *codec) *rpcClient {
+	return &rpcClient{codec: c, pending: make(map[uint64]chan *frame), closed: make(chan struct{})}
+}
+
+// readLoop routes responses to their callers until the connection breaks,
+// then fails every outstanding call with ErrPluginExited.
+func (c *rpcClient) readLoop() {
+	for {
+		f, rerr := c.codec.read()
+		if rerr != nil {
+			break
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[f.ID]
+		delete(c.pending, f.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- f
+		}
+	}
+
+	c.mu.Lock()
+	c.err = ErrPluginExited
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	close(c.closed)
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *frame, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.codec.write(&frame{ID: id, Method: method, Params: raw}); err != nil {
+		c.forget(id)
+		return err
+	}
+
+	select {
+	case f, ok := <-ch:
+		if !ok {
+			return c.err
+		}
+		if f.Error != nil {
+			return f.Error
+		}
+		if result == nil || len(f.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(f.Result, result)
+	case <-ctx.Done():
+		c.forget(id)
+		// best effort, the plugin may already be done with it
+		cancel, _ := json.Marshal(cancelParams{ID: id})
+		_ = c.codec.write(&frame{Method: methodCancel, Params: cancel})
+		return ctx.Err()
+	}
+}
+
+func (c *rpcClient) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
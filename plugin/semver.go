@@ -0,0 +1,168 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version, see https://semver.org. Build
+// metadata is dropped as it does not take part in precedence.
+type version struct {
+	major, minor, patch uint64
+	pre                 []string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	core, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("plugin: invalid version %q", s)
+	}
+	nums := make([]uint64, 3)
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil || (len(p) > 1 && p[0] == '0') {
+			return v, fmt.Errorf("plugin: invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	if hasPre {
+		if pre == "" {
+			return v, fmt.Errorf("plugin: invalid version %q", s)
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, nil
+}
+
+func (v version) compare(o version) int {
+	for _, d := range [][2]uint64{{v.major, o.major}, {v.minor, o.minor}, {v.patch, o.patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	// a pre-release sorts before the release
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if c := comparePrerelease(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(v.pre), len(o.pre))
+}
+
+func comparePrerelease(a, b string) int {
+	an, aerr := strconv.ParseUint(a, 10, 64)
+	bn, berr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aerr == nil && berr == nil:
+		if an == bn {
+			return 0
+		}
+		if an < bn {
+			return -1
+		}
+		return 1
+	case aerr == nil:
+		return -1
+	case berr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// satisfies reports whether v is in constraint. A constraint is a list of
+// alternatives separated by "||", each a space separated list of comparisons
+// that must all hold: "=", "!=", ">", ">=", "<", "<=", "^" (same major) and
+// "~" (same minor).
+func satisfies(constraint string, v version) (bool, error) {
+	// every alternative is evaluated so a malformed one is always reported
+	matched := false
+	for _, alt := range strings.Split(constraint, "||") {
+		ok := true
+		fields := strings.Fields(alt)
+		if len(fields) == 0 {
+			return false, fmt.Errorf("plugin: invalid version constraint %q", constraint)
+		}
+		for _, field := range fields {
+			match, err := compareConstraint(field, v)
+			if err != nil {
+				return false, err
+			}
+			ok = ok && match
+		}
+		matched = matched || ok
+	}
+	return matched, nil
+}
+
+func compareConstraint(field string, v version) (bool, error) {
+	op := strings.TrimRight(field, "0123456789.v-+abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	bound, err := parseVersion(field[len(op):])
+	if err != nil {
+		return false, err
+	}
+	c := v.compare(bound)
+	switch op {
+	case "", "=":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case "^":
+		if bound.major == 0 {
+			return c >= 0 && v.major == 0 && v.minor == bound.minor, nil
+		}
+		return c >= 0 && v.major == bound.major, nil
+	case "~":
+		return c >= 0 && v.major == bound.major && v.minor == bound.minor, nil
+	}
+	return false, fmt.Errorf("plugin: invalid version constraint operator %q", op)
+}
@@ -0,0 +1,199 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// Environment the agent passes to a launched plugin.
+const (
+	envMagicCookie = "FRABIT_PLUGIN_MAGIC_COOKIE"
+	envTransport   = "FRABIT_PLUGIN_TRANSPORT"
+	envSocket      = "FRABIT_PLUGIN_SOCKET"
+
+	// magicCookie only guards against running a plugin binary by hand, it is not a secret
+	magicCookie = "d1f0a9e4c6b84f4a8e5b0f2a7c3d9e61"
+)
+
+type Transport string
+
+const (
+	TransportStdio Transport = "stdio"
+	TransportUnix  Transport = "unix"
+)
+
+// Handler implements one RPC method of an out-of-process plugin.
+type Handler func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Server is the plugin end of the protocol, used from the main function of a
+// plugin binary.
+type Server struct {
+	Metadata Metadata
+	Handlers map[string]Handler
+	// Health is answered on the agent's periodic health checks; nil means always healthy.
+	Health func(ctx context.Context) error
+	// Shutdown runs once the agent asks the plugin to stop, after in-flight calls finished.
+	Shutdown func(ctx context.Context) error
+
+	mu       sync.Mutex
+	inflight map[uint64]context.CancelFunc
+}
+
+// Serve connects to the agent that launched the process and answers calls
+// until the agent shuts the plugin down or goes away. When stdio is the
+// transport, stdout belongs to the protocol and the plugin must log to stderr.
+func (s *Server) Serve() error {
+	if os.Getenv(envMagicCookie) != magicCookie {
+		return ErrNotLaunchedByAgent
+	}
+
+	var conn io.ReadWriteCloser
+	switch Transport(os.Getenv(envTransport)) {
+	case TransportUnix:
+		c, err := net.Dial("unix", os.Getenv(envSocket))
+		if err != nil {
+			return err
+		}
+		conn = c
+	case TransportStdio, "":
+		conn = stdioConn{}
+	default:
+		return fmt.Errorf("plugin: unsupported transport %q", os.Getenv(envTransport))
+	}
+	defer conn.Close()
+
+	return s.serveConn(context.Background(), conn)
+}
+
+func (s *Server) serveConn(ctx context.Context, conn io.ReadWriter) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.inflight = make(map[uint64]context.CancelFunc)
+	c := newCodec(conn)
+	hs, _ := json.Marshal(handshake{ProtocolVersion: ProtocolVersion, Metadata: s.Metadata})
+	if err := c.write(&frame{Result: hs}); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for {
+		f, err := c.read()
+		if err != nil {
+			// the agent went away; abandon running calls
+			cancel()
+			wg.Wait()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		switch f.Method {
+		case methodCancel:
+			var p cancelParams
+			if json.Unmarshal(f.Params, &p) == nil {
+				s.cancel(p.ID)
+			}
+		case methodShutdown:
+			wg.Wait()
+			var serr error
+			if s.Shutdown != nil {
+				serr = s.Shutdown(ctx)
+			}
+			_ = c.write(response(f.ID, nil, serr))
+			return serr
+		default:
+			callCtx, callCancel := context.WithCancel(ctx)
+			s.mu.Lock()
+			s.inflight[f.ID] = callCancel
+			s.mu.Unlock()
+
+			wg.Add(1)
+			go func(f *frame) {
+				defer wg.Done()
+				defer s.cancel(f.ID)
+				result, err := s.dispatch(callCtx, f)
+				_ = c.write(response(f.ID, result, err))
+			}(f)
+		}
+	}
+}
+
+func (s *Server) dispatch(ctx context.Context, f *frame) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin %s panicked in %s: %v", s.Metadata.Name, f.Method, r)
+		}
+	}()
+
+	if f.Method == methodHealth {
+		if s.Health == nil {
+			return nil, nil
+		}
+		return nil, s.Health(ctx)
+	}
+	h, ok := s.Handlers[f.Method]
+	if !ok {
+		return nil, &RPCError{Code: "unknown_method", Message: fmt.Sprintf("plugin %s has no method %s", s.Metadata.Name, f.Method)}
+	}
+	return h(ctx, f.Params)
+}
+
+func (s *Server) cancel(id uint64) {
+	s.mu.Lock()
+	cancel, ok := s.inflight[id]
+	delete(s.inflight, id)
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func response(id uint64, result any, err error) *frame {
+	f := &frame{ID: id}
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Message: err.Error()}
+		}
+		f.Error = rpcErr
+		return f
+	}
+	if result != nil {
+		raw, merr := json.Marshal(result)
+		if merr != nil {
+			f.Error = &RPCError{Message: merr.Error()}
+			return f
+		}
+		f.Result = raw
+	}
+	return f
+}
+
+type stdioConn struct{}
+
+func (stdioConn) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdioConn) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdioConn) Close() error                { return os.Stdout.Close() }
@@ -0,0 +1,60 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrObjectNotFound is returned by storage backends for keys that do not exist.
+var ErrObjectNotFound = errors.New("plugin: object not found")
+
+type ObjectInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	ETag    string    `json:"etag,omitempty"`
+}
+
+// StorageBackend stores backup artifacts. Keys are slash separated paths
+// relative to the root the backend was configured with.
+type StorageBackend interface {
+	Plugin
+
+	// Put streams r to key, replacing any existing object once r is drained.
+	// When reading r fails, the object at key is left as it was.
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the objects whose key starts with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Delete removes key; deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// NewMultipartUpload starts an upload assembled from independently sent
+	// parts, for artifacts too large to send or retry in one piece.
+	NewMultipartUpload(ctx context.Context, key string) (MultipartUpload, error)
+}
+
+// MultipartUpload collects parts numbered from 1. Parts may be uploaded in
+// any order and re-uploaded; Complete assembles them in number order.
+type MultipartUpload interface {
+	UploadPart(ctx context.Context, number int, r io.Reader) error
+	Complete(ctx context.Context) error
+	Abort(ctx context.Context) error
+}
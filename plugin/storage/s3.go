@@ -0,0 +1,412 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+const (
+	defaultPartSize = 16 << 20
+	// S3 refuses parts below 5 MiB except for the last one
+	minPartSize = 5 << 20
+)
+
+type S3Config struct {
+	// Endpoint is the base URL of the service, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://minio.internal:9000.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Prefix is prepended to every key, letting several agents share a bucket.
+	Prefix string
+	// PathStyle addresses the bucket as endpoint/bucket instead of as a
+	// subdomain; MinIO and most S3-compatible stores need it.
+	PathStyle bool
+	// PartSize is the chunk size Put switches to multipart uploads at.
+	PartSize   int64
+	HTTPClient *http.Client
+}
+
+// S3 stores objects in an S3-compatible object store.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+var _ plugin.StorageBackend = (*S3)(nil)
+
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("storage: s3 bucket and region are required")
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = defaultPartSize
+	}
+	if cfg.PartSize < minPartSize {
+		return nil, fmt.Errorf("storage: s3 part size must be at least %d bytes", minPartSize)
+	}
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = cleanhttp.DefaultPooledClient()
+	}
+	return &S3{cfg: cfg, endpoint: endpoint, client: client}, nil
+}
+
+func (s *S3) Metadata() plugin.Metadata { return metadata("s3") }
+
+func (s *S3) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// objectURL builds the URL of key, with the path already in the encoding the
+// request is signed with.
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	objectPath := "/" + s.cfg.Prefix + key
+	if key == "" {
+		objectPath = "/"
+	}
+	if s.cfg.PathStyle {
+		objectPath = "/" + s.cfg.Bucket + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	u.Path = objectPath
+	u.RawPath = uriEncode(objectPath, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, plugin.ErrObjectNotFound
+	}
+	return nil, s3Error(resp.StatusCode, resp.Body)
+}
+
+type s3ErrorBody struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func s3Error(status int, body io.Reader) error {
+	var e s3ErrorBody
+	data, _ := io.ReadAll(io.LimitReader(body, 64<<10))
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		return fmt.Errorf("storage: s3 %s: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("storage: s3 request failed with status %d", status)
+}
+
+// Put sends objects up to PartSize in one request and switches to a
+// multipart upload for anything larger, so memory use stays at one part.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, s.cfg.PartSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		resp, err := s.do(ctx, http.MethodPut, key, nil, buf[:n], nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	upload, err := s.newUpload(ctx, key)
+	if err != nil {
+		return err
+	}
+	for part := 1; ; part++ {
+		if err := upload.uploadPart(ctx, part, buf[:n]); err != nil {
+			_ = upload.Abort(context.WithoutCancel(ctx))
+			return err
+		}
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			_ = upload.Abort(context.WithoutCancel(ctx))
+			return err
+		}
+	}
+	return upload.Complete(ctx)
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]plugin.ObjectInfo, error) {
+	var objects []plugin.ObjectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			key := strings.TrimPrefix(c.Key, s.cfg.Prefix)
+			if hiddenKey(key, prefix) {
+				continue
+			}
+			objects = append(objects, plugin.ObjectInfo{
+				Key:     key,
+				Size:    c.Size,
+				ModTime: c.LastModified,
+				ETag:    strings.Trim(c.ETag, `"`),
+			})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if errors.Is(err, plugin.ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*plugin.ObjectInfo, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &plugin.ObjectInfo{
+		Key:     key,
+		Size:    resp.ContentLength,
+		ModTime: modTime,
+		ETag:    strings.Trim(resp.Header.Get("ETag"), `"`),
+	}, nil
+}
+
+func (s *S3) NewMultipartUpload(ctx context.Context, key string) (plugin.MultipartUpload, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.newUpload(ctx, key)
+}
+
+type s3Upload struct {
+	s        *S3
+	key      string
+	uploadID string
+
+	mu    sync.Mutex
+	etags map[int]string
+}
+
+func (s *S3) newUpload(ctx context.Context, key string) (*s3Upload, error) {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &s3Upload{s: s, key: key, uploadID: result.UploadID, etags: make(map[int]string)}, nil
+}
+
+// UploadPart buffers the part in memory, S3 needs its length and checksum up front.
+func (u *s3Upload) UploadPart(ctx context.Context, number int, r io.Reader) error {
+	data, err := io.ReadAll(ctxReader{ctx: ctx, r: r})
+	if err != nil {
+		return err
+	}
+	return u.uploadPart(ctx, number, data)
+}
+
+func (u *s3Upload) uploadPart(ctx context.Context, number int, data []byte) error {
+	if number < 1 || number > 10000 {
+		return fmt.Errorf("storage: part number %d out of range", number)
+	}
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.uploadID}}
+	resp, err := u.s.do(ctx, http.MethodPut, u.key, query, data, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	u.mu.Lock()
+	u.etags[number] = resp.Header.Get("ETag")
+	u.mu.Unlock()
+	return nil
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (u *s3Upload) Complete(ctx context.Context) error {
+	u.mu.Lock()
+	body := completeMultipartUpload{}
+	for number, etag := range u.etags {
+		body.Parts = append(body.Parts, completePart{PartNumber: number, ETag: etag})
+	}
+	u.mu.Unlock()
+	if len(body.Parts) == 0 {
+		return errors.New("storage: multipart upload has no parts")
+	}
+	sort.Slice(body.Parts, func(i, j int) bool { return body.Parts[i].PartNumber < body.Parts[j].PartNumber })
+
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := u.s.do(ctx, http.MethodPost, u.key, url.Values{"uploadId": {u.uploadID}}, data, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 may report a failed completion in the body of a 200 response
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(out, []byte("<Error>")) {
+		return s3Error(resp.StatusCode, bytes.NewReader(out))
+	}
+	return nil
+}
+
+func (u *s3Upload) Abort(ctx context.Context) error {
+	resp, err := u.s.do(ctx, http.MethodDelete, u.key, url.Values{"uploadId": {u.uploadID}}, nil, nil)
+	if errors.Is(err, plugin.ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,232 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+type SFTPConfig struct {
+	// Addr is the host:port of the SSH server.
+	Addr       string
+	User       string
+	Password   string
+	PrivateKey []byte
+	// HostKeyCallback verifies the server, e.g. ssh.FixedHostKey or a
+	// knownhosts callback. It is required.
+	HostKeyCallback ssh.HostKeyCallback
+	// Root is the remote directory objects are stored below.
+	Root string
+}
+
+// SFTP stores objects as files on an SSH server.
+type SFTP struct {
+	client *sftp.Client
+	ssh    *ssh.Client
+	root   string
+}
+
+var _ plugin.StorageBackend = (*SFTP)(nil)
+
+func NewSFTP(cfg SFTPConfig) (*SFTP, error) {
+	if cfg.HostKeyCallback == nil {
+		return nil, errors.New("storage: sftp host key callback is required")
+	}
+	var auth []ssh.AuthMethod
+	if len(cfg.PrivateKey) > 0 {
+		signer, err := ssh.ParsePrivateKey(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+
+	conn, err := ssh.Dial("tcp", cfg.Addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: cfg.HostKeyCallback,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s := NewSFTPFromClient(client, cfg.Root)
+	s.ssh = conn
+	return s, nil
+}
+
+// NewSFTPFromClient uses an already established SFTP session, for callers
+// that need control over how the SSH connection is made.
+func NewSFTPFromClient(client *sftp.Client, root string) *SFTP {
+	if root == "" {
+		root = "."
+	}
+	return &SFTP{client: client, root: root}
+}
+
+func (s *SFTP) Metadata() plugin.Metadata { return metadata("sftp") }
+
+func (s *SFTP) Close() error {
+	err := s.client.Close()
+	if s.ssh != nil {
+		err = errors.Join(err, s.ssh.Close())
+	}
+	return err
+}
+
+func (s *SFTP) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(s.root, key), nil
+}
+
+func notFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, os.ErrNotExist)
+}
+
+func (s *SFTP) Put(ctx context.Context, key string, r io.Reader) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := s.client.MkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	tmp := path.Join(path.Dir(name), ".tmp-"+path.Base(name)+"-"+randomID())
+	f, err := s.client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer s.client.Remove(tmp)
+
+	if _, err := f.ReadFrom(ctxReader{ctx: ctx, r: r}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// plain SFTP rename refuses to replace an existing file
+	if err := s.client.PosixRename(tmp, name); err == nil {
+		return nil
+	}
+	if err := s.client.Remove(name); err != nil && !notFound(err) {
+		return err
+	}
+	return s.client.Rename(tmp, name)
+}
+
+func (s *SFTP) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.client.Open(name)
+	if notFound(err) {
+		return nil, plugin.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *SFTP) List(ctx context.Context, prefix string) ([]plugin.ObjectInfo, error) {
+	var objects []plugin.ObjectInfo
+	// keys are relative to the root; with the default root "." the walked
+	// paths carry no prefix at all
+	root := path.Clean(s.root)
+	rootPrefix := strings.TrimSuffix(root, "/") + "/"
+	walker := s.client.Walk(s.root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if notFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info := walker.Stat()
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			continue
+		}
+		key := path.Clean(walker.Path())
+		if root != "." {
+			key = strings.TrimPrefix(key, rootPrefix)
+		}
+		if !strings.HasPrefix(key, prefix) || hiddenKey(key, prefix) {
+			continue
+		}
+		objects = append(objects, plugin.ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (s *SFTP) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = s.client.Remove(name)
+	if notFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *SFTP) Stat(ctx context.Context, key string) (*plugin.ObjectInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.client.Stat(name)
+	if notFound(err) {
+		return nil, plugin.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	clean, _ := cleanKey(key)
+	return &plugin.ObjectInfo{Key: clean, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *SFTP) NewMultipartUpload(ctx context.Context, key string) (plugin.MultipartUpload, error) {
+	return newStagedUpload(s, key)
+}
@@ -0,0 +1,178 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage ships the built-in plugin.StorageBackend implementations:
+// local filesystem, S3-compatible object stores and SFTP.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/frabits/frabit-go-sdk/frabit"
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// stagingPrefix holds the parts of multipart uploads on backends without
+// native support for them. It is hidden from List.
+const stagingPrefix = ".multipart/"
+
+func metadata(name string) plugin.Metadata {
+	return plugin.Metadata{Name: name, Version: frabit.Version, Kind: plugin.KindStorage, APIVersion: plugin.APIVersion}
+}
+
+// cleanKey normalises key and rejects keys escaping the backend root.
+func cleanKey(key string) (string, error) {
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("storage: invalid key %q", key)
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return cleaned, nil
+}
+
+func hiddenKey(key, prefix string) bool {
+	return strings.HasPrefix(key, stagingPrefix) && !strings.HasPrefix(prefix, stagingPrefix)
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ctxReader stops a streaming copy once its context is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// objectStore is the part of a backend stagedUpload builds on.
+type objectStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]plugin.ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// stagedUpload emulates multipart uploads by storing every part as an object
+// of its own and concatenating them on Complete.
+type stagedUpload struct {
+	store  objectStore
+	key    string
+	prefix string
+}
+
+func newStagedUpload(store objectStore, key string) (*stagedUpload, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &stagedUpload{store: store, key: key, prefix: stagingPrefix + randomID() + "/"}, nil
+}
+
+func (u *stagedUpload) UploadPart(ctx context.Context, number int, r io.Reader) error {
+	if number < 1 {
+		return fmt.Errorf("storage: part number %d out of range", number)
+	}
+	return u.store.Put(ctx, fmt.Sprintf("%s%08d", u.prefix, number), r)
+}
+
+func (u *stagedUpload) Complete(ctx context.Context) error {
+	parts, err := u.store.List(ctx, u.prefix)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return errors.New("storage: multipart upload has no parts")
+	}
+	r := &partsReader{ctx: ctx, store: u.store, parts: parts}
+	defer r.Close()
+	if err := u.store.Put(ctx, u.key, r); err != nil {
+		return err
+	}
+	return u.Abort(ctx)
+}
+
+// Abort drops the uploaded parts.
+func (u *stagedUpload) Abort(ctx context.Context) error {
+	parts, err := u.store.List(ctx, u.prefix)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range parts {
+		errs = append(errs, u.store.Delete(ctx, p.Key))
+	}
+	return errors.Join(errs...)
+}
+
+// partsReader reads the staged parts one after the other, opening each only
+// when the previous one is drained.
+type partsReader struct {
+	ctx   context.Context
+	store objectStore
+	parts []plugin.ObjectInfo
+	cur   io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := r.store.Get(r.ctx, r.parts[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			r.cur, r.parts = rc, r.parts[1:]
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.cur != nil {
+		return r.cur.Close()
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Frabit - The next-generation database automatic operation platform
+// Copyright © 2022-2024 Frabit Team
+//
+// Licensed under the GNU General Public License, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.gnu.org/licenses/gpl-3.0.txt
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+
+	"github.com/frabits/frabit-go-sdk/plugin"
+)
+
+// testBackend is the behaviour every backend has to share.
+func testBackend(t *testing.T, b plugin.StorageBackend) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := b.Put(ctx, "backups/db1/full.xb", strings.NewReader("full backup")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := b.Put(ctx, "backups/db2/full.xb", strings.NewReader("other")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := b.Put(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Error("Put accepted a key escaping the root")
+	}
+
+	rc, err := b.Get(ctx, "backups/db1/full.xb")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "full backup" {
+		t.Errorf("Get = %q", data)
+	}
+	if _, err := b.Get(ctx, "backups/missing"); !errors.Is(err, plugin.ErrObjectNotFound) {
+		t.Errorf("Get missing: %v", err)
+	}
+
+	info, err := b.Stat(ctx, "backups/db1/full.xb")
+	if err != nil || info.Size != int64(len("full backup")) {
+		t.Errorf("Stat = %+v, %v", info, err)
+	}
+
+	upload, err := b.NewMultipartUpload(ctx, "backups/db1/incr.xb")
+	if err != nil {
+		t.Fatalf("NewMultipartUpload: %v", err)
+	}
+	// parts arrive out of order
+	if err := upload.UploadPart(ctx, 2, strings.NewReader("-part2")); err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if err := upload.UploadPart(ctx, 1, strings.NewReader("part1")); err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if err := upload.Complete(ctx); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	objects, err := b.List(ctx, "backups/db1/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 2 || objects[0].Key != "backups/db1/full.xb" || objects[1].Key != "backups/db1/incr.xb" {
+		t.Errorf("List = %+v", objects)
+	}
+	rc, err = b.Get(ctx, "backups/db1/incr.xb")
+	if err != nil {
+		t.Fatalf("Get multipart: %v", err)
+	}
+	data, _ = io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "part1-part2" {
+		t.Errorf("multipart object = %q", data)
+	}
+
+	if err := b.Delete(ctx, "backups/db1/full.xb"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := b.Delete(ctx, "backups/db1/full.xb"); err != nil {
+		t.Errorf("Delete twice: %v", err)
+	}
+	if _, err := b.Stat(ctx, "backups/db1/full.xb"); !errors.Is(err, plugin.ErrObjectNotFound) {
+		t.Errorf("Stat deleted: %v", err)
+	}
+	if all, _ := b.List(ctx, ""); len(all) != 2 {
+		t.Errorf("List all = %+v", all)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	b, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBackend(t, b)
+}
+
+func TestSFTP(t *testing.T) {
+	for _, root := range []string{"/srv/backups", "", "backups"} {
+		t.Run(root, func(t *testing.T) {
+			// an in-memory SFTP server connected through pipes stands in for sshd
+			clientRead, serverWrite := io.Pipe()
+			serverRead, clientWrite := io.Pipe()
+			server := sftp.NewRequestServer(struct {
+				io.Reader
+				io.WriteCloser
+			}{serverRead, serverWrite}, sftp.InMemHandler())
+			go server.Serve()
+
+			client, err := sftp.NewClientPipe(clientRead, clientWrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := NewSFTPFromClient(client, root)
+			defer func() {
+				// the client waits for the server to hang up before Close returns
+				server.Close()
+				b.Close()
+			}()
+			testBackend(t, b)
+		})
+	}
+}
+
+func TestPartsReaderConcatenates(t *testing.T) {
+	b, _ := NewLocal(t.TempDir())
+	ctx := context.Background()
+	for _, key := range []string{"p/1", "p/2", "p/3"} {
+		_ = b.Put(ctx, key, bytes.NewReader(bytes.Repeat([]byte(key[2:]), 3)))
+	}
+	parts, _ := b.List(ctx, "p/")
+	data, err := io.ReadAll(&partsReader{ctx: ctx, store: b, parts: parts})
+	if err != nil || string(data) != "111222333" {
+		t.Errorf("partsReader = %q, %v", data, err)
+	}
+}